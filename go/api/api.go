@@ -27,9 +27,22 @@ type Api struct {
 	// Context of every request
 	ctx context.Context
 
+	// HTTP client that is shared by all requests
+	client *http.Client
+
+	// Limiter for the number of requests (optional)
+	limiter *rateLimiter
+
 	ApiOptions
 }
 
+// DefaultRequestTimeout is the default timeout of a single request
+const DefaultRequestTimeout = 10 * time.Second
+
+// DefaultMaxIdleConnsPerHost is the default number of idle (keep-alive) connections
+// to the server that are kept open
+const DefaultMaxIdleConnsPerHost = 10
+
 // ApiOptions specifies some additional options for the client.
 // These are completely optional and can all be empty
 type ApiOptions struct {
@@ -46,21 +59,94 @@ type ApiOptions struct {
 
 	// When running multiple instances with the same API-Key (WHICH IS NOT RECOMMENDED)
 	// you should set this flag to true that this client is also notified when an entry or
-	// attribute were changed
+	// attribute were changed.
+	// For a single instance this should be false to not receive the own changes again.
+	// The persistence layer replaces received duplicates of its own created or updated entries
+	// within the cache. The execution state of an entry is kept as long as its dates didn't
+	// change, so that the echo of an own change never results in a second execution
 	MultiInstance bool
 
 	// Endpoint of the api to send all requests to.
 	// Defaulting to https://rpdb.rpjosh.de/api/v1
 	BaseUrl string
+
+	// Transport to use for all requests made by the client returned from "GetDefaultClient()".
+	// This can be used to wrap the default transport with a middleware or to record and replay
+	// requests in tests. Defaulting to a clone of http.DefaultTransport with [DefaultMaxIdleConnsPerHost]
+	Transport http.RoundTripper
+
+	// Additional headers that are sent with every request (e.g. an "Authorization" header
+	// required by a gateway). Headers set by the client itself like "X-Api-Key" are not overwritten
+	ExtraHeaders http.Header
+
+	// Timeout of a single request. A zero value means no timeout.
+	// Defaulting to [DefaultRequestTimeout] (if nil)
+	RequestTimeout *time.Duration
+
+	// Timeout of a single bulk request (e.g. "CreateEntries()"). A zero value means no timeout.
+	// Defaulting to the value of "RequestTimeout" (if nil)
+	BulkRequestTimeout *time.Duration
+
+	// Maximum number of requests per second sent to the server. Requests exceeding this
+	// limit are blocked until they are allowed or the context is canceled.
+	// By default, the requests are not limited
+	RequestsPerSecond float64
+
+	// Maximum number of requests that can be sent at once without respecting "RequestsPerSecond".
+	// Defaulting to 1
+	Burst int
+
+	// Maximum number of idle (keep-alive) connections to keep open. This is only used
+	// when no custom transport was given. Defaulting to [DefaultMaxIdleConnsPerHost]
+	MaxIdleConnsPerHost int
+
+	// Whether the attributes returned by "GetAttributes()" should not contain the (potentially large)
+	// parameters and presets. Use "GetAttribute()" to fetch the full details of a single attribute.
+	// This saves bandwidth and latency for accounts with many attributes, when only the names are
+	// required (e.g. for the completion of the CLI). But every attribute whose details are needed
+	// requires an additional request.
+	// The persistence layer fetches the details automatically within "GetAttribute()"
+	LeanAttributes bool
+
+	// Maximum number of objects to send within a single bulk request. Larger requests are split
+	// into multiple requests and the responses are merged. Defaulting to [DefaultMaxBulkSize]
+	MaxBulkSize int
+
+	// Maximum number of split bulk requests that are executed concurrently. Defaulting to 1
+	MaxBulkConcurrency int
 }
 
 // Apiler contains all methods for making requests against the API
 type Apiler interface {
 	GetEntry(id int) (*models.Entry, *models.ErrorResponse)
 	GetEntries(filter models.EntryFilter) ([]*models.Entry, *models.ErrorResponse)
+
+	// GetEntriesByIDs returns the entries with the given IDs in the same order as requested.
+	// IDs for which no entry was found are returned as missing
+	GetEntriesByIDs(ids []int) ([]*models.Entry, []int, *models.ErrorResponse)
+
+	// IterateEntries calls the callback for every entry matching the filter without
+	// keeping all entries in memory. The iteration is stopped on an error of the callback.
+	// The persistence layer calls the callback without holding any lock, so the callback
+	// can safely call other methods of the persistence layer
+	IterateEntries(filter models.EntryFilter, callback func(*models.Entry) error) *models.ErrorResponse
+
+	// GetEntriesCount returns only the number of entries matching the filter
+	GetEntriesCount(filter models.EntryFilter) (int, *models.ErrorResponse)
 	CreateEntry(entry models.Entry) (*models.Entry, *models.ErrorResponse)
+
+	// CreateEntryAwaitResponse creates an entry of an attribute with the type "exec_response"
+	// and returns the response of the execution
+	CreateEntryAwaitResponse(entry models.Entry, timeout time.Duration) (*models.ExecutionResponse, *models.ErrorResponse)
 	DeleteEntry(id int) (*models.ResponseMessageWrapper, *models.ErrorResponse)
 	UpdateEntry(entry *models.Entry) (*models.Entry, *models.ErrorResponse)
+
+	// CreateEntries creates all the given entries with a single request.
+	// The server responds for every requested entry in the same order as requested.
+	// So the successfully created entries are returned in the order of the request.
+	// To correlate a failed entry, use the index of "BulkResponse.ResponseData" which
+	// matches the index of the requested entries.
+	// The same applies to "UpdateEntries()" and "PatchEntries()"
 	CreateEntries(entries []*models.Entry) ([]*models.Entry, *models.BulkResponse[models.Entry], *models.ErrorResponse)
 	UpdateEntries(entries []*models.Entry) ([]*models.Entry, *models.BulkResponse[models.Entry], *models.ErrorResponse)
 	PatchEntries(entries []*models.Entry) ([]*models.Entry, *models.BulkResponse[models.Entry], *models.ErrorResponse)
@@ -75,6 +161,7 @@ type Apiler interface {
 
 	GetAttribute(id int) (*models.Attribute, *models.ErrorResponse)
 	GetAttributeByName(name string) (*models.Attribute, *models.ErrorResponse)
+	GetAttributeByNameFold(name string) (*models.Attribute, *models.ErrorResponse)
 	GetAttributes() ([]*models.Attribute, *models.ErrorResponse)
 
 	// GetRealApi should always return the underlaying API that directly executes the api requests
@@ -100,6 +187,47 @@ func (options *ApiOptions) setAndValidateDefaults() {
 			options.BaseUrl = strings.TrimRight("/", options.BaseUrl)
 		}
 	}
+
+	if options.RequestTimeout == nil {
+		timeout := DefaultRequestTimeout
+		options.RequestTimeout = &timeout
+	}
+}
+
+// requestTimeout returns the value of "RequestTimeout" or the default value if it wasn't set
+func (options *ApiOptions) requestTimeout() time.Duration {
+	if options.RequestTimeout == nil {
+		return DefaultRequestTimeout
+	}
+
+	return *options.RequestTimeout
+}
+
+// bulkRequestTimeout returns the value of "BulkRequestTimeout" or the request timeout
+// if it wasn't set
+func (options *ApiOptions) bulkRequestTimeout() time.Duration {
+	if options.BulkRequestTimeout == nil {
+		return options.requestTimeout()
+	}
+
+	return *options.BulkRequestTimeout
+}
+
+// newHttpClient creates the http.Client that is shared by all requests
+func (options *ApiOptions) newHttpClient() *http.Client {
+	transport := options.Transport
+	if transport == nil {
+		if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+			t := defaultTransport.Clone()
+			t.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+			if t.MaxIdleConnsPerHost <= 0 {
+				t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+			}
+			transport = t
+		}
+	}
+
+	return &http.Client{Timeout: options.requestTimeout(), Transport: transport}
 }
 
 // NewApi is a wrapper for "NewApiWithContext" using context.Background.
@@ -118,6 +246,8 @@ func NewApiWithContext(context context.Context, apiKey string, options ApiOption
 	return &Api{
 		apiKey:     apiKey,
 		ctx:        context,
+		client:     options.newHttpClient(),
+		limiter:    newRateLimiter(options.RequestsPerSecond, options.Burst),
 		ApiOptions: options,
 	}
 }
@@ -140,6 +270,9 @@ func (api *Api) GetRequest(path string, method string, body io.Reader) *http.Req
 	req.Header.Set("Language", api.Language)
 	req.Header.Set("Multi-Instance", strconv.FormatBool(api.MultiInstance))
 	req.Header.Set("Client-Version", models.LibraryVersion)
+	if id := CorrelationID(api.ctx); id != "" {
+		req.Header.Set(CorrelationIDHeader, id)
+	}
 
 	// When fetching entries the dateTime has to be adjusted for the client
 	// time zone. So the current client date will be sent
@@ -150,19 +283,42 @@ func (api *Api) GetRequest(path string, method string, body io.Reader) *http.Req
 	// The body is always sent in JSON format
 	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
 
+	// Add the custom headers without overwriting the required ones
+	for key, values := range api.ExtraHeaders {
+		if req.Header.Get(key) != "" {
+			logger.Debug("Ignoring extra header %q because it's already set by the client", key)
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
 	return req
 }
 
-// GetDefaultClient returns a new http.Client with default
-// settings
+// GetDefaultClient returns the http.Client that is shared by all requests.
+// The connections to the server are reused by all copies of the client
 func (api *Api) GetDefaultClient() http.Client {
-	return http.Client{Timeout: 10 * time.Second}
+	if api.client == nil {
+		// The api was not created with "NewApi()"
+		api.client = api.ApiOptions.newHttpClient()
+	}
+
+	return *api.client
+}
+
+// getBulkClient returns the shared http.Client with the timeout for bulk requests
+func (api *Api) getBulkClient() http.Client {
+	client := api.GetDefaultClient()
+	client.Timeout = api.bulkRequestTimeout()
+
+	return client
 }
 
 // ExecuteRequests executes the given request and pretifies occured errors.
 // See "GetRequest()" for more information.
-// This does internally use a new http.client every time. If you are making a huge number
-// of requests you should consider reusing the same client for not open a connection every time!
+// The connections of the client are reused for all requests (see "GetDefaultClient()")
 func (api *Api) ExecuteRequest(path string, method string, body io.Reader) (*http.Response, *models.ErrorResponse) {
 	client := api.GetDefaultClient()
 	request := api.GetRequest(path, method, body)
@@ -174,9 +330,16 @@ func (api *Api) ExecuteRequest(path string, method string, body io.Reader) (*htt
 // Status codes >= 500 are handled as errors and will be returned
 // as an ErrorResponse.
 func (api *Api) execute(request *http.Request, client http.Client) (path string, response *http.Response, error *models.ErrorResponse) {
-	response, err := client.Do(request)
-
 	path = request.Method + ` "` + strings.Replace(request.URL.String(), api.BaseUrl, "", 1) + `"`
+
+	// Wait until the request is allowed by the rate limit
+	if api.limiter != nil {
+		if err := api.limiter.wait(request.Context()); err != nil {
+			return path, nil, &models.ErrorResponse{ErrorGo: err, Path: path}
+		}
+	}
+
+	response, err := client.Do(request)
 	if err != nil {
 		// An error occured
 		return path, nil, &models.ErrorResponse{ErrorGo: err, Path: path}
@@ -197,6 +360,22 @@ func (api *Api) execute(request *http.Request, client http.Client) (path string,
 	return
 }
 
+// parseRetryAfter parses the value of the header "Retry-After" that is either
+// given in seconds or as a HTTP date. Zero is returned for an invalid value
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if until := time.Until(date); until > 0 {
+			return until
+		}
+	}
+
+	return 0
+}
+
 // handlePHPError reads the error from a request that failed with a
 // status code between 300 - 499.
 // In almost all cases this should be a sepcific error that the PHP server
@@ -207,7 +386,31 @@ func (api *Api) handlePHPError(body []byte, res *http.Response, path string, req
 	}
 	errorResponse.R.Path = path
 	errorResponse.R.ResponseCode = res.StatusCode
-	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.R.Message != "" {
+	isValid := json.Unmarshal(body, &errorResponse) == nil && errorResponse.R.Message != ""
+
+	// The client was throttled by the server
+	if res.StatusCode == http.StatusTooManyRequests {
+		errorResponse.R.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+		logger.Warning("Too many requests sent to the server. Retry after %.0f seconds", errorResponse.R.RetryAfter.Seconds())
+		if !isValid {
+			errorResponse.R.Message = "Too many requests"
+		}
+		return &errorResponse.R
+	}
+
+	// The server requires a newer version of the client
+	if errorResponse.R.ID == models.ErrorIdClientOutdated || res.StatusCode == http.StatusUpgradeRequired {
+		logger.Error(models.ErrClientOutdated.Error())
+		errorResponse.R.ID = models.ErrorIdClientOutdated
+		errorResponse.R.ErrorGo = models.ErrClientOutdated
+		if !isValid {
+			// Use the message of the go error
+			errorResponse.R.Message = ""
+		}
+		return &errorResponse.R
+	}
+
+	if isValid {
 		// It was a valid error response
 		logger.Debug(errorResponse.R.PrintLog("  "))
 		return &errorResponse.R
@@ -250,6 +453,17 @@ func (api *Api) DoRequest(request *http.Request, client http.Client) (*http.Resp
 	return res, nil
 }
 
+// decodeJSON decodes the JSON of the given reader into the value.
+// An empty body (e.g. "204 No Content") is not treated as an error and leaves
+// the value untouched
+func decodeJSON(r io.Reader, v any) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
 // DoRequestBulk executes the given bulk request with the api client.
 // This method should only be used for BULK endpoints at all.
 //
@@ -269,13 +483,18 @@ func DoRequestBulk[T any](api *Api, request *http.Request, client http.Client) (
 
 	// Read the body once
 	body, ioErr := ioutil.ReadAll(res.Body)
-	if err != nil {
+	if ioErr != nil {
 		logger.Debug("Failed to read response body: %s", ioErr)
 		logger.Error("An unknown error occured while queuing the server: %s %q (%d)", request.Method, request.URL, res.StatusCode)
-		return nil, &models.ErrorResponse{ErrorGo: err, Path: path, ResponseCode: res.StatusCode}
+		return nil, &models.ErrorResponse{ErrorGo: ioErr, Path: path, ResponseCode: res.StatusCode}
 	}
 	defer res.Body.Close()
 
+	// Nothing was processed
+	if len(body) == 0 && res.StatusCode < 300 {
+		return &models.BulkResponse[T]{}, nil
+	}
+
 	// If a status code >= 300 is returned there are two possibilities:
 	//  - The request was correct, but individual operations failed
 	//  - The request was not correct (authentication, params, ...)
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// stubTransport records the requests and responds with an empty JSON object
+type stubTransport struct {
+	requests []*http.Request
+}
+
+func (s *stubTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, r)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Request:    r,
+	}, nil
+}
+
+func TestTransportHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ApiOptions
+		path    string
+
+		// Expected headers of the request. An empty value means that the header is not set
+		want map[string]string
+	}{
+		{
+			name: "default headers",
+			path: "/attribute",
+			want: map[string]string{
+				"X-Api-Key":      "key",
+				"Multi-Instance": "false",
+				"Client-Version": models.LibraryVersion,
+				"Client-Date":    "",
+			},
+		},
+		{
+			name:    "multi instance",
+			options: ApiOptions{MultiInstance: true},
+			path:    "/attribute",
+			want:    map[string]string{"Multi-Instance": "true"},
+		},
+		{
+			name:    "extra headers",
+			options: ApiOptions{ExtraHeaders: http.Header{"Authorization": {"Bearer token"}, "X-Api-Key": {"other"}}},
+			path:    "/attribute",
+			want:    map[string]string{"Authorization": "Bearer token", "X-Api-Key": "key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &stubTransport{}
+			tt.options.Transport = transport
+			tt.options.BaseUrl = "http://rpdb.invalid"
+
+			if _, err := NewApi("key", tt.options).ExecuteRequest(tt.path, "GET", nil); err != nil {
+				t.Fatalf("ExecuteRequest() returned an error: %s", err)
+			}
+			if len(transport.requests) != 1 {
+				t.Fatalf("Transport received %d requests, want 1", len(transport.requests))
+			}
+
+			for key, want := range tt.want {
+				if got := transport.requests[0].Header.Get(key); got != want {
+					t.Errorf("Header %q = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	// The client date is only sent for entries
+	transport := &stubTransport{}
+	NewApi("key", ApiOptions{BaseUrl: "http://rpdb.invalid", Transport: transport}).ExecuteRequest("/entry", "GET", nil)
+	if len(transport.requests) != 1 || transport.requests[0].Header.Get("Client-Date") == "" {
+		t.Errorf("Header \"Client-Date\" was not sent for entries")
+	}
+}
+
+func TestNewHttpClientTransport(t *testing.T) {
+	custom := &stubTransport{}
+
+	tests := []struct {
+		name    string
+		options ApiOptions
+
+		wantCustom       bool
+		wantMaxIdleConns int
+	}{
+		{name: "default transport", wantMaxIdleConns: DefaultMaxIdleConnsPerHost},
+		{name: "default transport with idle connections", options: ApiOptions{MaxIdleConnsPerHost: 3}, wantMaxIdleConns: 3},
+		{name: "custom transport", options: ApiOptions{Transport: custom, MaxIdleConnsPerHost: 3}, wantCustom: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.options.newHttpClient()
+
+			if tt.wantCustom {
+				if client.Transport != custom {
+					t.Errorf("Client doesn't use the custom transport")
+				}
+				return
+			}
+
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Client uses the transport %T, want *http.Transport", client.Transport)
+			}
+			if transport == http.DefaultTransport {
+				t.Errorf("Client uses the shared default transport")
+			}
+			if transport.MaxIdleConnsPerHost != tt.wantMaxIdleConns {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, tt.wantMaxIdleConns)
+			}
+		})
+	}
+}
+
+func TestEmptyResponseBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(api *Api) (any, *models.ErrorResponse)
+	}{
+		{name: "GetEntry", request: func(api *Api) (any, *models.ErrorResponse) { return api.GetEntry(1) }},
+		{name: "GetEntries", request: func(api *Api) (any, *models.ErrorResponse) { return api.GetEntries(models.EntryFilter{}) }},
+		{name: "GetAttributes", request: func(api *Api) (any, *models.ErrorResponse) { return api.GetAttributes() }},
+		{name: "GetUpdate", request: func(api *Api) (any, *models.ErrorResponse) { return api.GetUpdate(UpdateRequest{}) }},
+		{
+			name: "DeleteEntries",
+			request: func(api *Api) (any, *models.ErrorResponse) {
+				_, resp, err := api.DeleteEntries([]int{1})
+				return resp, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+			t.Run(fmt.Sprintf("%s %d", tt.name, status), func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(status)
+				}))
+				defer srv.Close()
+
+				got, err := tt.request(NewApi("key", ApiOptions{BaseUrl: srv.URL}))
+				if err != nil {
+					t.Fatalf("%s() returned an error: %s", tt.name, err)
+				}
+				if v := reflect.ValueOf(got); v.IsNil() {
+					t.Errorf("%s() returned nil, want an empty value", tt.name)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty body", want: 5},
+		{name: "value", body: "3", want: 3},
+		{name: "invalid JSON", body: "{", want: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 5
+			if err := decodeJSON(strings.NewReader(tt.body), &got); (err != nil) != tt.wantErr {
+				t.Errorf("decodeJSON() returned the error %v, want an error: %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeJSON() decoded %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/url"
 
@@ -32,35 +31,61 @@ func (api *Api) GetAttributeByName(name string) (*models.Attribute, *models.Erro
 
 	defer res.Body.Close()
 	var rtc []*models.Attribute
-	if err := json.NewDecoder(res.Body).Decode(&rtc); err != nil {
+	if err := decodeJSON(res.Body, &rtc); err != nil {
 		logger.Debug("Failed to decode attribute array: %s", err)
 		return nil, &models.ErrorResponse{ErrorGo: err}
 	}
 
 	if len(rtc) != 1 {
-		return nil, &models.ErrorResponse{ID: "ATTRIBUTE_NOT_FOUND", ResponseCode: 404, Message: "Attribute was not found"}
+		return nil, &models.ErrorResponse{ID: models.ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
 	}
 
 	return rtc[0], nil
 }
 
 func (api *Api) GetAttributes() ([]*models.Attribute, *models.ErrorResponse) {
-	res, err := api.ExecuteRequest("/attribute", "GET", nil)
+	req := api.GetRequest("/attribute", "GET", nil)
+	if api.LeanAttributes {
+		req.Header.Set("Lean-Attributes", "true")
+	}
+
+	res, err := api.DoRequest(req, api.GetDefaultClient())
 	if err != nil {
 		return []*models.Attribute{}, err
 	}
 
+	defer res.Body.Close()
+
 	// No attributes received
 	if res.StatusCode == 204 {
 		return []*models.Attribute{}, nil
 	}
 
-	defer res.Body.Close()
 	var rtc []*models.Attribute
-	if err := json.NewDecoder(res.Body).Decode(&rtc); err != nil {
+	if err := decodeJSON(res.Body, &rtc); err != nil {
 		logger.Debug("Failed to decode attribute array: %s", err)
 		return []*models.Attribute{}, &models.ErrorResponse{ErrorGo: err}
 	}
 
+	if rtc == nil {
+		rtc = []*models.Attribute{}
+	}
+
 	return rtc, nil
 }
+
+// GetAttributeByNameFold is like "GetAttributeByName()" but the name is compared
+// case-insensitive if no attribute matches exactly.
+// See [models.FindAttributeByName] for details
+func (api *Api) GetAttributeByNameFold(name string) (*models.Attribute, *models.ErrorResponse) {
+	attributes, err := api.GetAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	if attr := models.FindAttributeByName(attributes, name); attr != nil {
+		return attr, nil
+	}
+
+	return nil, &models.ErrorResponse{ID: models.ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
+}
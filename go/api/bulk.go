@@ -0,0 +1,134 @@
+package api
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+	"git.rpjosh.de/RPJosh/go-logger"
+)
+
+// DefaultMaxBulkSize is the maximum number of objects sent within a single bulk
+// request if no other value was configured via [ApiOptions.MaxBulkSize]
+const DefaultMaxBulkSize = 200
+
+// maxBulkSize returns the configured maximum bulk size or the default value
+func (o *ApiOptions) maxBulkSize() int {
+	if o.MaxBulkSize <= 0 {
+		return DefaultMaxBulkSize
+	}
+
+	return o.MaxBulkSize
+}
+
+// maxBulkConcurrency returns the configured number of concurrent bulk requests
+func (o *ApiOptions) maxBulkConcurrency() int {
+	if o.MaxBulkConcurrency <= 0 {
+		return 1
+	}
+
+	return o.MaxBulkConcurrency
+}
+
+// doBulkSplit splits the given data into chunks of the maximum bulk size and executes
+// the request for every chunk. The responses are merged into a single bulk response
+// in the same order as the given data.
+// The function "failed" converts a requested object to the data of a failed response.
+// It's used for all objects of a chunk whose request failed
+func doBulkSplit[T any, R any](api *Api, data []T, request func(chunk []T) (*models.BulkResponse[R], *models.ErrorResponse), failed func(T) R) (*models.BulkResponse[R], *models.ErrorResponse) {
+	size := api.maxBulkSize()
+	if len(data) <= size {
+		return request(data)
+	}
+
+	chunks := make([][]T, 0, len(data)/size+1)
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, data[start:end])
+	}
+
+	responses := make([]*models.BulkResponse[R], len(chunks))
+	errs := make([]*models.ErrorResponse, len(chunks))
+
+	// Limit the number of concurrent requests
+	sem := make(chan struct{}, api.maxBulkConcurrency())
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, chunk []T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			responses[i], errs[i] = request(chunk)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	return mergeBulkResponses(chunks, responses, errs, failed)
+}
+
+// correlateBulkResponse sets the identity of the requested objects within the response
+// data and logs all failed operations with their identity.
+// The server returns a response for every requested object in the same order. If the
+// number of responses doesn't match, the objects cannot be correlated
+func correlateBulkResponse[T any, R any](data []T, resp *models.BulkResponse[R], identify func(T) string) {
+	if len(resp.ResponseData) != len(data) {
+		return
+	}
+
+	for i := range resp.ResponseData {
+		d := &resp.ResponseData[i]
+		d.Identity = identify(data[i])
+
+		if d.Status == models.StatusFailed {
+			logger.Warning("Bulk operation for %s failed (%d): %s", d.Identity, d.StatusCode, d.Error.Error())
+		}
+	}
+}
+
+// mergeBulkResponses merges the responses of the single chunks into one bulk response.
+// All objects of a failed chunk are added with the status [models.StatusFailed].
+// Only if all chunks failed, the first error is returned
+func mergeBulkResponses[T any, R any](chunks [][]T, responses []*models.BulkResponse[R], errs []*models.ErrorResponse, failed func(T) R) (*models.BulkResponse[R], *models.ErrorResponse) {
+	rtc := &models.BulkResponse[R]{}
+	messages := make([]string, 0, len(responses))
+	failedChunks := 0
+
+	for i, resp := range responses {
+		if errs[i] != nil {
+			failedChunks++
+			logger.Warning("Bulk request %d of %d failed: %s", i+1, len(chunks), errs[i])
+
+			for _, d := range chunks[i] {
+				rtc.ResponseData = append(rtc.ResponseData, models.BulkResponseData[R]{
+					Status:     models.StatusFailed,
+					StatusCode: errs[i].ResponseCode,
+					Data:       failed(d),
+					Error:      *errs[i],
+				})
+			}
+			rtc.Overview.Errors += len(chunks[i])
+			continue
+		}
+
+		rtc.Overview.Successful += resp.Overview.Successful
+		rtc.Overview.Errors += resp.Overview.Errors
+		rtc.Overview.Exists += resp.Overview.Exists
+		rtc.ResponseData = append(rtc.ResponseData, resp.ResponseData...)
+		if resp.Message.Client != "" {
+			messages = append(messages, resp.Message.Client)
+		}
+	}
+
+	if failedChunks == len(chunks) {
+		return nil, errs[0]
+	}
+
+	rtc.Message.Client = strings.Join(messages, "\n")
+	return rtc, nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestDoBulkSplit(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		maxBulkSize int
+		concurrency int
+		// First values of the chunks whose request fails
+		failing []int
+
+		wantRequests int
+		wantErrors   int
+		wantErr      bool
+	}{
+		{name: "single request", size: 5, maxBulkSize: 5, wantRequests: 1},
+		{name: "default bulk size", size: DefaultMaxBulkSize + 1, wantRequests: 2},
+		{name: "split into equal chunks", size: 6, maxBulkSize: 2, wantRequests: 3},
+		{name: "split with remainder", size: 7, maxBulkSize: 3, concurrency: 2, wantRequests: 3},
+		{name: "failed chunk", size: 6, maxBulkSize: 2, concurrency: 3, failing: []int{2}, wantRequests: 3, wantErrors: 2},
+		{name: "all chunks failed", size: 4, maxBulkSize: 2, failing: []int{0, 2}, wantRequests: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := NewApi("key", ApiOptions{MaxBulkSize: tt.maxBulkSize, MaxBulkConcurrency: tt.concurrency})
+			data := make([]int, tt.size)
+			for i := range data {
+				data[i] = i
+			}
+
+			var mtx sync.Mutex
+			requests, running, maxRunning := 0, 0, 0
+			request := func(chunk []int) (*models.BulkResponse[int], *models.ErrorResponse) {
+				mtx.Lock()
+				requests++
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mtx.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+				mtx.Lock()
+				running--
+				mtx.Unlock()
+
+				for _, f := range tt.failing {
+					if chunk[0] == f {
+						return nil, &models.ErrorResponse{ResponseCode: 500, Message: "failed"}
+					}
+				}
+
+				resp := &models.BulkResponse[int]{}
+				resp.Overview.Successful = len(chunk)
+				resp.Message.Client = fmt.Sprintf("chunk %d", chunk[0])
+				for _, d := range chunk {
+					resp.ResponseData = append(resp.ResponseData, models.BulkResponseData[int]{Status: models.StatusCreated, Data: d})
+				}
+				return resp, nil
+			}
+
+			resp, err := doBulkSplit(api, data, request, func(d int) int { return d })
+			if requests != tt.wantRequests {
+				t.Errorf("doBulkSplit() sent %d requests, want %d", requests, tt.wantRequests)
+			}
+			if limit := api.maxBulkConcurrency(); maxRunning > limit {
+				t.Errorf("doBulkSplit() sent %d concurrent requests, want at most %d", maxRunning, limit)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("doBulkSplit() returned the error %v, want an error: %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The merged response keeps the order of the data
+			if len(resp.ResponseData) != len(data) {
+				t.Fatalf("doBulkSplit() returned %d responses, want %d", len(resp.ResponseData), len(data))
+			}
+			for i, d := range resp.ResponseData {
+				if d.Data != i {
+					t.Errorf("ResponseData[%d].Data = %d, want %d", i, d.Data, i)
+				}
+			}
+			if resp.Overview.Errors != tt.wantErrors || resp.Overview.Successful != len(data)-tt.wantErrors {
+				t.Errorf("doBulkSplit() overview = %+v, want %d errors", resp.Overview, tt.wantErrors)
+			}
+		})
+	}
+}
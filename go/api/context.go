@@ -0,0 +1,37 @@
+package api
+
+import "context"
+
+// correlationIDKey is the key of the correlation ID within a context
+type correlationIDKey struct{}
+
+// CorrelationIDHeader is the name of the header in which the correlation ID
+// of a request is sent to the server
+const CorrelationIDHeader = "X-Correlation-Id"
+
+// WithCorrelationID returns a copy of the given context containing the correlation ID.
+// When a request is made with this context (see [Api.WithContext] and [NewApiWithContext]),
+// the ID is sent within the header [CorrelationIDHeader]. This allows to correlate the
+// logs of the server with the traces of the client
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID stored in the context. If no ID was
+// stored, an empty string is returned
+func CorrelationID(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationIDKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
+
+// WithContext returns a shallow copy of the api that uses the given context
+// for all requests
+func (api *Api) WithContext(ctx context.Context) *Api {
+	rtc := *api
+	rtc.ctx = ctx
+
+	return &rtc
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/models"
 	"git.rpjosh.de/RPJosh/go-logger"
@@ -45,35 +47,307 @@ func (api *Api) GetEntry(id int) (*models.Entry, *models.ErrorResponse) {
 	return models.NewEntry(res.Body), nil
 }
 
+// GetEntryExpanded is like "GetEntry()" but the attribute of the entry is always
+// fully expanded by the server, even if the option "TreatAsJavaClient" is set.
+// Use this if you don't have the attributes cached locally. Note that this
+// requires more bandwidth than linking the attribute with a local cache
+func (api *Api) GetEntryExpanded(id int) (*models.Entry, *models.ErrorResponse) {
+	req := api.GetRequest(fmt.Sprintf("/entry/%d", id), "GET", nil)
+	req.Header.Set("Java-Client", "false")
+
+	res, err := api.DoRequest(req, api.GetDefaultClient())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return models.NewEntry(res.Body), nil
+}
+
 func (api *Api) GetEntries(filter models.EntryFilter) ([]*models.Entry, *models.ErrorResponse) {
 	res, err := api.ExecuteRequest("/entry", "PROPFIND", bytes.NewBuffer(filter.ToJson()))
 	if err != nil {
 		return []*models.Entry{}, err
 	}
 
+	defer res.Body.Close()
+
 	// No entries received
 	if res.StatusCode == 204 {
 		return []*models.Entry{}, nil
 	}
 
-	defer res.Body.Close()
 	var rtc []*models.Entry
-	if err := json.NewDecoder(res.Body).Decode(&rtc); err != nil {
+	if err := decodeJSON(res.Body, &rtc); err != nil {
 		logger.Debug("Failed to decode entry array: %s", err)
 		return []*models.Entry{}, &models.ErrorResponse{ErrorGo: err}
 	}
 
+	if rtc == nil {
+		rtc = []*models.Entry{}
+	}
+
 	return rtc, nil
 }
 
+// IterateEntries calls the callback for every entry matching the given filter.
+// In contrast to [Api.GetEntries] the response is decoded incrementally, so that not all
+// entries have to be kept in memory.
+// The iteration is stopped when the callback returns an error. This error is returned as
+// the "ErrorGo" of the response
+func (api *Api) IterateEntries(filter models.EntryFilter, callback func(*models.Entry) error) *models.ErrorResponse {
+	res, err := api.ExecuteRequest("/entry", "PROPFIND", bytes.NewBuffer(filter.ToJson()))
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	// No entries received
+	if res.StatusCode == 204 {
+		return nil
+	}
+
+	dec := json.NewDecoder(res.Body)
+	if tok, err := dec.Token(); err == io.EOF {
+		return nil
+	} else if err != nil {
+		logger.Debug("Failed to decode entry array: %s", err)
+		return &models.ErrorResponse{ErrorGo: err}
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return &models.ErrorResponse{ErrorGo: fmt.Errorf("expected an array of entries but got %v", tok)}
+	}
+
+	for dec.More() {
+		var ent models.Entry
+		if err := dec.Decode(&ent); err != nil {
+			logger.Debug("Failed to decode entry: %s", err)
+			return &models.ErrorResponse{ErrorGo: err}
+		}
+
+		if err := callback(&ent); err != nil {
+			return &models.ErrorResponse{ErrorGo: err}
+		}
+	}
+
+	// Consume the closing bracket of the array
+	if _, err := dec.Token(); err != nil {
+		return &models.ErrorResponse{ErrorGo: err}
+	}
+
+	return nil
+}
+
+// GetEntriesCount returns only the number of entries matching the given filter.
+// The entries itself are not transferred
+func (api *Api) GetEntriesCount(filter models.EntryFilter) (int, *models.ErrorResponse) {
+	req := api.GetRequest("/entry", "PROPFIND", bytes.NewBuffer(filter.ToJson()))
+	req.Header.Set("Count-Only", "true")
+
+	res, err := api.DoRequest(req, api.GetDefaultClient())
+	if err != nil {
+		return -1, err
+	}
+
+	defer res.Body.Close()
+
+	// No entries found
+	if res.StatusCode == 204 {
+		return 0, nil
+	}
+
+	var rtc struct {
+		Count int `json:"count"`
+	}
+	if err := decodeJSON(res.Body, &rtc); err != nil {
+		logger.Debug("Failed to decode entry count: %s", err)
+		return -1, &models.ErrorResponse{ErrorGo: err}
+	}
+
+	return rtc.Count, nil
+}
+
+// Maximum number of entries that can be returned by a single filter request
+const maxFilterEntries = 200
+
+// GetEntriesPaged returns all entries matching the filter beyond the limit of [maxFilterEntries]
+// of a single request. The entries are fetched page by page with the given page size (maximum 200)
+// until a page contains less entries than the page size.
+// The fields "MaxEntries" and "Offset" of the filter are overwritten.
+//
+// If a page couldn't be fetched or the context of the api was canceled, the already fetched
+// entries are returned together with the error.
+// When the server doesn't support the offset, the same page would be returned every time. In this
+// case the fetching is stopped after the second page with an error
+func (api *Api) GetEntriesPaged(filter models.EntryFilter, pageSize int) ([]*models.Entry, *models.ErrorResponse) {
+	if pageSize <= 0 || pageSize > maxFilterEntries {
+		pageSize = maxFilterEntries
+	}
+
+	rtc := make([]*models.Entry, 0)
+	for offset := 0; ; offset += pageSize {
+		if err := api.ctx.Err(); err != nil {
+			return rtc, &models.ErrorResponse{ErrorGo: err}
+		}
+
+		filter.MaxEntries = pageSize
+		filter.Offset = offset
+		page, err := api.GetEntries(filter)
+		if err != nil {
+			return rtc, err
+		}
+
+		// The server ignored the offset
+		if offset > 0 && len(page) > 0 && len(rtc) >= pageSize && page[0].ID == rtc[offset-pageSize].ID {
+			return rtc, &models.ErrorResponse{ErrorGo: fmt.Errorf("the server does not support an offset for the entries")}
+		}
+
+		rtc = append(rtc, page...)
+		if len(page) < pageSize {
+			return rtc, nil
+		}
+	}
+}
+
+// GetEntriesByIDs returns the entries with the given IDs in the same order as requested.
+// Also entries that are already past are returned. IDs for which no entry was found are returned
+// as missing
+func (api *Api) GetEntriesByIDs(ids []int) ([]*models.Entry, []int, *models.ErrorResponse) {
+	entries := make([]*models.Entry, 0, len(ids))
+	for start := 0; start < len(ids); start += maxFilterEntries {
+		end := start + maxFilterEntries
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		ent, err := api.GetEntries(models.EntryFilter{IDs: ids[start:end], OldDates: true, MaxEntries: end - start})
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, ent...)
+	}
+
+	ordered, missing := models.OrderEntriesByIDs(entries, ids)
+	return ordered, missing, nil
+}
+
+// CreateEntry creates the given entry. Use "CreationStatus" of the returned entry to check if
+// an identical entry did already exist
 func (api *Api) CreateEntry(entry models.Entry) (*models.Entry, *models.ErrorResponse) {
+	if err := api.resolveParameterNames(&entry); err != nil {
+		return nil, err
+	}
+
 	res, err := api.ExecuteRequest("/entry", "POST", bytes.NewBuffer(entry.ToJson()))
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
-	return models.NewEntry(res.Body), nil
+	body, rErr := io.ReadAll(res.Body)
+	if rErr != nil {
+		return nil, &models.ErrorResponse{ErrorGo: rErr}
+	}
+
+	ent := models.NewEntry(bytes.NewReader(body))
+	ent.CreationStatus = parseCreationStatus(body)
+
+	return ent, nil
+}
+
+// CreateEntryAwaitResponse creates an entry of an attribute with the type "exec_response" and
+// returns the response of the execution. The server waits until the client responded or the given
+// timeout was exceeded. If no timeout is given, the default timeout of the attribute is used.
+// An error is returned if the attribute is not of the type "exec_response" or if the execution
+// was delayed because no response was received in time (see "AllowDelayedExecution")
+func (api *Api) CreateEntryAwaitResponse(entry models.Entry, timeout time.Duration) (*models.ExecutionResponse, *models.ErrorResponse) {
+	if entry.Attribute == nil {
+		return nil, &models.ErrorResponse{ErrorGo: fmt.Errorf("no attribute was given for the entry")}
+	}
+
+	// The type of the attribute is required
+	if entry.Attribute.Name == "" {
+		attr, err := api.GetAttribute(entry.Attribute.ID)
+		if err != nil {
+			return nil, err
+		}
+		entry.Attribute = attr
+	}
+	if !entry.Attribute.IsExecResponse() {
+		return nil, &models.ErrorResponse{ErrorGo: fmt.Errorf("the attribute %q is not of the type exec_response", entry.Attribute.Name)}
+	}
+
+	// Wait at least for the execution timeout
+	waitTime := time.Duration(entry.Attribute.ExecResponse.DefaultTimeout) * time.Second
+	if timeout > 0 {
+		entry.Timeout = models.NullInt{Valid: true, Int32: int32(timeout.Seconds())}
+		waitTime = timeout
+	}
+
+	if err := api.resolveParameterNames(&entry); err != nil {
+		return nil, err
+	}
+
+	client := api.GetDefaultClient()
+	if client.Timeout != 0 && client.Timeout < waitTime+api.requestTimeout() {
+		client.Timeout = waitTime + api.requestTimeout()
+	}
+	res, err := api.DoRequest(api.GetRequest("/entry", "POST", bytes.NewBuffer(entry.ToJson())), client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	ent := models.NewEntry(res.Body)
+	if ent.Attribute == nil {
+		ent.Attribute = entry.Attribute
+	}
+	if !ent.IsImmediateExecResponse() {
+		return nil, &models.ErrorResponse{ErrorGo: fmt.Errorf("no response was received in time. The entry #%d will be executed delayed", ent.ID)}
+	}
+
+	id := ent.ExecutionResponseId
+	if id == 0 {
+		id = ent.ID
+	}
+	return models.NewExecutionResponse(id, ent.ResponseCode, ent.Response), nil
+}
+
+// parseCreationStatus parses the status of a single entry creation from the given
+// response body. If the server doesn't include the status, [models.StatusCreated] is returned
+func parseCreationStatus(body []byte) models.BulkResponseStatus {
+	var resp struct {
+		Status *models.BulkResponseStatus `json:"status"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Status == nil {
+		return models.StatusCreated
+	}
+
+	return *resp.Status
+}
+
+// resolveParameterNames resolves the parameters of the entry that are addressed by their
+// name to their position. If the attribute of the entry doesn't contain the parameter
+// definitions, the attribute is fetched from the API
+func (api *Api) resolveParameterNames(entry *models.Entry) *models.ErrorResponse {
+	if !entry.HasNamedParameters() {
+		return nil
+	}
+
+	if entry.Attribute != nil && entry.Attribute.ID != 0 && len(entry.Attribute.Parameter) == 0 {
+		attr, err := api.GetAttribute(entry.Attribute.ID)
+		if err != nil {
+			return err
+		}
+		entry.Attribute = attr
+	}
+
+	if err := entry.ResolveParameterNames(); err != nil {
+		return &models.ErrorResponse{ErrorGo: err}
+	}
+
+	return nil
 }
 
 func (api *Api) DeleteEntry(id int) (*models.ResponseMessageWrapper, *models.ErrorResponse) {
@@ -109,16 +383,24 @@ func (api *Api) PatchEntries(entries []*models.Entry) ([]*models.Entry, *models.
 }
 
 func (api *Api) makeBulkCreateOrUpdate(method string, entries []*models.Entry) ([]*models.Entry, *models.BulkResponse[models.Entry], *models.ErrorResponse) {
-	// Get request with data
-	ent := bulkEntry[*models.Entry]{Data: entries}
-	req := api.GetRequest("/entry", method, bytes.NewBuffer(ent.toJson()))
+	// Execute the request (split by the maximum bulk size)
+	resp, err := doBulkSplit(api, entries, func(chunk []*models.Entry) (*models.BulkResponse[models.Entry], *models.ErrorResponse) {
+		ent := bulkEntry[*models.Entry]{Data: chunk}
+		req := api.GetRequest("/entry", method, bytes.NewBuffer(ent.toJson()))
 
-	// Execute request
-	resp, err := DoRequestBulk[models.Entry](api, req, api.GetDefaultClient())
+		return DoRequestBulk[models.Entry](api, req, api.getBulkClient())
+	}, func(e *models.Entry) models.Entry { return *e })
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// The server returns a response for every requested entry in the same order.
+	// So the index of the response data correlates to the index of the request
+	if len(resp.ResponseData) != len(entries) {
+		logger.Warning("Received %d bulk responses for %d requested entries. The order of the entries may differ", len(resp.ResponseData), len(entries))
+	}
+	correlateBulkResponse(entries, resp, (*models.Entry).Identity)
+
 	// Get created entries
 	rtc := make([]*models.Entry, 0)
 	for i, e := range resp.ResponseData {
@@ -131,15 +413,17 @@ func (api *Api) makeBulkCreateOrUpdate(method string, entries []*models.Entry) (
 }
 
 func (api *Api) DeleteEntries(idsToDelete []int) ([]int, *models.BulkResponse[int], *models.ErrorResponse) {
-	// Get request with data
-	ent := bulkEntry[int]{Data: idsToDelete}
-	req := api.GetRequest("/entry/delete", "PATCH", bytes.NewBuffer(ent.toJson()))
+	// Execute the request (split by the maximum bulk size)
+	resp, err := doBulkSplit(api, idsToDelete, func(chunk []int) (*models.BulkResponse[int], *models.ErrorResponse) {
+		ent := bulkEntry[int]{Data: chunk}
+		req := api.GetRequest("/entry/delete", "PATCH", bytes.NewBuffer(ent.toJson()))
 
-	// Execute request
-	resp, err := DoRequestBulk[int](api, req, api.GetDefaultClient())
+		return DoRequestBulk[int](api, req, api.getBulkClient())
+	}, func(id int) int { return id })
 	if err != nil {
 		return nil, nil, err
 	}
+	correlateBulkResponse(idsToDelete, resp, func(id int) string { return fmt.Sprintf("#%d", id) })
 
 	// Get deleted entries
 	rtc := make([]int, 0)
@@ -160,7 +444,7 @@ func (api *Api) DeleteEntriesFiltered(filter models.EntryFilter) (EntryDeleteFil
 
 	defer res.Body.Close()
 	var rtc EntryDeleteFiltered
-	if err := json.NewDecoder(res.Body).Decode(&rtc); err != nil {
+	if err := decodeJSON(res.Body, &rtc); err != nil {
 		logger.Debug("Failed to decode delete entry filtered response: %s", err)
 		return EntryDeleteFiltered{}, &models.ErrorResponse{ErrorGo: err}
 	}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// newBulkTestServer returns a server that responds to bulk requests of entries in the
+// order of the request. Entries with the note "fail" are rejected.
+// Requests with more entries are answered faster, so that split requests finish out of order
+func newBulkTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req bulkEntry[models.Entry]
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode bulk request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		time.Sleep(time.Duration(10-len(req.Data)) * 10 * time.Millisecond)
+
+		resp := models.BulkResponse[models.Entry]{}
+		for _, e := range req.Data {
+			data := models.BulkResponseData[models.Entry]{Status: models.StatusCreated, StatusCode: 201, Data: e}
+			if r.Method != "POST" {
+				data.Status, data.StatusCode = models.StatusUpdated, 200
+			}
+			if e.Note == "fail" {
+				data = models.BulkResponseData[models.Entry]{Status: models.StatusFailed, StatusCode: 400, Data: e}
+			}
+			resp.ResponseData = append(resp.ResponseData, data)
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestMakeBulkCreateOrUpdateOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		notes       []string
+		maxBulkSize int
+		want        []string
+	}{
+		{
+			name:   "create",
+			method: "POST",
+			notes:  []string{"entry 3", "entry 1", "entry 2"},
+			want:   []string{"entry 3", "entry 1", "entry 2"},
+		},
+		{
+			name:   "create with failed entry",
+			method: "POST",
+			notes:  []string{"entry 3", "fail", "entry 2"},
+			want:   []string{"entry 3", "entry 2"},
+		},
+		{
+			name:        "create split into multiple requests",
+			method:      "POST",
+			notes:       []string{"entry 5", "entry 4", "entry 3", "entry 2", "entry 1"},
+			maxBulkSize: 2,
+			want:        []string{"entry 5", "entry 4", "entry 3", "entry 2", "entry 1"},
+		},
+		{
+			name:        "update split into multiple requests",
+			method:      "PUT",
+			notes:       []string{"entry 2", "entry 1", "entry 3"},
+			maxBulkSize: 1,
+			want:        []string{"entry 2", "entry 1", "entry 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newBulkTestServer(t)
+			defer srv.Close()
+			api := NewApi("key", ApiOptions{BaseUrl: srv.URL, MaxBulkSize: tt.maxBulkSize, MaxBulkConcurrency: 3})
+
+			entries := make([]*models.Entry, len(tt.notes))
+			for i, n := range tt.notes {
+				entries[i] = &models.Entry{ID: i + 1, Attribute: &models.Attribute{ID: 1}, Note: n}
+			}
+
+			created, resp, err := api.makeBulkCreateOrUpdate(tt.method, entries)
+			if err != nil {
+				t.Fatalf("makeBulkCreateOrUpdate() returned an error: %s", err)
+			}
+
+			got := make([]string, len(created))
+			for i, e := range created {
+				got[i] = e.Note
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("makeBulkCreateOrUpdate() = %q, want %q", got, tt.want)
+			}
+
+			// The response data correlates to the requested entries by their index
+			for i, d := range resp.ResponseData {
+				if d.Identity != entries[i].Identity() {
+					t.Errorf("ResponseData[%d].Identity = %q, want %q", i, d.Identity, entries[i].Identity())
+				}
+			}
+		})
+	}
+}
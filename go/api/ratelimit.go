@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a simple token bucket limiting the number of requests per second
+type rateLimiter struct {
+	mux sync.Mutex
+
+	// Number of tokens added per second
+	rate float64
+	// Maximum number of tokens
+	burst float64
+
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a new rate limiter or nil if the requests should not be limited
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available or the context was canceled
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mux.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mux.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mux.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -29,6 +29,21 @@ type UpdateRequest struct {
 	OnlyVersion bool
 }
 
+// NewUpdateRequestSince creates an update request for all updates that occurred
+// since the given version number or time (formatted in the server time format)
+func NewUpdateRequestSince(since string) (UpdateRequest, error) {
+	if version, err := strconv.Atoi(since); err == nil {
+		return UpdateRequest{LatestVersion: version}, nil
+	}
+
+	tme, err := time.ParseInLocation(models.TimeFormat, since, time.Now().Location())
+	if err != nil {
+		return UpdateRequest{}, fmt.Errorf("expected a version number or a time in the format YYYY-MM-DDThh:mm:ss. Got %q", since)
+	}
+
+	return UpdateRequest{LaterThan: tme}, nil
+}
+
 func (api *Api) GetUpdate(updReq UpdateRequest) (*models.Update, *models.ErrorResponse) {
 	req := api.GetRequest(fmt.Sprintf("/update/%d", updReq.LatestVersion), "GET", nil)
 
@@ -1,11 +1,17 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/api"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
 	"github.com/RPJoshL/RPdb/v4/go/persistence"
 	"git.rpjosh.de/RPJosh/go-logger"
 	yaml "gopkg.in/yaml.v3"
@@ -19,6 +25,7 @@ type AppConfig struct {
 	UserConfig      UserConfig         `yaml:"user"`
 	AttributeConfig []AttributeOptions `yaml:"attributes"`
 	LoggerConfig    LoggerConfig       `yaml:"logger"`
+	StartupConfig   StartupConfig      `yaml:"startup"`
 	RuntimeOptions  RuntimeOptions
 }
 
@@ -30,6 +37,13 @@ type UserConfig struct {
 	MultiInstance bool   `yaml:"multiInstance" cli:"--multiInstance,-mi,~~~"`
 	BaseURL       string `yaml:"baseURL"`
 	SocketURL     string `yaml:"socketURL"`
+
+	// Maximum time to wait for establishing a WebSocket connection. Defaulting to 5 seconds
+	SocketDialTimeout time.Duration `yaml:"socketDialTimeout"`
+
+	// Whether a WebSocket connection should be used for real time updates. Defaulting to true.
+	// Without the WebSocket, entries of attributes with "exec_response" or "no_db" are never received
+	UseWebsocket *bool `yaml:"useWebsocket"`
 }
 
 func (c *UserConfig) SetMultiInstance() string {
@@ -51,6 +65,32 @@ type AttributeOptions struct {
 	Program           string `yaml:"program"`
 	OnDeleteProgram   string `yaml:"onDelete"`
 	PassOnlyParameter bool   `yaml:"passOnlyParameter"`
+
+	// Offset to use when creating an entry for this attribute via the CLI without
+	// providing a date, offset or date pattern. E.g.: "+20m"
+	DefaultOffset string `yaml:"defaultOffset"`
+
+	// URL to which the entry is sent as JSON on execution. This can be used
+	// in addition to or instead of "Program"
+	Webhook string `yaml:"webhook"`
+	// HTTP method to use for the webhook. Defaulting to "POST"
+	WebhookMethod string `yaml:"webhookMethod"`
+	// Additional headers to send to the webhook
+	WebhookHeaders map[string]string `yaml:"webhookHeaders"`
+
+	// Go templates (text/template) for the arguments of the program. Every template is
+	// rendered to a single argument and replaces the positional parameters.
+	// The fields ".Entry", ".Attribute", ".Parameters" and ".DateTime" can be referenced
+	ArgsTemplate []string `yaml:"argsTemplate"`
+	// Go template for the body of the webhook replacing the JSON of the entry.
+	// See "ArgsTemplate" for the available fields
+	BodyTemplate string `yaml:"bodyTemplate"`
+}
+
+// IsExecutable returns whether a program or a webhook is configured to execute
+// the entries of this attribute
+func (o AttributeOptions) IsExecutable() bool {
+	return o.Program != "" || o.Webhook != ""
 }
 
 // LoggerConfig is used to customize the logging output and behaviour
@@ -58,6 +98,61 @@ type LoggerConfig struct {
 	PrintLevel string `yaml:"printLevel"`
 	WriteLevel string `yaml:"logLevel"`
 	LogPath    string `yaml:"logPath"`
+
+	// Print levels for specific modules overwriting "PrintLevel". The level
+	// of the log file is not affected
+	WebSocketLevel string `yaml:"websocket"`
+	ExecutionLevel string `yaml:"execution"`
+
+	// Interval in which a status line (e.g. the number of cached entries) is logged
+	// when running as a service. Zero disables the status line
+	StatusInterval time.Duration `yaml:"statusInterval"`
+}
+
+// WebSocketLogger returns the logger to use for the WebSocket. If no specific level
+// was configured, nil is returned so that the global logger is used
+func (c *LoggerConfig) WebSocketLogger() *logger.Logger {
+	return newModuleLogger(c.WebSocketLevel)
+}
+
+// ExecutionLogger returns the logger to use for the scheduling and execution of
+// entries. If no specific level was configured, nil is returned so that the global
+// logger is used
+func (c *LoggerConfig) ExecutionLogger() *logger.Logger {
+	return newModuleLogger(c.ExecutionLevel)
+}
+
+// newModuleLogger derives a logger with the given print level from the global logger.
+// The log file of the global logger is shared
+func newModuleLogger(level string) *logger.Logger {
+	if level == "" {
+		return nil
+	}
+
+	global := logger.GetGlobalLogger()
+	return logger.NewLoggerWithFile(
+		&logger.Logger{
+			Level: logger.GetLevelByName(level),
+			File: &logger.FileLogger{
+				Level: global.File.Level,
+			},
+			ColoredOutput: global.ColoredOutput,
+		}, global,
+	)
+}
+
+// StartupConfig controls the retries of the initial start of the persistence
+// layer when running as a service
+type StartupConfig struct {
+	// Maximum number of attempts to start the persistence layer. Zero means unlimited
+	MaxAttempts int `yaml:"maxAttempts"`
+
+	// Maximum duration in which failed starts are retried. Defaulting to one hour
+	MaxDuration time.Duration `yaml:"maxDuration"`
+
+	// Maximum age of past entries with the flag "execute always" that are still executed.
+	// By default, all past entries are executed
+	MaxCatchUpAge time.Duration `yaml:"maxCatchUpAge"`
 }
 
 // RuntimeOptions containes options specified via the CLI that are required for
@@ -74,6 +169,12 @@ type RuntimeOptions struct {
 
 	// Printing raw data instead of a user-friendly message
 	Quiet bool `cli:"--quiet,-q,~~~"`
+
+	// Printing the JSON output in a single line without indentation
+	JSONCompact bool `cli:"--json-compact,-jc,~~~"`
+
+	// Exit the program on misconfigurations that would otherwise only be logged as a warning
+	Strict bool `cli:"--strict,-st,~~~"`
 }
 
 func (o *RuntimeOptions) SetService() string {
@@ -86,6 +187,16 @@ func (o *RuntimeOptions) SetQuiet() string {
 	return ""
 }
 
+func (o *RuntimeOptions) SetStrict() string {
+	o.Strict = true
+	return ""
+}
+
+func (o *RuntimeOptions) SetJSONCompact() string {
+	o.JSONCompact = true
+	return ""
+}
+
 func (o *RuntimeOptions) SetOneShot(value string) string {
 	// Try to parse the string to a valid time.Duration
 	d, err := time.ParseDuration(value)
@@ -185,19 +296,17 @@ func (conf *AppConfig) SetDefaults() {
 	if conf.LoggerConfig.WriteLevel == "" {
 		conf.LoggerConfig.WriteLevel = "warning"
 	}
+
+	// Startup retries
+	if conf.StartupConfig.MaxDuration == 0 {
+		conf.StartupConfig.MaxDuration = time.Hour
+	}
 }
 
 // Validate validates if this Appconfiguration is valid.
-// When an error is found, it will be returned
+// All found problems are returned joined as a single error. Only an invalid API key
+// file is returned immediately
 func (conf *AppConfig) Validate() error {
-
-	// Validate required fields in 'AttributeOptions'
-	for _, opt := range conf.AttributeConfig {
-		if opt.Name == "" && opt.Id == 0 {
-			return fmt.Errorf("for each attribute an id or name is required")
-		}
-	}
-
 	// Validate and read the JWT key path
 	if conf.UserConfig.ApiKeyFile != "" {
 		if cnt, err := os.ReadFile(conf.UserConfig.ApiKeyFile); err != nil {
@@ -209,7 +318,84 @@ func (conf *AppConfig) Validate() error {
 		}
 	}
 
-	return nil
+	var errs []error
+
+	// Validate the API key from the configuration
+	if conf.UserConfig.ApiKey != "" && len(conf.UserConfig.ApiKey) != 64 {
+		errs = append(errs, fmt.Errorf("got invalid api key. The key should be exactly 64 characters long. Got %d", len(conf.UserConfig.ApiKey)))
+	}
+
+	// Validate required fields in 'AttributeOptions'
+	for _, opt := range conf.AttributeConfig {
+		if opt.Name == "" && opt.Id == 0 {
+			errs = append(errs, fmt.Errorf("for each attribute an id or name is required"))
+		}
+		if opt.DefaultOffset != "" {
+			if _, err := mod.NormalizeOffset(opt.DefaultOffset); err != nil {
+				errs = append(errs, fmt.Errorf("invalid default offset for attribute %q (#%d): %s", opt.Name, opt.Id, err))
+			}
+		}
+
+		if err := validateURL(opt.Webhook, "http", "https"); err != nil {
+			errs = append(errs, fmt.Errorf("invalid webhook for attribute %q (#%d): %s", opt.Name, opt.Id, err))
+		}
+
+		for _, tmpl := range append([]string{opt.BodyTemplate}, opt.ArgsTemplate...) {
+			if _, err := template.New("").Parse(tmpl); err != nil {
+				errs = append(errs, fmt.Errorf("invalid template for attribute %q (#%d): %s", opt.Name, opt.Id, err))
+			}
+		}
+
+		for _, program := range []string{opt.Program, opt.OnDeleteProgram} {
+			if program == "" {
+				continue
+			}
+			if _, err := exec.LookPath(program); err != nil {
+				errs = append(errs, fmt.Errorf("the program %q of the attribute %q (#%d) was not found: %s", program, opt.Name, opt.Id, err))
+			}
+		}
+	}
+
+	// Validate startup options
+	if conf.StartupConfig.MaxAttempts < 0 || conf.StartupConfig.MaxDuration < 0 {
+		errs = append(errs, fmt.Errorf("the startup options 'maxAttempts' and 'maxDuration' must not be negative"))
+	}
+
+	// Validate URLs
+	if err := validateURL(conf.UserConfig.BaseURL, "http", "https"); err != nil {
+		errs = append(errs, fmt.Errorf("invalid 'baseURL': %s", err))
+	}
+	if err := validateURL(conf.UserConfig.SocketURL, "ws", "wss"); err != nil {
+		errs = append(errs, fmt.Errorf("invalid 'socketURL': %s", err))
+	}
+
+	// Validate WebSocket options
+	if conf.UserConfig.SocketDialTimeout < 0 {
+		errs = append(errs, fmt.Errorf("the option 'socketDialTimeout' must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateURL validates that the given URL is absolute and uses one of the
+// given schemes. An empty URL is valid
+func validateURL(value string, schemes ...string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	for _, scheme := range schemes {
+		if u.Scheme == scheme && u.Host != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("expected an absolute URL with the scheme %s. Got %q", strings.Join(schemes, " or "), value)
 }
 
 // ToApiOptions is an adapter function to convert this abstract application configuration
@@ -225,7 +411,9 @@ func (c *AppConfig) ToApiOptions() api.ApiOptions {
 // to websocket options
 func (c *AppConfig) ToWebsocketOptions() persistence.WebSocket {
 	return persistence.WebSocket{
-		UseWebsocket: true,
+		UseWebsocket: c.UserConfig.UseWebsocket == nil || *c.UserConfig.UseWebsocket,
 		SocketURL:    c.UserConfig.SocketURL,
+		DialTimeout:  c.UserConfig.SocketDialTimeout,
+		Logger:       c.LoggerConfig.WebSocketLogger(),
 	}
 }
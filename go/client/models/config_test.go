@@ -0,0 +1,77 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to get the executable: %s", err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+	validKey := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name string
+		conf AppConfig
+
+		// Substrings of the problems that are expected to be reported
+		want []string
+	}{
+		{name: "valid", conf: AppConfig{UserConfig: UserConfig{ApiKey: validKey}}},
+		{
+			name: "existing programs",
+			conf: AppConfig{AttributeConfig: []AttributeOptions{{Name: "a", Program: executable, OnDeleteProgram: executable}}},
+		},
+		{
+			name: "missing programs",
+			conf: AppConfig{AttributeConfig: []AttributeOptions{{Name: "a", Program: missing, OnDeleteProgram: missing + "-delete"}}},
+			want: []string{`"` + missing + `"`, `"` + missing + `-delete"`},
+		},
+		{
+			name: "multiple problems",
+			conf: AppConfig{
+				UserConfig:      UserConfig{ApiKey: "short", BaseURL: "rpdb.de", SocketDialTimeout: -1},
+				AttributeConfig: []AttributeOptions{{Program: missing}},
+			},
+			want: []string{"invalid api key", "'baseURL'", "'socketDialTimeout'", "id or name is required", `"` + missing + `"`},
+		},
+		{
+			name: "invalid api key file",
+			conf: AppConfig{
+				UserConfig:      UserConfig{ApiKeyFile: missing, BaseURL: "rpdb.de"},
+				AttributeConfig: []AttributeOptions{{Program: missing}},
+			},
+			want: []string{"failed to read api key from file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conf.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() returned an error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() returned no error, want %q", tt.want)
+			}
+
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tt.want) {
+				t.Errorf("Validate() reported %d problems, want %d:\n%s", len(problems), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"net/http"
 	"os/exec"
 	"sync"
+	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/client/models"
 	mod "github.com/RPJoshL/RPdb/v4/go/models"
@@ -22,12 +24,66 @@ type ProgramExecutor struct {
 
 	// Mutex to sync the execution
 	Mutex *sync.Mutex
+
+	// Context of the executor. Running webhooks of the default execution are canceled
+	// when the context is done. Defaulting to context.Background()
+	Context context.Context
+
+	// HTTP client used to call the webhooks. The time to wait for a response is limited
+	// by the context of the execution, so the client shouldn't have its own timeout.
+	// Defaulting to http.DefaultClient
+	Client *http.Client
+
+	// Currently running executions
+	running sync.WaitGroup
+
+	// Whether the executions are only simulated (e.g. for testing the configured
+	// programs). The programs are still called, but all log messages are labeled
+	// as a simulation
+	Simulation bool
+
+	// Logger to use for the executions. Defaulting to the global logger
+	Logger *logger.Logger
+
+	// Maximum size in bytes of the response text of an execution. Longer
+	// outputs are truncated. Defaulting to [DefaultMaxResponseBytes]
+	MaxResponseBytes int
 }
 
-// Execute calls a program defined in the attribute options
+// logPrefix returns a prefix for the log messages to differentiate simulated executions
+func (e *ProgramExecutor) logPrefix() string {
+	if e.Simulation {
+		return "[Simulation] "
+	}
+
+	return ""
+}
+
+// context returns the context of the executor
+func (e *ProgramExecutor) context() context.Context {
+	if e.Context != nil {
+		return e.Context
+	}
+
+	return context.Background()
+}
+
+// httpClient returns the HTTP client to call the webhooks with
+func (e *ProgramExecutor) httpClient() *http.Client {
+	if e.Client != nil {
+		return e.Client
+	}
+
+	return http.DefaultClient
+}
+
+// Execute calls a program defined in the attribute options.
+// For the default execution the webhook of the attribute is also called.
+// The webhook is called without holding the mutex, so that a slow webhook
+// doesn't block other executions
 func (e *ProgramExecutor) Execute(ent mod.Entry, typ persistence.ExecutionType) {
-	e.Mutex.Lock()
-	defer e.Mutex.Unlock()
+	e.running.Add(1)
+	defer e.running.Done()
 
 	// Get the attribute to execute
 	attr, doesExist := e.Attributes[ent.Attribute.ID]
@@ -35,108 +91,204 @@ func (e *ProgramExecutor) Execute(ent mod.Entry, typ persistence.ExecutionType)
 		return
 	}
 	program := ""
+	webhook := ""
 	logMessage := ""
 	switch typ {
 	case persistence.DEFAULT:
 		program = attr.Program
+		webhook = attr.Webhook
 		logMessage = "Executing entry"
 	case persistence.DELETE:
 		program = attr.OnDeleteProgram
 		logMessage = "Executing delete hook for entry"
 	default:
-		logger.Warning("Received unknown execution type: %q", typ)
+		e.log().Warning("Received unknown execution type: %q", typ)
 	}
 
 	// Nothing to execute
-	if program == "" {
+	if program == "" && webhook == "" {
 		return
 	}
 
-	logger.Info("%s %s with attribute %q (#%d)", logMessage, ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
+	e.log().Info("%s%s %s with attribute %q (#%d)", e.logPrefix(), logMessage, ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
 
-	// Get the CLI parameters
-	params := e.getParameters(&ent, attr)
+	if program != "" {
+		e.Mutex.Lock()
+
+		// Get the CLI parameters and call the programm detached from its process
+		if params, err := e.getParameters(&ent, attr); err != nil {
+			e.log().Warning("Failed to render the arguments for %q: %s", program, err)
+		} else if err := e.startProgramm(program, params); err != nil {
+			e.log().Warning("Failed to start %q: %s", program, err)
+		}
 
-	// Call the programm and detach its process
-	if err := e.startProgramm(program, params); err != nil {
-		logger.Warning("Failed to start %q: %s", attr.Program, err)
+		e.Mutex.Unlock()
+	}
+
+	if webhook != "" {
+		if res := e.callWebhook(e.context(), &ent, attr); res.Code != 0 {
+			e.log().Warning("Webhook %q returned the code %d: %s", webhook, res.Code, res.Text)
+		}
 	}
 }
 
 // ExecuteResponse calls a program defined in the attribute options and returns
 // the exeuction response.
-// Therefore, this method does block until the program was executed
-func (e *ProgramExecutor) ExecuteResponse(ent mod.Entry) (rtc *mod.ExecutionResponse) {
-	e.Mutex.Lock()
-	defer e.Mutex.Unlock()
+// Therefore, this method does block until the program was executed or the timeout of
+// the entry (see [persistence.ExecResponseTimeout]) was exceeded.
+// If only a webhook is configured, the response of the webhook is returned
+func (e *ProgramExecutor) ExecuteResponse(ent mod.Entry) *mod.ExecutionResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), persistence.ExecResponseTimeout(&ent))
+	defer cancel()
 
-	rtc = &mod.ExecutionResponse{
-		EntryId: ent.ID,
-	}
+	return e.ExecuteResponseContext(ctx, ent)
+}
+
+// ExecuteResponseContext is like [ProgramExecutor.ExecuteResponse] but kills the
+// program when the given context is done instead of using the timeout of the entry.
+// When the deadline of the context was exceeded, the code [persistence.ExecResponseTimeoutCode]
+// is returned
+func (e *ProgramExecutor) ExecuteResponseContext(ctx context.Context, ent mod.Entry) (rtc *mod.ExecutionResponse) {
+	e.running.Add(1)
+	defer e.running.Done()
+
+	rtc = mod.NewExecutionResponse(ent.ID, 0, "")
 
 	// Get the attribute to execute
 	attr, doesExist := e.Attributes[ent.Attribute.ID]
-	if !doesExist || attr.Program == "" {
+	if !doesExist || (attr.Program == "" && attr.Webhook == "") {
 		return nil
 	}
 
-	logger.Info("Executing entry %s (#%d) and returning response", ent.DateTime.FormatPretty(), ent.ID)
+	e.log().Info("%sExecuting entry %s (#%d) and returning response", e.logPrefix(), ent.DateTime.FormatPretty(), ent.ID)
 
-	// Get the CLI parameters
-	params := e.getParameters(&ent, attr)
+	// The response of a program takes precedence
+	if attr.Program == "" {
+		return e.callWebhook(ctx, &ent, attr)
+	}
 
-	// Call the program (in foreground) and return response
-	cmd := exec.Command(attr.Program, params...)
-	// Combine stdout and stderr
-	cmdReader, err := cmd.StdoutPipe()
+	// Like in "Execute()", only the program is executed while holding the mutex
+	e.Mutex.Lock()
+	defer e.Mutex.Unlock()
+
+	// Get the CLI parameters
+	params, err := e.getParameters(&ent, attr)
 	if err != nil {
-		logger.Warning(err.Error())
+		e.log().Warning("Failed to render the arguments for %q: %s", attr.Program, err)
+		rtc.Text = err.Error()
+		rtc.Code = -1
+		return
 	}
-	cmd.Stderr = cmd.Stdout
-	defer cmdReader.Close()
 
-	// Function to read the combined output
-	go func() {
-		outCombined, err := io.ReadAll(cmdReader)
-		if err != nil {
-			logger.Warning("Failed to read output from program %q: %s", attr.Program, err)
-		}
-		rtc.Text = string(outCombined)
-	}()
+	// Call the program (in foreground) and return response
+	cmd := exec.CommandContext(ctx, attr.Program, params...)
+	// Combine stdout and stderr
+	output := e.newResponseBuffer()
+	cmd.Stdout = output
+	cmd.Stderr = output
 
 	// Execute it
 	err = cmd.Run()
+	rtc.Text = output.String()
+	e.logTruncation(output, attr.Program)
 
 	// If a non-zero return code was returned, an error is returned in go
 	if err != nil {
 		if werr, ok := err.(*exec.ExitError); ok {
 			rtc.Code = werr.ExitCode()
 		} else {
-			logger.Warning("Error during execution of program %q: %s", attr.Program, err)
+			e.log().Warning("Error during execution of program %q: %s", attr.Program, err)
 			rtc.Text += err.Error()
 			rtc.Code = -1
 		}
 	}
 
+	// The program was killed because the timeout was exceeded
+	if ctx.Err() == context.DeadlineExceeded {
+		rtc.Code = persistence.ExecResponseTimeoutCode
+	}
+
 	return
 }
 
-// getParameters returns a list of parameters that should be used to call the program
-func (e *ProgramExecutor) getParameters(ent *mod.Entry, attr models.AttributeOptions) []string {
+// Wait blocks until all currently running executions are finished or the
+// given timeout was reached. If the timeout was reached, false is returned
+func (e *ProgramExecutor) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		e.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// GetParametersPreview returns the program and the arguments that would be used to
+// execute the given entry with the given execution type, without running anything
+func (e *ProgramExecutor) GetParametersPreview(ent mod.Entry, typ persistence.ExecutionType) (string, []string, error) {
+	if ent.Attribute == nil {
+		return "", nil, fmt.Errorf("the attribute of the entry #%d is not resolved", ent.ID)
+	}
+
+	attr, doesExist := e.Attributes[ent.Attribute.ID]
+	if !doesExist {
+		return "", nil, fmt.Errorf("no options are configured for the attribute %q", ent.Attribute.Name)
+	}
+
+	program := attr.Program
+	if typ == persistence.DELETE {
+		program = attr.OnDeleteProgram
+	}
+	if program == "" {
+		return "", nil, fmt.Errorf("no program is configured for the attribute %q", ent.Attribute.Name)
+	}
+
+	params, err := e.getParameters(&ent, attr)
+	return program, params, err
+}
+
+// getParameters returns a list of parameters that should be used to call the program.
+// If a template for the arguments is configured, the rendered template is returned
+func (e *ProgramExecutor) getParameters(ent *mod.Entry, attr models.AttributeOptions) ([]string, error) {
 	// Build dynamic parameters
-	parameters := make([]string, len(ent.Parameters))
-	for i, p := range ent.Parameters {
-		parameters[i] = p.GetValue(ent.Attribute)
+	parameters := parameterValues(ent)
+
+	if len(attr.ArgsTemplate) > 0 {
+		return renderArgsTemplate(attr.ArgsTemplate, newTemplateData(ent, parameters))
 	}
 
 	// Only call the program with the parameters with entries detail
 	if attr.PassOnlyParameter {
-		return parameters
+		return parameters, nil
 	}
 
 	return append(parameters, []string{
 		ent.DateTime.Format(mod.TimeFormat),
 		ent.Attribute.Name,
 		fmt.Sprintf("%d", ent.ID),
-	}...)
+	}...), nil
+}
+
+// parameterValues returns the values of the parameters of the given entry
+func parameterValues(ent *mod.Entry) []string {
+	parameters := make([]string, len(ent.Parameters))
+	for i, p := range ent.Parameters {
+		parameters[i] = p.GetValue(ent.Attribute)
+	}
+
+	return parameters
+}
+
+// log returns the logger to use for the executions
+func (e *ProgramExecutor) log() *logger.Logger {
+	if e.Logger != nil {
+		return e.Logger
+	}
+
+	return logger.GetGlobalLogger()
 }
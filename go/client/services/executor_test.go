@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+	"github.com/RPJoshL/RPdb/v4/go/persistence"
+)
+
+func TestExecuteWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the context of the executor is canceled while the webhook is running
+		cancel bool
+	}{
+		{name: "finished"},
+		{name: "canceled", cancel: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan struct{})
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(received)
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			e := &ProgramExecutor{
+				Attributes: map[int]models.AttributeOptions{1: {Id: 1, Webhook: srv.URL}},
+				Mutex:      &sync.Mutex{},
+				Context:    ctx,
+			}
+
+			done := make(chan struct{})
+			go func() {
+				e.Execute(mod.Entry{ID: 1, Attribute: &mod.Attribute{ID: 1, Name: "a"}}, persistence.DEFAULT)
+				close(done)
+			}()
+			<-received
+
+			// The mutex is not held while the webhook is running
+			locked := make(chan struct{})
+			go func() {
+				e.Mutex.Lock()
+				e.Mutex.Unlock()
+				close(locked)
+			}()
+			select {
+			case <-locked:
+			case <-time.After(time.Second):
+				t.Fatalf("Mutex is held while the webhook is running")
+			}
+
+			if tt.cancel {
+				cancel()
+			} else {
+				release <- struct{}{}
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("Execute() didn't return")
+			}
+			if !e.Wait(time.Second) {
+				t.Errorf("Wait() reported running executions")
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestExecuteResponseWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the webhook responds only after the request was canceled
+		block bool
+
+		wantCode int
+		wantText string
+	}{
+		{name: "finished", wantText: "done"},
+		{name: "timeout of the entry exceeded", block: true, wantCode: persistence.ExecResponseTimeoutCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.block {
+					select {
+					case <-release:
+					case <-r.Context().Done():
+					}
+					return
+				}
+				w.Write([]byte("done"))
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			transport := &countingTransport{}
+			e := &ProgramExecutor{
+				Attributes: map[int]models.AttributeOptions{1: {Id: 1, Webhook: srv.URL}},
+				Mutex:      &sync.Mutex{},
+				Client:     &http.Client{Transport: transport},
+			}
+
+			// The mutex is not needed for calling the webhook
+			e.Mutex.Lock()
+			defer e.Mutex.Unlock()
+
+			// The context has no deadline, so that the timeout of the entry is used
+			start := time.Now()
+			res := e.ExecuteResponseContext(context.Background(), mod.Entry{ID: 1, Attribute: &mod.Attribute{ID: 1, Name: "a"}, Timeout: mod.NullInt{Int32: 1, Valid: true}})
+			if took := time.Since(start); took > 3*time.Second {
+				t.Errorf("ExecuteResponseContext() took %s, want at most the timeout of the entry", took)
+			}
+
+			if res == nil || res.Code != tt.wantCode || (tt.wantText != "" && res.Text != tt.wantText) {
+				t.Errorf("ExecuteResponseContext() = %+v, want (%d, %q)", res, tt.wantCode, tt.wantText)
+			}
+			if got := transport.requests.Load(); got != 1 {
+				t.Errorf("Client sent %d requests, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+	"github.com/RPJoshL/RPdb/v4/go/persistence"
+)
+
+// newTestProgram writes a shell script with the given content and returns its path
+func newTestProgram(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "program.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write the program: %s", err)
+	}
+
+	return path
+}
+
+func TestExecuteResponseTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		timeout mod.NullInt
+
+		wantCode int
+		wantText string
+		// Maximum duration of the execution
+		wantMax time.Duration
+	}{
+		{name: "finished", script: "echo done", timeout: mod.NullInt{Int32: 1, Valid: true}, wantText: "done\n", wantMax: time.Second},
+		{name: "exit code", script: "echo failed; exit 3", wantCode: 3, wantText: "failed\n", wantMax: time.Second},
+		{
+			name:     "timeout exceeded",
+			script:   "exec sleep 10",
+			timeout:  mod.NullInt{Int32: 1, Valid: true},
+			wantCode: persistence.ExecResponseTimeoutCode,
+			wantMax:  5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &ProgramExecutor{
+				Attributes: map[int]models.AttributeOptions{1: {Id: 1, Program: newTestProgram(t, tt.script)}},
+				Mutex:      &sync.Mutex{},
+			}
+
+			start := time.Now()
+			res := e.ExecuteResponse(mod.Entry{ID: 1, Attribute: &mod.Attribute{ID: 1, Name: "a"}, Timeout: tt.timeout})
+			if took := time.Since(start); took > tt.wantMax {
+				t.Errorf("ExecuteResponse() took %s, want at most %s", took, tt.wantMax)
+			}
+
+			if res == nil {
+				t.Fatalf("ExecuteResponse() returned no response")
+			}
+			if res.Code != tt.wantCode || res.Text != tt.wantText {
+				t.Errorf("ExecuteResponse() = (%d, %q), want (%d, %q)", res.Code, res.Text, tt.wantCode, tt.wantText)
+			}
+		})
+	}
+}
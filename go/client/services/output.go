@@ -0,0 +1,60 @@
+package service
+
+import "bytes"
+
+// DefaultMaxResponseBytes is the default maximum size of the text of an execution response
+const DefaultMaxResponseBytes = 64 * 1024
+
+// Indicator appended to a truncated execution response
+const truncatedIndicator = "\n[... output truncated]"
+
+// limitedBuffer is a writer that keeps only the first max bytes of the written data.
+// All further data is discarded without returning an error, so the program
+// that writes the output isn't terminated
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// String returns the buffered output with an indicator if the output was truncated
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + truncatedIndicator
+	}
+
+	return b.buf.String()
+}
+
+// maxResponseBytes returns the maximum size of an execution response
+func (e *ProgramExecutor) maxResponseBytes() int {
+	if e.MaxResponseBytes > 0 {
+		return e.MaxResponseBytes
+	}
+
+	return DefaultMaxResponseBytes
+}
+
+// newResponseBuffer returns a buffer for capturing the output of an execution
+func (e *ProgramExecutor) newResponseBuffer() *limitedBuffer {
+	return &limitedBuffer{max: e.maxResponseBytes()}
+}
+
+// logTruncation logs a message if the output of the given source was truncated
+func (e *ProgramExecutor) logTruncation(b *limitedBuffer, source string) {
+	if b.truncated {
+		e.log().Info("Truncated the response of %q to %d bytes", source, b.max)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   string
+	}{
+		{name: "within the limit", max: 10, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "exactly the limit", max: 6, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "exceeding write", max: 4, writes: []string{"abc", "def"}, want: "abcd" + truncatedIndicator},
+		{name: "writes after the limit", max: 3, writes: []string{"abc", "def", "ghi"}, want: "abc" + truncatedIndicator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &limitedBuffer{max: tt.max}
+			for _, w := range tt.writes {
+				// The writer must never fail, so that the program isn't terminated
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Errorf("Write() = (%d, %v), want (%d, nil)", n, err, len(w))
+				}
+			}
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteResponseTruncated(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name             string
+		maxResponseBytes int
+		want             string
+	}{
+		{name: "default limit", want: body},
+		{name: "exceeding the limit", maxResponseBytes: 10, want: body[:10] + truncatedIndicator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &ProgramExecutor{
+				Attributes:       map[int]models.AttributeOptions{1: {Id: 1, Webhook: srv.URL}},
+				Mutex:            &sync.Mutex{},
+				MaxResponseBytes: tt.maxResponseBytes,
+			}
+
+			res := e.ExecuteResponseContext(context.Background(), mod.Entry{ID: 1, Attribute: &mod.Attribute{ID: 1, Name: "a"}})
+			if res == nil || res.Text != tt.want {
+				t.Errorf("ExecuteResponseContext() = %+v, want the text %q", res, tt.want)
+			}
+		})
+	}
+}
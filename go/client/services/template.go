@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strings"
+	"text/template"
+
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// templateData contains the data that can be referenced within the
+// argument and body templates of an attribute
+type templateData struct {
+	Entry     *mod.Entry
+	Attribute *mod.Attribute
+
+	// The resolved values of the parameters
+	Parameters []string
+
+	// The date time of the entry formatted with [mod.TimeFormat]
+	DateTime string
+}
+
+func newTemplateData(ent *mod.Entry, parameters []string) templateData {
+	return templateData{
+		Entry:      ent,
+		Attribute:  ent.Attribute,
+		Parameters: parameters,
+		DateTime:   ent.DateTime.Format(mod.TimeFormat),
+	}
+}
+
+// renderTemplate renders the given template text with the given data
+func renderTemplate(text string, data templateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// renderArgsTemplate renders every template of the given list to a single argument
+func renderArgsTemplate(templates []string, data templateData) ([]string, error) {
+	rtc := make([]string, len(templates))
+	for i, t := range templates {
+		arg, err := renderTemplate(t, data)
+		if err != nil {
+			return nil, err
+		}
+		rtc[i] = arg
+	}
+
+	return rtc, nil
+}
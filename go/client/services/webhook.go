@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+	"github.com/RPJoshL/RPdb/v4/go/persistence"
+)
+
+// callWebhook sends the given entry as JSON (or the rendered body template) to the webhook of the attribute and returns
+// the response of it. A status code outside of 2xx is returned as the response code.
+// This method does block until the webhook responded, the given context is done or the
+// timeout of the entry (see [persistence.ExecResponseTimeout]) was exceeded
+func (e *ProgramExecutor) callWebhook(ctx context.Context, ent *mod.Entry, attr models.AttributeOptions) *mod.ExecutionResponse {
+	rtc := mod.NewExecutionResponse(ent.ID, 0, "")
+
+	ctx, cancel := context.WithTimeout(ctx, persistence.ExecResponseTimeout(ent))
+	defer cancel()
+
+	var body []byte
+	var err error
+	if attr.BodyTemplate != "" {
+		var rendered string
+		rendered, err = renderTemplate(attr.BodyTemplate, newTemplateData(ent, parameterValues(ent)))
+		body = []byte(rendered)
+	} else {
+		body, err = json.Marshal(ent)
+	}
+	if err != nil {
+		return e.webhookError(rtc, attr, err)
+	}
+
+	method := attr.WebhookMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, attr.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return e.webhookError(rtc, attr, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range attr.WebhookHeaders {
+		req.Header.Set(key, value)
+	}
+
+	res, err := e.httpClient().Do(req)
+	if err != nil {
+		e.webhookError(rtc, attr, err)
+		if ctx.Err() == context.DeadlineExceeded {
+			rtc.Code = persistence.ExecResponseTimeoutCode
+		}
+		return rtc
+	}
+	defer res.Body.Close()
+
+	text := e.newResponseBuffer()
+	if _, err := io.Copy(text, res.Body); err != nil {
+		e.log().Warning("Failed to read response of webhook %q: %s", attr.Webhook, err)
+	}
+	rtc.Text = text.String()
+	e.logTruncation(text, attr.Webhook)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		rtc.Code = res.StatusCode
+	}
+
+	return rtc
+}
+
+// webhookError logs the given error and adds it to the execution response
+func (e *ProgramExecutor) webhookError(rtc *mod.ExecutionResponse, attr models.AttributeOptions, err error) *mod.ExecutionResponse {
+	e.log().Warning("Failed to call webhook %q: %s", attr.Webhook, err)
+	rtc.Text = err.Error()
+	rtc.Code = -1
+
+	return rtc
+}
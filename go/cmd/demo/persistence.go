@@ -27,6 +27,14 @@ func runPersistence(pers *persistence.Persistence) {
 		}
 	}()
 
+	// Live view of the entries of a single attribute
+	if attr, err := pers.GetAttributeByName("Sensor"); err == nil {
+		unsubscribe := pers.WatchAttribute(attr.ID, func(entries []*models.Entry) {
+			logger.Info("Entries of the attribute %q: %s", attr.Name, entries)
+		})
+		defer unsubscribe()
+	}
+
 	// Entries with the type "no_db"
 	if attrNoDb, err := pers.GetAttributeByName("Keine DB"); err == nil {
 
@@ -90,5 +98,5 @@ func Execute(ent models.Entry, typ persistence.ExecutionType) {
 
 func ExecuteWithResponse(ent models.Entry) *models.ExecutionResponse {
 	logger.Debug("Oh no. I have to return a response :/")
-	return &models.ExecutionResponse{EntryId: ent.ID, Code: 0, Text: "This was successful"}
+	return models.NewExecutionResponse(ent.ID, 0, "This was successful")
 }
@@ -1,6 +1,7 @@
 package args
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -9,14 +10,19 @@ import (
 
 // Attribute contains attribute options for the CLI
 type Attribute struct {
-	Disabled      bool
-	AttributeList AttributeList `cli:"list,l"`
+	Disabled          bool
+	AttributeList     AttributeList     `cli:"list,l"`
+	AttributePrograms AttributePrograms `cli:"programs,prog"`
 }
 
 type AttributeList struct {
-	IDs    string `cli:"--ids,-i"`
-	Name   string `cli:"--name,-n" completion:"GetAttributeNames"`
-	Format string `cli:"--output,-o" completion:"GetOutputFormats"`
+	IDs    string           `cli:"--ids,-i"`
+	Name   string           `cli:"--name,-n" completion:"GetAttributeNames"`
+	Format mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
+}
+
+func (al *AttributeList) SetFormat(value string) string {
+	return setOutputFormat(&al.Format, value)
 }
 
 // SetAttributeList lists all available attributes filtered by the specified fields
@@ -90,3 +96,67 @@ func (al *AttributeList) SetAttributeList(cli *Cli) string {
 func (al *Attribute) IsFieldDisabled() bool {
 	return al.Disabled
 }
+
+// AttributePrograms lists the attributes with the programs and webhooks that are
+// configured for them in the configuration file
+type AttributePrograms struct {
+	Format mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
+}
+
+func (ap *AttributePrograms) SetFormat(value string) string {
+	return setOutputFormat(&ap.Format, value)
+}
+
+// attributeProgram is an attribute joined with its configured executions
+type attributeProgram struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Program         string `json:"program"`
+	OnDeleteProgram string `json:"on_delete_program"`
+	Webhook         string `json:"webhook"`
+}
+
+func (a attributeProgram) String() string {
+	if a.Program == "" && a.OnDeleteProgram == "" && a.Webhook == "" {
+		return fmt.Sprintf("_______ %s (%d) _______\nOnly tracked\n", a.Name, a.ID)
+	}
+
+	return fmt.Sprintf(
+		`_______ %s (%d) _______
+Program:           %s
+On delete program: %s
+Webhook:           %s
+`, a.Name, a.ID, a.Program, a.OnDeleteProgram, a.Webhook)
+}
+
+func (a attributeProgram) ToSlice() []string {
+	return []string{fmt.Sprintf("%d", a.ID), a.Name, a.Program, a.OnDeleteProgram, a.Webhook}
+}
+
+func (a attributeProgram) Headers() []string {
+	return []string{"id", "name", "program", "on_delete_program", "webhook"}
+}
+
+// SetAttributePrograms lists all attributes with the programs and webhooks that
+// would be executed when running as a service
+func (ap *AttributePrograms) SetAttributePrograms(cli *Cli) string {
+	attributes, err := cli.GetApi().GetAttributes()
+	if err != nil {
+		return cli.PrintFatalError(err.Error())
+	}
+
+	var rtc []mod.Formattable
+	for _, a := range attributes {
+		prog := attributeProgram{ID: a.ID, Name: a.Name}
+		if opt := cli.GetAttributeOptions(a); opt != nil {
+			prog.Program = opt.Program
+			prog.OnDeleteProgram = opt.OnDeleteProgram
+			prog.Webhook = opt.Webhook
+		}
+
+		rtc = append(rtc, prog)
+	}
+
+	cli.PrintStructsFormatted(&rtc, ap.Format)
+	return ""
+}
@@ -22,6 +22,7 @@ func (a *Attribute) Help() string {
 Listing of all available attributes.
 
 list      l                  |Shows all available attributes 
+programs  prog               |Shows the programs and webhooks configured for the attributes
 
 |___________________________________________________________________________
 
@@ -31,8 +32,26 @@ Global options that can be used for all comamnds.
     `)
 }
 
+func (a *AttributePrograms) Help() string {
+	return `
+Lists all attributes with the programs and webhooks that are configured for
+them in the configuration file. Attributes without any configuration are only tracked.
+
+programs  prog               |Shows the programs and webhooks configured for the attributes
+|___________________________________________________________________________
+
+Global options that can be used for all comamnds.
+
+ --output  {format}  	|Output format to use. Available formats are 'pretty', 'json' and 'csv'
+`
+}
+
+func (a *AttributePrograms) GetOutputFormats(cli *Cli, input string) (rtc []string) {
+	return getOutputFormats()
+}
+
 func (a *AttributeList) GetOutputFormats(cli *Cli, input string) (rtc []string) {
-	return []string{"pretty", "csv", "json"}
+	return getOutputFormats()
 }
 
 func (a *AttributeList) GetAttributeNames(cli *Cli, input string) (rtc []string) {
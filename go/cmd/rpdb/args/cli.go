@@ -11,6 +11,7 @@ import (
 	"github.com/RPJoshL/RPdb/v4/go/client/models"
 	mod "github.com/RPJoshL/RPdb/v4/go/models"
 	"github.com/RPJoshL/RPdb/v4/go/pkg/cli"
+	"git.rpjosh.de/RPJosh/go-logger"
 )
 
 // Cli parameters that can be processed without having a concrete app configuration
@@ -29,17 +30,30 @@ type Cli struct {
 	// This field is not used! It's only there that the CLI parser won't throw an error
 	ConfigPath string `cli:"--config,-conf"`
 
+	// Additional options for the attributes from the configuration file
+	AttributeConfig []models.AttributeOptions
+
 	Version string `cli:"--version,-v,~~~"`
 
 	// Sub commands
 	Entry      *Entry      `cli:"entry,e"`
 	Attribute  *Attribute  `cli:"attribute,a"`
+	Ping       *Ping       `cli:"ping"`
+	SelfTest   *SelfTest   `cli:"selftest"`
 	Completion *Completion `cli:"completion,comp"`
 
 	// If the program is called in auto-completion mode
 	AutoComplete bool
+
+	// Output format to use when no format was given via "--output".
+	// It's read from the environment variable [OutputFormatEnv]
+	DefaultFormat mod.OutputFormat
 }
 
+// OutputFormatEnv is the name of the environment variable containing the default
+// output format for all commands. An explicitly given "--output" does take precedence
+const OutputFormatEnv = "RPDB_OUTPUT"
+
 func (cli *Cli) Help() string {
 	return (`
 Syntax: ProgramName [generic options] entry\|attribute [options]
@@ -50,10 +64,15 @@ Generic options (these has to be specified at the beginning and affects only the
   --multiInstance -mi             |Also notifies the currently used token on updates|. This is required when you are
                                   using the same API-Key multiple times locally (create + listen)
   --quiet         -q              |Instead of a user friendly message the raw data / no date will be printed.
+  --json-compact  -jc             |The JSON output is printed in a single line instead of being indented
+
+The environment variable RPDB_OUTPUT can contain the default output format for all commands.
+An explicitly given '--output' does take precedence.
 
   --service       -s              |Runs this program infinite to execute scheduled entries
   --oneShot       -os   {time}    |The program will be exited, when no entries in the next {time} are available.
                                   |The time will be reset after an entry was executed. Example: '3h', '1h10m'
+  --strict        -st             |Exits the service on misconfigurations instead of logging a warning
   --version       -v              |Prints the version of the application
 |_________________________________________________________________________________________________________
 
@@ -62,6 +81,8 @@ For example: ProgramName entry --help
 
   entry      e     |Schedule and manage the execution of entries
   attribute  a     |List all available attributes
+  ping             |Checks if the server is reachable and the API key is valid
+  selftest         |Creates, reads and deletes a test entry to verify the full round-trip
   completion comp  |Output shell completion code for the specified shell| (only bash is supproted currently)
 	`)
 }
@@ -71,12 +92,23 @@ func (cli *Cli) EnableAutoComplete() {
 }
 
 func ParseArgs(config *models.AppConfig, args []string) error {
+	// Read the default output format from the environment
+	defaultFormat, err := mod.ParseOutputFormat(os.Getenv(OutputFormatEnv))
+	if err != nil {
+		logger.Error("Invalid value of the environment variable %s: %s", OutputFormatEnv, err)
+		return err
+	}
+
 	cl := &Cli{
-		UserConfig:     &config.UserConfig,
-		RuntimeOptions: &config.RuntimeOptions,
-		Entry:          &Entry{},
-		Attribute:      &Attribute{},
-		Completion:     &Completion{},
+		UserConfig:      &config.UserConfig,
+		RuntimeOptions:  &config.RuntimeOptions,
+		AttributeConfig: config.AttributeConfig,
+		DefaultFormat:   defaultFormat,
+		Entry:           &Entry{},
+		Attribute:       &Attribute{},
+		Ping:            &Ping{},
+		SelfTest:        &SelfTest{},
+		Completion:      &Completion{},
 	}
 
 	if cli.ParseParams(args, cl) < 0 {
@@ -94,6 +126,8 @@ func ParseAnonymousArgs(args []string) error {
 		RuntimeOptions: &models.RuntimeOptions{},
 		Entry:          &Entry{Disabled: true},
 		Attribute:      &Attribute{Disabled: true},
+		Ping:           &Ping{Disabled: true},
+		SelfTest:       &SelfTest{Disabled: true},
 		Completion:     &Completion{},
 	}
 
@@ -110,6 +144,18 @@ func (cli *Cli) SetVersion() string {
 	return ""
 }
 
+// GetAttributeOptions returns the options of the configuration file for the given
+// attribute. If no options were configured, nil is returned
+func (cli *Cli) GetAttributeOptions(attr *mod.Attribute) *models.AttributeOptions {
+	for i, opt := range cli.AttributeConfig {
+		if (opt.Id != 0 && opt.Id == attr.ID) || (opt.Id == 0 && opt.Name == attr.Name) {
+			return &cli.AttributeConfig[i]
+		}
+	}
+
+	return nil
+}
+
 // PrintFatalError prints the given message and exits eventually the program
 func (cli *Cli) PrintFatalError(message string) string {
 
@@ -150,15 +196,15 @@ func (cli *Cli) GetApi() api.Apiler {
 	)
 }
 
-func (cli *Cli) PrintStructFormatted(str mod.Formattable, format string) {
-	switch strings.ToUpper(format) {
-	case "PRETTY", "":
+func (cli *Cli) PrintStructFormatted(str mod.Formattable, format mod.OutputFormat) {
+	format = cli.outputFormat(format)
+	switch format {
+	case mod.FormatPretty, "":
 		fmt.Println(str.String())
-	case "JSON":
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
+	case mod.FormatJSON:
+		enc := cli.newJSONEncoder()
 		enc.Encode(str)
-	case "CSV":
+	case mod.FormatCSV:
 		w := csv.NewWriter(os.Stdout)
 		w.Write(str.ToSlice())
 		w.Flush()
@@ -167,17 +213,55 @@ func (cli *Cli) PrintStructFormatted(str mod.Formattable, format string) {
 	}
 }
 
-func (cli *Cli) PrintStructsFormatted(structs *[]mod.Formattable, format string) {
-	switch strings.ToUpper(format) {
-	case "PRETTY", "", "CSV":
+func (cli *Cli) PrintStructsFormatted(structs *[]mod.Formattable, format mod.OutputFormat) {
+	format = cli.outputFormat(format)
+	switch format {
+	case mod.FormatPretty, "", mod.FormatCSV:
 		for _, a := range *structs {
 			cli.PrintStructFormatted(a, format)
 		}
-	case "JSON":
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
+	case mod.FormatJSON:
+		enc := cli.newJSONEncoder()
 		enc.Encode(structs)
 	default:
 		cli.PrintFatalErrorf("Invalid format given: %q", format)
 	}
 }
+
+// newJSONEncoder returns a JSON encoder writing to stdout. The output is
+// indented unless the flag "--json-compact" was given
+func (cli *Cli) newJSONEncoder() *json.Encoder {
+	enc := json.NewEncoder(os.Stdout)
+	if !cli.RuntimeOptions.JSONCompact {
+		enc.SetIndent("", "  ")
+	}
+
+	return enc
+}
+
+// outputFormat returns the given format or the default format from the
+// environment if no format was given
+func (cli *Cli) outputFormat(format mod.OutputFormat) mod.OutputFormat {
+	if format == "" {
+		return cli.DefaultFormat
+	}
+
+	return format
+}
+
+// setOutputFormat parses the given value from the CLI into the format.
+// An error message is returned if the format is unknown
+func setOutputFormat(format *mod.OutputFormat, value string) string {
+	f, err := mod.ParseOutputFormat(value)
+	if err != nil {
+		return err.Error()
+	}
+
+	*format = f
+	return ""
+}
+
+// getOutputFormats returns all available output formats for auto completion
+func getOutputFormats() []string {
+	return mod.OutputFormatNames()
+}
@@ -2,14 +2,18 @@ package args
 
 import (
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	service "github.com/RPJoshL/RPdb/v4/go/client/services"
 	mod "github.com/RPJoshL/RPdb/v4/go/models"
+	"github.com/RPJoshL/RPdb/v4/go/persistence"
 )
 
 // Entry contains entry options for the CLI
@@ -19,6 +23,9 @@ type Entry struct {
 	EntryDelete EntryDelete `cli:"delete,d"`
 	EntryCreate EntryCreate `cli:"create,c"`
 	EntryUpdate EntryUpdate `cli:"update,u"`
+
+	EntrySimulate EntrySimulate `cli:"simulate,sim"`
+	EntryArgs     EntryArgs     `cli:"args"`
 }
 
 type EntryList struct {
@@ -31,7 +38,21 @@ type EntryList struct {
 
 	Count bool `cli:"--count,-c,~~~"`
 
-	Format string `cli:"--output,-o" completion:"GetOutputFormats"`
+	// Version number or time since when created or updated entries should be listed
+	Since string `cli:"--since,-sn"`
+
+	// Print the name of parameter presets instead of their value (csv)
+	ShowPresets bool `cli:"--show-presets,-sp,~~~"`
+
+	// Comma separated list of optional columns to print (csv). See "entryColumns"
+	Columns string `cli:"--columns,-col" completion:"GetColumns"`
+	columns mod.EntryColumns
+
+	// Key to sort the printed entries after (see "entrySortKeys")
+	Sort    string `cli:"--sort,-so" completion:"GetSortKeys"`
+	Reverse bool   `cli:"--reverse,-rv,~~~"`
+
+	Format mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
 }
 
 type EntryDelete struct {
@@ -48,7 +69,10 @@ type EntryCreate struct {
 	Parameter    []string `cli:"--parameter,-p" completion:"GetParameters"`
 	ParameterSet bool
 
-	Format string `cli:"--output,-o" completion:"GetOutputFormats"`
+	// Parameters addressed by their name in the format "name=value"
+	NamedParameter []string `cli:"--namedParameter,-np"`
+
+	Format mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
 }
 
 type EntryUpdate struct {
@@ -59,12 +83,140 @@ type EntryUpdate struct {
 	IDs []int `cli:"--ids,-i,,1"`
 }
 
+type EntrySimulate struct {
+	// IDs of the entries to simulate
+	IDs []int `cli:"--ids,-i,,1"`
+
+	// Call the delete hook instead of the program
+	Delete bool `cli:"--delete,-de,~~~"`
+}
+
+type EntryArgs struct {
+	// IDs of the entries to print the arguments for
+	IDs []int `cli:"--ids,-i,,1"`
+
+	// Print the arguments of the delete hook instead of the program
+	Delete bool `cli:"--delete,-de,~~~"`
+}
+
 func (e *EntryList) SetCount() string {
 	e.Count = true
 
 	return ""
 }
 
+// entrySortKeys contains the comparators for the keys that can be used to sort
+// the listed entries. The entries are only sorted for the output
+var entrySortKeys = map[string]func(a, b *mod.Entry) bool{
+	"date": func(a, b *mod.Entry) bool {
+		return a.DateTime.Before(b.DateTime.Time)
+	},
+	"execution": func(a, b *mod.Entry) bool {
+		return a.GetExecutionTime(false).Before(b.GetExecutionTime(false))
+	},
+	"attribute": func(a, b *mod.Entry) bool {
+		return attributeName(a) < attributeName(b)
+	},
+	"id": func(a, b *mod.Entry) bool {
+		return a.ID < b.ID
+	},
+}
+
+// attributeName returns the name of the entries attribute (if any)
+func attributeName(e *mod.Entry) string {
+	if e.Attribute == nil {
+		return ""
+	}
+
+	return e.Attribute.Name
+}
+
+func (e *EntryList) SetSort(value string) string {
+	if _, ok := entrySortKeys[value]; !ok {
+		return fmt.Sprintf("Invalid sort key %q. Valid keys are: %s", value, strings.Join(getEntrySortKeys(), ", "))
+	}
+
+	e.Sort = value
+	return ""
+}
+
+// entryColumns contains the setters for the optional columns of the csv output
+var entryColumns = map[string]func(c *mod.EntryColumns){
+	"note":       func(c *mod.EntryColumns) { c.Note = true },
+	"parameters": func(c *mod.EntryColumns) { c.Parameters = true },
+}
+
+func (e *EntryList) SetColumns(value string) string {
+	for _, col := range strings.Split(value, ",") {
+		set, ok := entryColumns[strings.TrimSpace(col)]
+		if !ok {
+			return fmt.Sprintf("Invalid column %q. Valid columns are: %s", col, strings.Join(getEntryColumns(), ", "))
+		}
+		set(&e.columns)
+	}
+
+	e.Columns = value
+	return ""
+}
+
+func getEntryColumns() []string {
+	rtc := make([]string, 0, len(entryColumns))
+	for key := range entryColumns {
+		rtc = append(rtc, key)
+	}
+	sort.Strings(rtc)
+
+	return rtc
+}
+
+func (e *EntryList) SetShowPresets() string {
+	e.ShowPresets = true
+	e.columns.Presets = true
+
+	return ""
+}
+
+func (e *EntryList) SetReverse() string {
+	e.Reverse = true
+
+	return ""
+}
+
+// getEntrySortKeys returns all available keys to sort the entries after
+func getEntrySortKeys() []string {
+	rtc := make([]string, 0, len(entrySortKeys))
+	for key := range entrySortKeys {
+		rtc = append(rtc, key)
+	}
+	sort.Strings(rtc)
+
+	return rtc
+}
+
+// sortEntries sorts the entries by the given sort options. The original
+// order is kept for equal entries
+func (e *EntryList) sortEntries(entries []*mod.Entry) {
+	less, ok := entrySortKeys[e.Sort]
+	if !ok {
+		if !e.Reverse {
+			return
+		}
+
+		// Reverse the original order
+		less = func(a, b *mod.Entry) bool { return false }
+	}
+
+	if e.Reverse {
+		// Reverse the entries first, so that the order of equal entries is also reversed
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return less(entries[j], entries[i]) })
+	} else {
+		sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	}
+}
+
 func (e *EntryList) SetParameter(parameters []string) string {
 	e.ParameterSet = true
 	e.Parameter = parameters
@@ -79,34 +231,35 @@ func (e *EntryCreate) SetParameter(parameters []string) string {
 	return ""
 }
 
+func (e *EntryCreate) SetNamedParameter(parameters []string) string {
+	for _, p := range parameters {
+		if name, _, found := strings.Cut(p, "="); !found || name == "" {
+			return fmt.Sprintf("Invalid named parameter %q. Expected the format 'name=value'", p)
+		}
+	}
+
+	e.ParameterSet = true
+	e.NamedParameter = parameters
+
+	return ""
+}
+
+func (e *EntryList) SetFormat(value string) string {
+	return setOutputFormat(&e.Format, value)
+}
+
+func (e *EntryCreate) SetFormat(value string) string {
+	return setOutputFormat(&e.Format, value)
+}
+
 // ApplyFillter applies the dynamic filter parameters from the cli to the
 // EntryFilter
 func (e *EntryList) ApplyFilter(cli *Cli) string {
 	// Filter after attribute IDs
 	if e.Attributes != "" {
-		// Get all attributes to resolve attribute names
-		attributes, err := cli.GetApi().GetAttributes()
-		if err != nil {
-			return cli.PrintFatalErrorf("Failed to fetch available attributes: %s", err)
-		}
-
-	outer:
-		for _, val := range strings.Split(e.Attributes, ",") {
-			id := -1
-			if intVal, err := strconv.Atoi(val); err == nil {
-				id = intVal
-			}
-
-			// Loop through all attributes to find id / name
-			for _, a := range attributes {
-				if a.ID == id || a.Name == val {
-					e.EntryFilter.Attributes = append(e.EntryFilter.Attributes, a.ID)
-					continue outer
-				}
-			}
-
-			// No matching attribute found
-			return cli.PrintFatalErrorf("No attribute found for id / name %q", val)
+		e.EntryFilter.AttributeNames = strings.Split(e.Attributes, ",")
+		if err := e.EntryFilter.ResolveAttributes(cli.GetApi()); err != nil {
+			return cli.PrintFatalError(err.Error())
 		}
 	}
 
@@ -125,26 +278,93 @@ func (e *EntryList) ApplyFilter(cli *Cli) string {
 }
 
 func (e *EntryList) SetEntryList(cli *Cli) string {
+	if e.Since != "" {
+		return e.listSince(cli)
+	}
+
 	e.ApplyFilter(cli)
 
+	// Only print the number of entries (counted by the server)
+	if e.Count {
+		count, err := cli.GetApi().GetEntriesCount(e.EntryFilter)
+		if err != nil {
+			fmt.Printf("%d\n", -1)
+			return cli.PrintFatalError(err.Error())
+		}
+
+		fmt.Printf("%d\n", count)
+		return ""
+	}
+
 	// Make the request
 	entries, err := cli.GetApi().GetEntries(e.EntryFilter)
 	if err != nil {
 		return cli.PrintFatalError(err.Error())
 	}
 
+	// Print the entries (always as array)
+	e.printEntries(cli, entries)
+	return ""
+}
+
+// listSince prints all entries that were created or updated since the
+// given version or time
+func (e *EntryList) listSince(cli *Cli) string {
+	updReq, err := api.NewUpdateRequestSince(e.Since)
+	if err != nil {
+		return cli.PrintFatalErrorf("Invalid value for '--since': %s", err)
+	}
+
+	// The other filter options are applied locally to the changed entries
+	e.ApplyFilter(cli)
+	if !canFilterSince(e.EntryFilter) {
+		return cli.PrintFatalError("The option '--since' can only be combined with filter options that don't require the server (e.g. not '--oldDates')")
+	}
+
+	upd, errResp := cli.GetApi().GetUpdate(updReq)
+	if errResp != nil {
+		return cli.PrintFatalError(errResp.Error())
+	}
+	entries := filterEntries(e.EntryFilter, append(upd.Entry.Created, upd.Entry.Updated...))
+
 	// Only print the number of entries
 	if e.Count {
 		fmt.Printf("%d\n", len(entries))
 		return ""
 	}
 
-	// Print the entries (always as array)
-	cli.PrintEntriesFormatted(entries, e.Format)
+	e.printEntries(cli, entries)
 	return ""
 }
 
+// canFilterSince returns whether the given filter can be applied to the entries
+// returned for the option "--since"
+func canFilterSince(filter mod.EntryFilter) bool {
+	return filter.IsZero() || (filter.CanHandleLocally() && len(filter.Executed) == 0)
+}
+
+// filterEntries returns all the given entries that match the filter.
+// At most "MaxEntries" of the filter are returned
+func filterEntries(filter mod.EntryFilter, entries []*mod.Entry) []*mod.Entry {
+	rtc := make([]*mod.Entry, 0, len(entries))
+	matches := filter.Matcher()
+	for _, ent := range entries {
+		if filter.MaxEntries > 0 && len(rtc) >= filter.MaxEntries {
+			break
+		}
+		if filter.IsZero() || matches(*ent) {
+			rtc = append(rtc, ent)
+		}
+	}
+
+	return rtc
+}
+
 func (e *EntryDelete) SetEntryDelete(cli *Cli) string {
+	if e.EntryList.Since != "" {
+		return cli.PrintFatalError("The option '--since' cannot be used for deletion")
+	}
+
 	e.EntryList.ApplyFilter(cli)
 
 	// Make the request
@@ -154,22 +374,27 @@ func (e *EntryDelete) SetEntryDelete(cli *Cli) string {
 	}
 
 	// Only print the number of deleted entries
-	if e.EntryList.Count {
+	if e.EntryList.Count || cli.RuntimeOptions.Quiet {
 		fmt.Printf("%d\n", deleted.Count)
 		return ""
 	}
 
 	// Print the result of the deletion
-	switch strings.ToUpper(e.EntryList.Format) {
-	case "PRETTY", "":
-		fmt.Println(deleted.Message)
-	case "CSV":
+	switch cli.outputFormat(e.EntryList.Format) {
+	case mod.FormatPretty, "":
+		if deleted.Count == 0 {
+			fmt.Println("No entries matched the filter. Nothing was deleted")
+		} else if deleted.Message.Client == "" {
+			fmt.Printf("Deleted %d entries\n", deleted.Count)
+		} else {
+			fmt.Printf("%s (%d entries)\n", deleted.Message, deleted.Count)
+		}
+	case mod.FormatCSV:
 		w := csv.NewWriter(os.Stdout)
 		w.Write([]string{fmt.Sprintf("%d", deleted.Count), deleted.Message.Client})
 		w.Flush()
-	case "JSON":
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
+	case mod.FormatJSON:
+		enc := cli.newJSONEncoder()
 		enc.Encode(deleted)
 	default:
 		cli.PrintFatalErrorf("Invalid format given: %q", e.EntryList.Format)
@@ -180,7 +405,7 @@ func (e *EntryDelete) SetEntryDelete(cli *Cli) string {
 
 // PrintEntriesFormatted is a helper function to convert from []*mod.Entry to
 // []mod.Formattable
-func (cli *Cli) PrintEntriesFormatted(entries []*mod.Entry, format string) {
+func (cli *Cli) PrintEntriesFormatted(entries []*mod.Entry, format mod.OutputFormat) {
 	rtc := make([]mod.Formattable, len(entries))
 
 	for i, e := range entries {
@@ -190,6 +415,36 @@ func (cli *Cli) PrintEntriesFormatted(entries []*mod.Entry, format string) {
 	cli.PrintStructsFormatted(&rtc, format)
 }
 
+// entryView is a wrapper around an entry that prints the optional columns
+type entryView struct {
+	*mod.Entry
+	columns mod.EntryColumns
+}
+
+func (e entryView) ToSlice() []string {
+	return e.Entry.ToSliceWith(e.columns)
+}
+
+func (e entryView) Headers() []string {
+	return e.columns.Headers()
+}
+
+// printEntries prints the entries with the output options of the list command
+func (e *EntryList) printEntries(cli *Cli, entries []*mod.Entry) {
+	e.sortEntries(entries)
+
+	if e.columns == (mod.EntryColumns{}) {
+		cli.PrintEntriesFormatted(entries, e.Format)
+		return
+	}
+
+	rtc := make([]mod.Formattable, len(entries))
+	for i, ent := range entries {
+		rtc[i] = entryView{Entry: ent, columns: e.columns}
+	}
+	cli.PrintStructsFormatted(&rtc, e.Format)
+}
+
 func (e *EntryCreate) SetDate(val string) string {
 	// Try to parse the time
 	if tme, err := time.Parse(mod.TimeFormat, val); err != nil {
@@ -210,61 +465,92 @@ func (e *EntryCreate) ApplyEntry(cli *Cli) string {
 		e.Entry.Parameters = append(e.Entry.Parameters, mod.EntryParameter{Value: p})
 	}
 
-	if e.Attribute != "" {
-		var idInt = -1
-		// Try to parse the attribute to an ID
-		if intVal, err := strconv.Atoi(e.Attribute); err == nil {
-			idInt = intVal
-		}
+	// The named parameters are resolved to their position after the attribute was found
+	for _, p := range e.NamedParameter {
+		name, value, _ := strings.Cut(p, "=")
+		e.Entry.Parameters = append(e.Entry.Parameters, mod.EntryParameter{ParameterName: name, Value: value})
+	}
 
+	if e.Attribute != "" {
 		// Get all attributes for the user
 		attributes, err := cli.GetApi().GetAttributes()
 		if err != nil {
 			return cli.PrintFatalErrorf("Failed to fetch available attributes: %s", err)
 		}
 
-		// Search for the attribute Name
-		for _, a := range attributes {
-			if a.ID == idInt || a.Name == e.Attribute {
-				e.Entry.Attribute = a
-				break
-			}
-		}
-
-		if e.Entry.Attribute.ID == 0 {
+		// Search for the attribute ID or name
+		e.Entry.Attribute = mod.FindAttributeByIdOrName(attributes, e.Attribute)
+		if e.Entry.Attribute == nil {
 			return cli.PrintFatalErrorf("Unable to find attribute with name %q", e.Attribute)
 		}
+	}
 
+	// Validate the parameter names against the attribute
+	if err := e.Entry.ResolveParameterNames(); err != nil {
+		return cli.PrintFatalError(err.Error())
+	}
+
+	// The date can only be specified through a single option
+	dateOptions := 0
+	for _, given := range []bool{!e.Entry.DateTime.IsZero(), e.Entry.Offset != "", e.Entry.OffsetPattern != ""} {
+		if given {
+			dateOptions++
+		}
+	}
+	if dateOptions > 1 {
+		return cli.PrintFatalError("Only one of the options '--date', '--offset' and '--datePattern' can be given")
 	}
 
 	return ""
 }
 
+// applyDefaultOffset uses the default offset configured for the attribute when
+// no date, offset or date pattern was given explicitly.
+// Explicitly provided values do always take precedence
+func (e *EntryCreate) applyDefaultOffset(cli *Cli) {
+	if !e.Entry.DateTime.IsZero() || e.Entry.Offset != "" || e.Entry.OffsetPattern != "" {
+		return
+	}
+
+	if opt := cli.GetAttributeOptions(e.Entry.Attribute); opt != nil && opt.DefaultOffset != "" {
+		e.Entry.Offset = opt.DefaultOffset
+	}
+}
+
 func (e *EntryCreate) SetEntryCreate(cli *Cli) string {
-	e.ApplyEntry(cli)
+	if msg := e.ApplyEntry(cli); msg != "" {
+		return msg
+	}
 
 	// Attribute is required
 	if e.Entry.Attribute == nil {
 		return cli.PrintFatalError("Required parameter '--attribute' is missing")
 	}
 
+	// Use the default offset of the attribute if no date was given
+	e.applyDefaultOffset(cli)
+
 	ent, err := cli.GetApi().CreateEntry(e.Entry)
 	if err != nil {
 		return cli.PrintFatalError(err.Error())
 	}
 
-	if e.Entry.Attribute.ExecResponse.Enabled && (!e.Entry.Attribute.ExecResponse.AllowDelayedExecution || ent.ExecutionResponseId != 0) {
+	// Use the already resolved attribute if it wasn't expanded by the server
+	if ent.Attribute == nil || ent.Attribute.Name == "" {
+		ent.Attribute = e.Entry.Attribute
+	}
+
+	if ent.IsImmediateExecResponse() {
 		// Return execution response
-		switch strings.ToUpper(e.Format) {
-		case "PRETTY", "":
+		switch cli.outputFormat(e.Format) {
+		case mod.FormatPretty, "":
 			fmt.Println(ent.ExecutionResponse())
-		case "CSV":
+		case mod.FormatCSV:
 			w := csv.NewWriter(os.Stdout)
 			w.Write([]string{fmt.Sprintf("%d", ent.ResponseCode), ent.Response})
 			w.Flush()
-		case "JSON":
-			enc := json.NewEncoder(os.Stdout)
-			enc.SetIndent("", "  ")
+		case mod.FormatJSON:
+			enc := cli.newJSONEncoder()
 			enc.Encode(struct {
 				Code     int                 `json:"code"`
 				Response string              `json:"response"`
@@ -274,10 +560,13 @@ func (e *EntryCreate) SetEntryCreate(cli *Cli) string {
 			return cli.PrintFatalErrorf("Invalid format given: %q", e.Format)
 		}
 	} else {
-		switch strings.ToUpper(e.Format) {
-		case "PRETTY", "":
+		switch cli.outputFormat(e.Format) {
+		case mod.FormatPretty, "":
 			fmt.Println(ent.Message.Client)
-		case "CSV", "JSON":
+			if nextRun, future := ent.NextRun(); future {
+				fmt.Printf("Next run: %s\n", mod.DateTime{Time: nextRun}.FormatPretty())
+			}
+		case mod.FormatCSV, mod.FormatJSON:
 			cli.PrintStructFormatted(ent, e.Format)
 		default:
 			return cli.PrintFatalErrorf("Invalid format given: %q", e.Format)
@@ -288,7 +577,9 @@ func (e *EntryCreate) SetEntryCreate(cli *Cli) string {
 }
 
 func (e *EntryUpdate) SetEntryUpdate(cli *Cli) string {
-	e.EntryCreate.ApplyEntry(cli)
+	if msg := e.EntryCreate.ApplyEntry(cli); msg != "" {
+		return msg
+	}
 
 	// Attribute is required
 	if len(e.IDs) == 0 {
@@ -317,10 +608,10 @@ func (e *EntryUpdate) SetEntryUpdate(cli *Cli) string {
 		return cli.PrintFatalError(err.Error())
 	}
 
-	switch strings.ToUpper(e.EntryCreate.Format) {
-	case "PRETTY", "":
+	switch cli.outputFormat(e.EntryCreate.Format) {
+	case mod.FormatPretty, "":
 		fmt.Println(bulkResponse.Message.Client)
-	case "CSV":
+	case mod.FormatCSV:
 		w := csv.NewWriter(os.Stdout)
 		w.Write([]string{
 			fmt.Sprintf("%d", bulkResponse.Overview.Successful),
@@ -328,9 +619,8 @@ func (e *EntryUpdate) SetEntryUpdate(cli *Cli) string {
 			fmt.Sprintf("%d", bulkResponse.Overview.Exists),
 		})
 		w.Flush()
-	case "JSON":
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
+	case mod.FormatJSON:
+		enc := cli.newJSONEncoder()
 		enc.Encode(struct {
 			NewEntries []*mod.Entry                 `json:"new_entries"`
 			Response   *mod.BulkResponse[mod.Entry] `json:"response"`
@@ -342,6 +632,104 @@ func (e *EntryUpdate) SetEntryUpdate(cli *Cli) string {
 	return ""
 }
 
+func (e *EntrySimulate) SetDelete() string {
+	e.Delete = true
+	return ""
+}
+
+// SetEntrySimulate calls the configured program of the given entries immediately with the
+// same parameters as during a real execution. The entries are not marked as executed
+func (e *EntrySimulate) SetEntrySimulate(cli *Cli) string {
+	if len(e.IDs) == 0 {
+		return cli.PrintFatalError("Required positional parameter (ids) is missing")
+	}
+
+	executor := &service.ProgramExecutor{
+		Attributes: make(map[int]models.AttributeOptions),
+		Mutex:      &sync.Mutex{},
+		Simulation: true,
+	}
+
+	for _, id := range e.IDs {
+		ent, err := cli.GetApi().GetEntry(id)
+		if err != nil {
+			return cli.PrintFatalErrorf("Failed to get entry #%d: %s", id, err)
+		} else if ent.Attribute == nil {
+			return cli.PrintFatalErrorf("The attribute of the entry #%d could not be resolved", id)
+		}
+
+		// Find the configuration of the attribute
+		opts := cli.GetAttributeOptions(ent.Attribute)
+		if opts == nil {
+			return cli.PrintFatalErrorf("No options are configured for the attribute %q of the entry #%d", ent.Attribute.Name, id)
+		}
+		executor.Attributes[ent.Attribute.ID] = *opts
+
+		switch {
+		case e.Delete:
+			executor.Execute(*ent, persistence.DELETE)
+		case ent.Attribute.IsExecResponse():
+			res := executor.ExecuteResponse(*ent)
+			if res == nil {
+				return cli.PrintFatalErrorf("No program is configured for the attribute %q", ent.Attribute.Name)
+			}
+			fmt.Println(res)
+		default:
+			executor.Execute(*ent, persistence.DEFAULT)
+		}
+	}
+
+	return ""
+}
+
+func (e *EntryArgs) SetDelete() string {
+	e.Delete = true
+	return ""
+}
+
+// SetEntryArgs prints the program and the arguments that would be used to execute
+// the given entries without running anything
+func (e *EntryArgs) SetEntryArgs(cli *Cli) string {
+	if len(e.IDs) == 0 {
+		return cli.PrintFatalError("Required positional parameter (ids) is missing")
+	}
+
+	executor := &service.ProgramExecutor{
+		Attributes: make(map[int]models.AttributeOptions),
+		Mutex:      &sync.Mutex{},
+	}
+	typ := persistence.DEFAULT
+	if e.Delete {
+		typ = persistence.DELETE
+	}
+
+	for _, id := range e.IDs {
+		ent, err := cli.GetApi().GetEntry(id)
+		if err != nil {
+			return cli.PrintFatalErrorf("Failed to get entry #%d: %s", id, err)
+		} else if ent.Attribute == nil {
+			return cli.PrintFatalErrorf("The attribute of the entry #%d could not be resolved", id)
+		}
+
+		if opts := cli.GetAttributeOptions(ent.Attribute); opts != nil {
+			executor.Attributes[ent.Attribute.ID] = *opts
+		}
+
+		program, args, perr := executor.GetParametersPreview(*ent, typ)
+		if perr != nil {
+			return cli.PrintFatalErrorf("Failed to get the arguments of the entry #%d: %s", id, perr)
+		}
+
+		fmt.Printf("#%d: %q", id, program)
+		for _, arg := range args {
+			fmt.Printf(" %q", arg)
+		}
+		fmt.Println()
+	}
+
+	return ""
+}
+
 func (e *Entry) IsFieldDisabled() bool {
 	return e.Disabled
 }
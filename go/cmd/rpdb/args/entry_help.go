@@ -30,6 +30,16 @@ list [options]		|Lists all available entries matching the filter options
 
     --max          -m  {x}       |Shows at a max rate {x} entries
     --count        -c            |Shows only the NUMBER of entries (-1 on error)
+    --since        -sn {xx}      |Shows only entries created or updated since the given version number
+                                 or time (YYYY-MM-DDThh:mm:ss). The other filter options are applied
+                                 to the changed entries. '--oldDates' is not supported
+    --sort         -so {key}     |Sorts the printed entries after the given key. Available keys are
+                                 'date', 'execution', 'attribute' and 'id'
+    --reverse      -rv           |Prints the entries in reverse order
+    --columns      -col {list}   |Comma separated list of optional columns to print after the fixed
+                                 columns (csv). Available columns are 'note' and 'parameters'
+    --show-presets -sp           |Prints the parameters with the name of their presets instead of
+                                 their values (csv). This implies the column 'parameters'
 |_______________________________________________________________________________
 
 Global options that can be used for almost all comamnds.
@@ -51,10 +61,15 @@ create -a\|--attribute id\|name {one of the available method} [options]
                               an own offset (positive: + \| negative: /) can be given.
                               Examples: 2021-01-01T+20:00:00  \|  +0-+1-+0T/5:00:+20
         --keepDate    -kd     |The date will be kept during overflow of the date (day will not be changed)
+                              |If none of these methods is given, the 'defaultOffset' of the attribute
+                              configuration is used (if any). Only one of these methods can be given
 
     --parameter -p  [ 1 2 ]   |Parameter values or the name of a preset for the entry
+    --namedParameter -np [ name=1 ] |Parameter values addressed by the name of the parameter.
+                              |These can be combined with "--parameter"
     --timeout   -t  {sec}     |Exec Response: Waiting time in seconds to receive a response.
                               |Specify "0" to not wait for an answer
+    --note      -nt {text}    |A note for your own bookkeeping. It has no influence on the execution
 |_______________________________________________________________________________
 
 Global options that can be used for almost all comamnds.
@@ -67,7 +82,8 @@ func (e *EntryDelete) Help() string {
 	return fmt.Sprintf(
 		`
 delete [options]    |Delete entries base on the given search parameters
-                    |See the section "list" for options
+                    |See the section "list" for options. With '--count' or '--quiet'
+                    |only the number of deleted entries is printed
 %s`, regexp.MustCompile(`^.*\n.*\n`).ReplaceAllString((&EntryList{}).Help(), ""))
 }
 
@@ -78,6 +94,25 @@ update id,id,id  {fields}   |For all the given entries the fields will be update
 %s`, regexp.MustCompile(`^.*\n.*\n`).ReplaceAllString((&EntryCreate{}).Help(), ""))
 }
 
+func (e *EntrySimulate) Help() string {
+	return `
+simulate id,id,id [options] |Calls the configured program of the entries immediately with the same
+                            |parameters as during a real execution. The entries are not marked as executed.
+                            |Use this to validate the configuration of your programs
+
+    --delete    -de         |Calls the "onDelete" program instead
+`
+}
+
+func (e *EntryArgs) Help() string {
+	return `
+args id,id,id [options]     |Prints the program and the arguments (quoted) that would be used to
+                            |execute the entries. Nothing is executed
+
+    --delete    -de         |Prints the arguments of the "onDelete" program instead
+`
+}
+
 func (e *Entry) Help() string {
 	return (`
 Create, delete, update and query entries.
@@ -90,6 +125,10 @@ delete [options]            |Delete entries base on the given search parameters
 create -a\|--attribute id\|name {one of the available method} [options] | Create a single entry
 
 update id,id,id  {fields}   |For all the given entries the fields will be updated accordingly
+
+simulate id,id,id           |Calls the configured program of the entries without marking them as executed
+
+args id,id,id               |Prints the program and arguments that would be used to execute the entries
 |_______________________________________________________________________________
 
 |Global options that can be used for almost all comamnds.
@@ -227,35 +266,13 @@ func GetParameterPresets(cli *Cli, input string, attribute string, position int)
 			if attr, err := cli.GetApi().GetAttribute(id); err != nil {
 				logger.Error("[Autocomplete] Failed to fetch attribute %q: %s", attribute, err)
 			} else {
-				if position < len(attr.Parameter) {
-					for _, par := range attr.Parameter[position].Presets {
-						rtc = append(rtc, par.Name)
-					}
-
-					// Add true / false for boolean parameter
-					if !attr.Parameter[position].ForcePreset && attr.Parameter[position].Type == mod.PARAMETER_TYPE_BOOL {
-						rtc = append(rtc, "true", "false")
-					}
-				}
-
-				return rtc, attr
+				return getPresetNames(attr, position), attr
 			}
 		} else {
-			if attr, err := cli.GetApi().GetAttributeByName(attribute); err != nil {
+			if attr, err := cli.GetApi().GetAttributeByNameFold(attribute); err != nil {
 				logger.Error("[Autocomplte] Failed to fetch attribute %q: %s", attribute, err)
 			} else {
-				if position < len(attr.Parameter) {
-					for _, par := range attr.Parameter[position].Presets {
-						rtc = append(rtc, par.Name)
-					}
-
-					// Add true / false for boolean parameter
-					if !attr.Parameter[position].ForcePreset && attr.Parameter[position].Type == mod.PARAMETER_TYPE_BOOL {
-						rtc = append(rtc, "true", "false")
-					}
-				}
-
-				return rtc, attr
+				return getPresetNames(attr, position), attr
 			}
 		}
 	}
@@ -263,9 +280,37 @@ func GetParameterPresets(cli *Cli, input string, attribute string, position int)
 	return rtc, nil
 }
 
+// getPresetNames returns the names of all presets for the parameter at the given
+// position (indexed by 0). For boolean parameters "true" and "false" are added
+func getPresetNames(attr *mod.Attribute, position int) (rtc []string) {
+	rtc = make([]string, 0)
+	if position >= len(attr.Parameter) {
+		return
+	}
+
+	for _, par := range attr.Parameter[position].Presets {
+		rtc = append(rtc, par.Name)
+	}
+
+	// Add true / false for boolean parameter
+	if !attr.Parameter[position].ForcePreset && attr.Parameter[position].Type == mod.PARAMETER_TYPE_BOOL {
+		rtc = append(rtc, "true", "false")
+	}
+
+	return
+}
+
 func (e *EntryCreate) GetOutputFormats(cli *Cli, input string) (rtc []string) {
-	return []string{"pretty", "csv", "json"}
+	return getOutputFormats()
+}
+func (e *EntryList) GetSortKeys(cli *Cli, input string) (rtc []string) {
+	return getEntrySortKeys()
 }
+
+func (e *EntryList) GetColumns(cli *Cli, input string) (rtc []string) {
+	return getEntryColumns()
+}
+
 func (e *EntryList) GetOutputFormats(cli *Cli, input string) (rtc []string) {
-	return []string{"pretty", "csv", "json"}
+	return getOutputFormats()
 }
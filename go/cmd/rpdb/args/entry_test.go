@@ -0,0 +1,78 @@
+package args
+
+import (
+	"testing"
+	"time"
+
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestFilterEntriesSince(t *testing.T) {
+	date := time.Now().Add(time.Hour)
+	entries := []*mod.Entry{
+		{ID: 1, Attribute: &mod.Attribute{ID: 1}, DateTime: mod.DateTime{Time: date}},
+		{ID: 2, Attribute: &mod.Attribute{ID: 2}, DateTime: mod.DateTime{Time: date}},
+		{ID: 3, Attribute: &mod.Attribute{ID: 1}, DateTime: mod.DateTime{Time: date}},
+	}
+
+	tests := []struct {
+		name      string
+		filter    mod.EntryFilter
+		canFilter bool
+		want      []int
+	}{
+		{name: "no filter", canFilter: true, want: []int{1, 2, 3}},
+		{name: "attribute", filter: mod.EntryFilter{Attributes: []int{1}}, canFilter: true, want: []int{1, 3}},
+		{name: "ids", filter: mod.EntryFilter{IDs: []int{2, 3}}, canFilter: true, want: []int{2, 3}},
+		{name: "max entries", filter: mod.EntryFilter{Attributes: []int{1}, MaxEntries: 1}, canFilter: true, want: []int{1}},
+		{name: "old dates", filter: mod.EntryFilter{OldDates: true}, canFilter: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canFilterSince(tt.filter); got != tt.canFilter {
+				t.Fatalf("canFilterSince() = %t, want %t", got, tt.canFilter)
+			}
+			if !tt.canFilter {
+				return
+			}
+
+			got := filterEntries(tt.filter, entries)
+			if len(got) != len(tt.want) {
+				t.Fatalf("filterEntries() returned %d entries, want %v", len(got), tt.want)
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i] {
+					t.Errorf("filterEntries()[%d] = #%d, want #%d", i, got[i].ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetColumns(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    mod.EntryColumns
+		wantErr bool
+	}{
+		{value: "note", want: mod.EntryColumns{Note: true}},
+		{value: "note, note", want: mod.EntryColumns{Note: true}},
+		{value: "parameters", want: mod.EntryColumns{Parameters: true}},
+		{value: "note,parameters", want: mod.EntryColumns{Note: true, Parameters: true}},
+		{value: "unknown", wantErr: true},
+		{value: "note,unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var e EntryList
+			if msg := e.SetColumns(tt.value); (msg != "") != tt.wantErr {
+				t.Fatalf("SetColumns() = %q, want an error: %t", msg, tt.wantErr)
+			}
+			if !tt.wantErr && e.columns != tt.want {
+				t.Errorf("SetColumns() set the columns %+v, want %+v", e.columns, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package args
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// Ping checks if the server is reachable and the API key is valid
+type Ping struct {
+	Disabled bool
+	Format   mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
+}
+
+func (p *Ping) SetFormat(value string) string {
+	return setOutputFormat(&p.Format, value)
+}
+
+func (p *Ping) GetOutputFormats(cli *Cli, input string) (rtc []string) {
+	return getOutputFormats()
+}
+
+func (p *Ping) IsFieldDisabled() bool {
+	return p.Disabled
+}
+
+// SetPing makes a lightweight authenticated request to the server and prints the
+// latency and the current version of the data
+func (p *Ping) SetPing(cli *Cli) string {
+	start := time.Now()
+	upd, err := cli.GetApi().GetUpdate(api.UpdateRequest{OnlyVersion: true})
+	latency := time.Since(start)
+	if err != nil {
+		return cli.PrintFatalErrorf("Server is not reachable or the API key is invalid: %s", err)
+	}
+
+	switch cli.outputFormat(p.Format) {
+	case mod.FormatPretty, "":
+		fmt.Printf("Server reachable (latency: %d ms)\nData version: %d\n", latency.Milliseconds(), upd.Version)
+	case mod.FormatCSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{fmt.Sprintf("%d", latency.Milliseconds()), fmt.Sprintf("%d", upd.Version)})
+		w.Flush()
+	case mod.FormatJSON:
+		enc := cli.newJSONEncoder()
+		enc.Encode(struct {
+			Reachable bool  `json:"reachable"`
+			Latency   int64 `json:"latency_ms"`
+			Version   int   `json:"version"`
+		}{Reachable: true, Latency: latency.Milliseconds(), Version: upd.Version})
+	default:
+		return cli.PrintFatalErrorf("Invalid format given: %q", p.Format)
+	}
+
+	return ""
+}
+
+func (p *Ping) Help() string {
+	return `
+ping [options]      |Checks if the server is reachable and the API key is valid.
+                    |The latency and the current data version is printed
+
+    --output  {format}        |Output format to use|. Available formats are 'pretty', 'json' and 'csv'
+`
+}
@@ -0,0 +1,179 @@
+package args
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	mod "github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// SelfTest creates a throwaway entry, reads it back and deletes it again to
+// verify the full round-trip against the server
+type SelfTest struct {
+	Disabled bool
+
+	Attribute string   `cli:"--attribute,-a" completion:"GetAttributeNames"`
+	Parameter []string `cli:"--parameter,-p"`
+
+	// Skip the confirmation prompt
+	Yes bool `cli:"--yes,-y,~~~"`
+
+	Format mod.OutputFormat `cli:"--output,-o" completion:"GetOutputFormats"`
+}
+
+// selfTestOffset is the offset of the created test entry. It's far in the future
+// so that the entry won't be executed by any running service before it's deleted
+const selfTestOffset = "+365d"
+
+// selfTestStep is the result of a single step of the self-test
+type selfTestStep struct {
+	Step    string `json:"step"`
+	Success bool   `json:"success"`
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (s selfTestStep) String() string {
+	if !s.Success {
+		return fmt.Sprintf("%-8s failed  (%d ms): %s", s.Step, s.Latency, s.Error)
+	}
+
+	return fmt.Sprintf("%-8s ok      (%d ms)", s.Step, s.Latency)
+}
+
+func (s selfTestStep) ToSlice() []string {
+	return []string{s.Step, fmt.Sprintf("%t", s.Success), fmt.Sprintf("%d", s.Latency), s.Error}
+}
+
+func (s selfTestStep) Headers() []string {
+	return []string{"step", "success", "latency_ms", "error"}
+}
+
+func (s *SelfTest) SetFormat(value string) string {
+	return setOutputFormat(&s.Format, value)
+}
+
+func (s *SelfTest) IsFieldDisabled() bool {
+	return s.Disabled
+}
+
+func (s *SelfTest) SetYes() string {
+	s.Yes = true
+
+	return ""
+}
+
+// SetSelfTest runs the steps create, read and delete one after another and prints
+// the result of every step
+func (s *SelfTest) SetSelfTest(cli *Cli) string {
+	if s.Attribute == "" {
+		return cli.PrintFatalError("Required parameter '--attribute' is missing")
+	}
+
+	// Writing requires an explicit confirmation
+	if !s.Yes {
+		fmt.Fprintf(os.Stderr, "A test entry will be created and deleted for the attribute %q. Continue? [y/N] ", s.Attribute)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return cli.PrintFatalError("Self-test aborted")
+		}
+	}
+
+	api := cli.GetApi()
+	var steps []mod.Formattable
+	failed := false
+
+	// Runs the given step and records its latency
+	run := func(name string, step func() error) bool {
+		start := time.Now()
+		err := step()
+
+		res := selfTestStep{Step: name, Success: err == nil, Latency: time.Since(start).Milliseconds()}
+		if err != nil {
+			res.Error = err.Error()
+			failed = true
+		}
+		steps = append(steps, res)
+
+		return err == nil
+	}
+
+	var attribute *mod.Attribute
+	var created *mod.Entry
+	ok := run("auth", func() error {
+		attributes, err := api.GetAttributes()
+		if err != nil {
+			return err
+		}
+
+		if attribute = mod.FindAttributeByIdOrName(attributes, s.Attribute); attribute == nil {
+			return fmt.Errorf("unable to find attribute with name %q", s.Attribute)
+		}
+		return nil
+	})
+
+	ok = ok && run("create", func() error {
+		ent := mod.Entry{Attribute: attribute, Offset: selfTestOffset}
+		for _, p := range s.Parameter {
+			ent.Parameters = append(ent.Parameters, mod.EntryParameter{Value: p})
+		}
+
+		var err *mod.ErrorResponse
+		if created, err = api.CreateEntry(ent); err != nil {
+			return err
+		}
+		return nil
+	})
+
+	if ok {
+		// The entry is also deleted when it couldn't be read back
+		run("read", func() error {
+			entries, err := api.GetEntries(mod.EntryFilter{IDs: []int{created.ID}})
+			if err != nil {
+				return err
+			}
+
+			if len(entries) != 1 || entries[0].ID != created.ID {
+				return fmt.Errorf("created entry #%d was not returned", created.ID)
+			}
+			return nil
+		})
+
+		run("delete", func() error {
+			if _, err := api.DeleteEntry(created.ID); err != nil {
+				return err
+			}
+			return nil
+		})
+	}
+
+	cli.PrintStructsFormatted(&steps, s.Format)
+	if failed {
+		return cli.PrintFatalError("Self-test failed")
+	}
+
+	return ""
+}
+
+func (s *SelfTest) GetAttributeNames(cli *Cli, input string) (rtc []string) {
+	return cli.Entry.GetAttributeNames(cli, input)
+}
+
+func (s *SelfTest) GetOutputFormats(cli *Cli, input string) (rtc []string) {
+	return getOutputFormats()
+}
+
+func (s *SelfTest) Help() string {
+	return `
+selftest [options]      |Creates a throwaway entry, reads it back and deletes it again.
+                        |The success and latency of every step is printed. Useful for smoke tests of a deployment
+
+    --attribute  -a  {name}        |Name or ID of the attribute to create the test entry for (required)
+    --parameter  -p  {value}       |Parameters of the test entry|. Can be provided multiple times
+    --yes        -y                |Don't ask for a confirmation before creating the entry
+    --output     -o  {format}      |Output format to use|. Available formats are 'pretty', 'json' and 'csv'
+`
+}
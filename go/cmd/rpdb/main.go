@@ -2,17 +2,24 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"git.rpjosh.de/RPJosh/go-logger"
 	"github.com/RPJoshL/RPdb/v4/go/client/models"
 	service "github.com/RPJoshL/RPdb/v4/go/client/services"
 	"github.com/RPJoshL/RPdb/v4/go/cmd/rpdb/args"
 	"github.com/RPJoshL/RPdb/v4/go/persistence"
-	"git.rpjosh.de/RPJosh/go-logger"
 )
 
+// Maximum time to wait for running executions during shutdown
+const shutdownTimeout = 30 * time.Second
+
 // App contains shared ressource needed for the run of the application
 type App struct {
 	config   *models.AppConfig
@@ -56,30 +63,89 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Context that is canceled when the program should be stopped
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	pers := persistence.NewPersistenceWithContext(
 		ctx, conf.UserConfig.ApiKey, conf.ToApiOptions(),
 		&persistence.PersistenceOptions{
-			WebSocket:                  conf.ToWebsocketOptions(),
-			Exeuction:                  persistence.Execution{},
-			BeforeInitialUpdateRequest: app.initExecutor,
+			WebSocket:                   conf.ToWebsocketOptions(),
+			Exeuction:                   persistence.Execution{Logger: conf.LoggerConfig.ExecutionLogger()},
+			BeforeInitialUpdateRequest:  app.initExecutor,
+			MaxCatchUpAge:               conf.StartupConfig.MaxCatchUpAge,
+			RetryInitialLoad:            true,
+			RetryInitialLoadMaxAttempts: conf.StartupConfig.MaxAttempts,
+			RetryInitialLoadMaxDuration: conf.StartupConfig.MaxDuration,
 		},
 	)
 
+	// Abort the retries of the initial load when the program should be stopped
+	started := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCtx.Done():
+			cancel()
+		case <-started:
+		}
+	}()
+
 	// Initialize the persistence layer
-	if err := pers.Start(); err != nil {
+	err = pers.Start()
+	close(started)
+	if err != nil {
 		logger.Fatal("Failed to start the persistence layer: %s", err)
 	}
 
 	// Create context which expires in "oneShot" minutes
 	if app.config.RuntimeOptions.OneShot != nil {
-		oneShot := NewOneShot(*app.config.RuntimeOptions.OneShot, pers, &app.attributeMap, app.executionSync)
+		oneShot := NewOneShot(*app.config.RuntimeOptions.OneShot, pers, &app.attributeMap, app.executionSync, app.executor)
 
 		// Add update hook to persistence
 		oneShot.Start(pers.Update.RegisterObserver())
 	}
 
-	// Run the program infinite
-	select {}
+	// Log a heartbeat periodically
+	if interval := app.config.LoggerConfig.StatusInterval; interval > 0 && app.config.RuntimeOptions.Service {
+		go app.logStatus(sigCtx, pers, interval)
+	}
+
+	// Run the program until a signal to stop was received
+	<-sigCtx.Done()
+	app.shutdown(pers, cancel)
+}
+
+// shutdown aborts running exec responses and waits until running executions
+// are finished. Afterwards the persistence layer is closed and its context canceled
+func (app *App) shutdown(pers *persistence.Persistence, cancel context.CancelFunc) {
+	logger.Info("Shutting down")
+
+	// Abort exec responses so that the server is not waiting for the response
+	pers.Options.Exeuction.CancelExecResponses()
+	if app.executor != nil && !app.executor.Wait(shutdownTimeout) {
+		logger.Warning("Running executions did not finish within %.0f seconds", shutdownTimeout.Seconds())
+	}
+
+	if err := pers.Close(); err != nil {
+		logger.Warning(err.Error())
+	}
+	cancel()
+}
+
+// logStatus logs the status of the persistence layer in the given interval until
+// the context is canceled
+func (app *App) logStatus(ctx context.Context, pers *persistence.Persistence, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logger.Info("Status: %s", pers.Status())
+		}
+	}
 }
 
 // initExecutor initializes the executor after the persistence data were loaded
@@ -106,15 +172,48 @@ func (app *App) initExecutor(pers *persistence.Persistence) {
 		}
 	}
 
+	app.checkWebSocketRequirements(pers)
+
 	// Init executor
+	// The webhooks share the transport of the API. Their timeout is set by the execution
+	webhookClient := pers.GetDefaultClient()
+	webhookClient.Timeout = 0
+
 	app.executor = &service.ProgramExecutor{
 		Attributes: app.attributeMap,
 		Mutex:      app.executionSync,
+		Context:    pers.Options.Exeuction.BaseContext,
+		Logger:     app.config.LoggerConfig.ExecutionLogger(),
+		Client:     &webhookClient,
 	}
 
 	// Assign exeuctor to persistence
 	pers.Options.Exeuction.Executor = app.executor.Execute
-	pers.Options.Exeuction.ExecuterExecResponse = app.executor.ExecuteResponse
+	pers.Options.Exeuction.ExecuterExecResponseContext = app.executor.ExecuteResponseContext
+}
+
+// checkWebSocketRequirements checks if programs are configured for attributes of the type
+// "no_db" or "exec_response" while the WebSocket is disabled. Entries of these attributes
+// are only received through the WebSocket, so that these programs would never be executed.
+// With the flag "--strict" the program is exited in such a case
+func (app *App) checkWebSocketRequirements(pers *persistence.Persistence) {
+	if pers.Options.WebSocket.UseWebsocket {
+		return
+	}
+
+	for id, opt := range app.attributeMap {
+		attr, err := pers.GetAttribute(id)
+		if err != nil || !opt.IsExecutable() || (!attr.NoDb && !attr.IsExecResponse()) {
+			continue
+		}
+
+		message := fmt.Sprintf("The program of the attribute %q (#%d) is never executed because entries of the type 'no_db' or 'exec_response' require the WebSocket", attr.Name, attr.ID)
+		if app.config.RuntimeOptions.Strict {
+			logger.Fatal(message)
+		} else {
+			logger.Warning(message)
+		}
+	}
 }
 
 // CheckForAnonymousArgs checks if the first CLI argument is whitelisted to be used "anonymously" without
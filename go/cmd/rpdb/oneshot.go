@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/client/models"
+	service "github.com/RPJoshL/RPdb/v4/go/client/services"
 	mod "github.com/RPJoshL/RPdb/v4/go/models"
 	"github.com/RPJoshL/RPdb/v4/go/persistence"
 	"git.rpjosh.de/RPJosh/go-logger"
@@ -20,14 +21,18 @@ type OneShot struct {
 
 	// Mutex to synchronize the os.exit function
 	Mtx *sync.Mutex
+
+	// Executor to wait for running webhooks before leaving. These are not synchronized with "Mtx"
+	Executor *service.ProgramExecutor
 }
 
-func NewOneShot(duration time.Duration, persistence *persistence.Persistence, attributes *map[int]models.AttributeOptions, execSync *sync.Mutex) *OneShot {
+func NewOneShot(duration time.Duration, persistence *persistence.Persistence, attributes *map[int]models.AttributeOptions, execSync *sync.Mutex, executor *service.ProgramExecutor) *OneShot {
 	rtc := &OneShot{
 		Duration:    duration,
 		Persistence: persistence,
 		Attributes:  attributes,
 		Mtx:         execSync,
+		Executor:    executor,
 	}
 
 	return rtc
@@ -59,8 +64,8 @@ func (o *OneShot) checkAndScheduleOneShot() {
 	// Find the next entry to execute
 	for _, e := range o.Persistence.GetEntriesAll() {
 
-		// Only attributes which does have a program registered a counted for one shot
-		if attr, doesExist := (*o.Attributes)[e.Attribute.ID]; !doesExist || attr.Program == "" {
+		// Only attributes which does have a program or webhook registered a counted for one shot
+		if attr, doesExist := (*o.Attributes)[e.Attribute.ID]; !doesExist || !attr.IsExecutable() {
 			continue
 		}
 
@@ -79,6 +84,9 @@ func (o *OneShot) checkAndScheduleOneShot() {
 	// Let the executor some time to lock.
 	// @TODO how could we make this cleaner?
 	time.Sleep(100 * time.Millisecond)
+	if o.Executor != nil && !o.Executor.Wait(shutdownTimeout) {
+		logger.Warning("Running executions did not finish within %.0f seconds", shutdownTimeout.Seconds())
+	}
 
 	o.Mtx.Lock()
 	logger.Info("Found no entry within the time range of oneShot. Leaving now")
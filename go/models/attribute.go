@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -173,17 +174,139 @@ func (c Right) MarshalJSON() ([]byte, error) {
 }
 
 // NewAttribute decodes the JSON response of the given reader
-// to a new attribute
+// to a new attribute.
+// An empty body (e.g. "204 No Content") results in an empty value
 func NewAttribute(r io.Reader) *Attribute {
 	var attr Attribute
 
-	if err := json.NewDecoder(r).Decode(&attr); err != nil {
+	if err := json.NewDecoder(r).Decode(&attr); err != nil && err != io.EOF {
 		logger.Warning("Failed to decode attribute: %s", err)
 	}
 
 	return &attr
 }
 
+// MarshalJSON implements the marshal interface to emit the parameters and presets in
+// a stable order (by their position and sort order). This keeps the JSON output
+// byte-stable for the same data
+func (a Attribute) MarshalJSON() ([]byte, error) {
+	// We cannot use attribute directly to avoid a loop :)
+	type TempAttribute Attribute
+
+	attr := TempAttribute(*a.Clone())
+	sort.SliceStable(attr.Parameter, func(i, j int) bool {
+		return attr.Parameter[i].Position < attr.Parameter[j].Position
+	})
+	for _, p := range attr.Parameter {
+		sort.SliceStable(p.Presets, func(i, j int) bool {
+			return p.Presets[i].SortOrder < p.Presets[j].SortOrder
+		})
+	}
+
+	return json.Marshal(attr)
+}
+
+// FindAttributeByName returns the attribute with the given name from the list.
+// An exact match is preferred. If no attribute matches exactly, the names are compared
+// case-insensitive. When multiple attributes match case-insensitive, a warning is logged
+// and the first one is returned.
+// If no attribute was found, nil is returned
+func FindAttributeByName(attributes []*Attribute, name string) *Attribute {
+	var rtc *Attribute
+	matches := 0
+
+	for i, a := range attributes {
+		if a.Name == name {
+			return attributes[i]
+		} else if strings.EqualFold(a.Name, name) {
+			matches++
+			if rtc == nil {
+				rtc = attributes[i]
+			}
+		}
+	}
+
+	if matches > 1 {
+		logger.Warning("Found %d attributes matching the name %q case-insensitive. Using %q", matches, name, rtc.Name)
+	}
+
+	return rtc
+}
+
+// FindAttributeByIdOrName returns the attribute with the given ID or name from the list.
+// The name is compared like in [FindAttributeByName].
+// If no attribute was found, nil is returned
+func FindAttributeByIdOrName(attributes []*Attribute, idOrName string) *Attribute {
+	if id, err := strconv.Atoi(idOrName); err == nil {
+		for i, a := range attributes {
+			if a.ID == id {
+				return attributes[i]
+			}
+		}
+	}
+
+	return FindAttributeByName(attributes, idOrName)
+}
+
+// Clone returns a deep copy of the attribute
+func (a *Attribute) Clone() *Attribute {
+	rtc := *a
+
+	if a.Parameter != nil {
+		rtc.Parameter = make([]AttributeParameter, len(a.Parameter))
+		for i, p := range a.Parameter {
+			rtc.Parameter[i] = p
+			if p.Presets != nil {
+				rtc.Parameter[i].Presets = append([]ParameterPreset(nil), p.Presets...)
+			}
+		}
+	}
+
+	return &rtc
+}
+
+// IsExecResponse returns whether a response message and code is expected to be
+// returned to the client after an entry of this attribute was executed
+func (a *Attribute) IsExecResponse() bool {
+	return a.ExecResponse.Enabled
+}
+
+// ParameterByName returns the parameter of the attribute with the given
+// name (case-insensitive). If no parameter was found, false is returned
+func (a *Attribute) ParameterByName(name string) (*AttributeParameter, bool) {
+	for i := range a.Parameter {
+		if strings.EqualFold(a.Parameter[i].Name, name) {
+			return &a.Parameter[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// ParameterByID returns the parameter of the attribute with the given ID.
+// If no parameter was found, false is returned
+func (a *Attribute) ParameterByID(id int) (*AttributeParameter, bool) {
+	for i := range a.Parameter {
+		if a.Parameter[i].ID == id {
+			return &a.Parameter[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// PresetByName returns the preset of the parameter with the given
+// name (case-insensitive). If no preset was found, false is returned
+func (ap *AttributeParameter) PresetByName(name string) (*ParameterPreset, bool) {
+	for i := range ap.Presets {
+		if strings.EqualFold(ap.Presets[i].Name, name) {
+			return &ap.Presets[i], true
+		}
+	}
+
+	return nil, false
+}
+
 func (ap AttributeParameter) String(indent string) string {
 	// Build info string for presets
 	presets := ""
@@ -250,3 +373,7 @@ func (a Attribute) ToSlice() []string {
 		strconv.FormatBool(a.ExecResponse.Enabled),
 	}
 }
+
+func (a Attribute) Headers() []string {
+	return []string{"id", "name", "execute_always", "no_db", "exec_response"}
+}
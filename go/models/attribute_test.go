@@ -0,0 +1,90 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files within testdata")
+
+// newGoldenAttribute returns a fixed attribute. The parameters and presets are
+// reversed if requested
+func newGoldenAttribute(reversed bool) *Attribute {
+	attr := &Attribute{
+		ID:           1,
+		Name:         "light",
+		Rights:       WRITE,
+		DefaultRight: READ,
+		SortOrder:    2,
+		Parameter: []AttributeParameter{
+			{ID: 10, Name: "room", Position: 1, Type: PARAMETER_TYPE_STRING, ForcePreset: true, Presets: []ParameterPreset{
+				{Name: "Kitchen", ShortName: "k", Value: "10", SortOrder: 1},
+				{Name: "Bedroom", ShortName: "b", Value: "20", SortOrder: 2},
+				{Name: "Bath", ShortName: "ba", Value: "30", SortOrder: 3},
+			}},
+			{ID: 11, Name: "brightness", Position: 2, Type: PARAMETER_TYPE_NUMBER},
+		},
+	}
+
+	if reversed {
+		p := attr.Parameter
+		p[0], p[1] = p[1], p[0]
+		presets := p[1].Presets
+		presets[0], presets[2] = presets[2], presets[0]
+	}
+
+	return attr
+}
+
+func TestMarshalJSONGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		value  any
+	}{
+		{name: "attribute", golden: "attribute.json", value: newGoldenAttribute(false)},
+		{name: "attribute in reversed order", golden: "attribute.json", value: newGoldenAttribute(true)},
+		{
+			name:   "entry",
+			golden: "entry.json",
+			value: &Entry{
+				ID:                5,
+				Attribute:         newGoldenAttribute(true),
+				DateTime:          NewDateTime("2024-03-10T12:00:00"),
+				DateTimeExecution: NewDateTime("2024-03-10T11:55:00"),
+				Parameters:        []EntryParameter{{ParameterID: 10, Preset: "Kitchen"}, {ParameterID: 11, Value: "80"}},
+				Creator:           3,
+				Note:              "my note",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.value, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to marshal: %s", err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", tt.golden)
+			if *updateGolden {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatalf("Failed to update the golden file: %s", err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read the golden file: %s", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("JSON differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
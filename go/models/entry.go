@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"strconv"
-	"strings"
 	"sync/atomic"
 	"time"
 
@@ -38,6 +37,11 @@ type Entry struct {
 	// The ID of the token which created the entry
 	Creator int `json:"creator"`
 
+	// A free text note of the user for their own bookkeeping. It's stored by the server
+	// and has no influence on the execution.
+	// The field is omitted when empty, so that servers without support for notes are not affected
+	Note string `json:"note,omitempty" cli:"--note,-nt"`
+
 	// Creation or updating only attributes //
 
 	Message ResponseMessage `json:"message"`
@@ -69,6 +73,11 @@ type Entry struct {
 	//        "2021-01-Mo2T20:00:00" (week on a montly basis)
 	OffsetPattern string `json:"offset_pattern" cli:"--datePattern,-dp"`
 
+	// Creation only: whether a new entry was created ([StatusCreated]) or an identical
+	// entry did already exist ([StatusExists]). The status is only parsed from the response
+	// of a single creation if the server includes it. Otherwise, [StatusCreated] is assumed
+	CreationStatus BulkResponseStatus `json:"-"`
+
 	// Exec Response //
 
 	// Creation only (exec response): The maximum time to wait in seconds for a response (max: 60 seconds)
@@ -108,25 +117,43 @@ type EntryParameter struct {
 	// Name of the parameter preset to use. This does override the 'value' property if set.
 	// You can use this field to make sure that a preset is correctly used for creation / upate
 	Preset string `json:"preset"`
+
+	// Creation only: name of the attribute parameter to which this value belongs.
+	// Because the server identifies the parameters by their position, the name is resolved
+	// by the client to the correct position before sending (see [Entry.ResolveParameterNames])
+	ParameterName string `json:"-"`
 }
 
 // NewEntry decodes the JSON response of the given reader
-// to a new Entry
+// to a new Entry.
+// An empty body (e.g. "204 No Content") results in an empty value
 func NewEntry(r io.Reader) *Entry {
 	var ent Entry
 
 	if err := json.NewDecoder(r).Decode(&ent); err != nil {
-		logger.Warning("Failed to decode entry: %s", err)
-	}
+		// An empty body results in an empty entry
+		if err != io.EOF {
+			logger.Warning("Failed to decode entry: %s", err)
+		}
 
-	// Initialize pointer value
-	if ent.execution == nil {
-		ent.execution = &struct{ WasExecuted atomic.Bool }{}
+		// "UnmarshalJSON()" was not called
+		ent.InitExecution()
 	}
 
 	return &ent
 }
 
+// InitExecution initializes the execution state of the entry if it's not
+// initialized yet.
+// This is done for every decoding path within "UnmarshalJSON()". The
+// initialization is only required for entries that weren't decoded from JSON
+// (e.g. with "encoding/gob")
+func (e *Entry) InitExecution() {
+	if e.execution == nil {
+		e.execution = &struct{ WasExecuted atomic.Bool }{}
+	}
+}
+
 // ToJson marshals this entry to a json string represented in bytes
 func (e *Entry) ToJson() []byte {
 	rtc, err := json.Marshal(e)
@@ -151,12 +178,34 @@ func (e *Entry) UnmarshalJSON(data []byte) error {
 	}
 	*e = Entry(ee)
 
-	// Initialize pointer value
-	e.execution = &struct{ WasExecuted atomic.Bool }{}
+	// Initialize pointer value. This is also called for every element of an
+	// array or nested entries like in a bulk response
+	e.execution = nil
+	e.InitExecution()
 
 	return nil
 }
 
+// Clone returns a deep copy of the entry including its attribute.
+// The execution state is copied into a new state, so that changes of the
+// clone don't affect this entry
+func (e *Entry) Clone() *Entry {
+	rtc := *e
+
+	if e.Attribute != nil {
+		rtc.Attribute = e.Attribute.Clone()
+	}
+	if e.Parameters != nil {
+		rtc.Parameters = append([]EntryParameter(nil), e.Parameters...)
+	}
+
+	rtc.execution = nil
+	rtc.InitExecution()
+	rtc.execution.WasExecuted.Store(e.WasExecuted())
+
+	return &rtc
+}
+
 // DontIncludeParametersInRequest "omits" the field "Parameters" for patch API requests.
 // This is a hack to keep the old parameters when no new parameters should be applied.
 //
@@ -182,22 +231,138 @@ func (e Entry) String() string {
 		}
 	}
 
+	note := ""
+	if e.Note != "" {
+		note = fmt.Sprintf("Note:       %s\n", e.Note)
+	}
+
 	return fmt.Sprintf(
 		`_____ %s (%d) _____
 Parameter:  %s
 Attribute:  %s
 Execution:  %s
-`, e.DateTime.FormatPretty(), e.ID, parameter, e.Attribute.Name, e.DateTimeExecution.FormatPretty(),
+%s`, e.DateTime.FormatPretty(), e.ID, parameter, e.Attribute.Name, e.DateTimeExecution.FormatPretty(), note,
 	)
 }
 
+// EntryColumns selects the optional columns of an entry within the csv output.
+// These columns are not contained by default to not break consumers relying on the
+// number of columns
+type EntryColumns struct {
+	// The note of the entry
+	Note bool
+
+	// The resolved values of all parameters. They are following all other columns
+	Parameters bool
+
+	// Print the name of the parameter preset instead of its resolved value for
+	// parameters using a preset. This implies "Parameters"
+	Presets bool
+}
+
+// ToSlice returns the relevant fields of the entry
 func (e Entry) ToSlice() []string {
-	return []string{
+	return e.ToSliceWith(EntryColumns{})
+}
+
+// ToSliceWith is like [Entry.ToSlice] but contains the given optional columns
+// after the fixed fields
+func (e Entry) ToSliceWith(columns EntryColumns) []string {
+	rtc := []string{
 		fmt.Sprintf("%d", e.ID),
 		e.DateTime.Format(TimeFormat),
 		e.Attribute.Name,
 		e.DateTimeExecution.Format(TimeFormat),
 	}
+	if columns.Note {
+		rtc = append(rtc, e.Note)
+	}
+
+	if columns.Parameters || columns.Presets {
+		for _, p := range e.Parameters {
+			if columns.Presets {
+				rtc = append(rtc, p.GetDisplay(e.Attribute, false))
+			} else {
+				rtc = append(rtc, p.GetValue(e.Attribute))
+			}
+		}
+	}
+
+	return rtc
+}
+
+// Headers returns the names of the fields of [Entry.ToSlice]
+func (e Entry) Headers() []string {
+	return EntryColumns{}.Headers()
+}
+
+// Headers returns the names of the fixed fields of [Entry.ToSliceWith] for these columns.
+// The values of the parameters (if selected) are following these fields
+func (c EntryColumns) Headers() []string {
+	rtc := []string{"id", "date_time", "attribute", "date_time_execution"}
+	if c.Note {
+		rtc = append(rtc, "note")
+	}
+
+	return rtc
+}
+
+// HasNamedParameters returns whether any parameter of this entry is addressed
+// by its name instead of its position
+func (e *Entry) HasNamedParameters() bool {
+	for _, p := range e.Parameters {
+		if p.ParameterName != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveParameterNames moves all parameters that are addressed by their name to
+// the position of the parameter within the attribute of the entry. Parameters without
+// a name keep their position.
+// The attribute of the entry has to contain the parameter definitions. An error is returned
+// if a name is unknown or multiple values are given for the same position
+func (e *Entry) ResolveParameterNames() error {
+	if !e.HasNamedParameters() {
+		return nil
+	}
+	if e.Attribute == nil {
+		return fmt.Errorf("an attribute is required to resolve parameters by their name")
+	}
+
+	// Parameters by their position (indexed by 0)
+	resolved := make(map[int]EntryParameter, len(e.Parameters))
+	maxPosition := -1
+
+	for i, p := range e.Parameters {
+		position := i
+		if p.ParameterName != "" {
+			ap, ok := e.Attribute.ParameterByName(p.ParameterName)
+			if !ok {
+				return fmt.Errorf("the attribute %q has no parameter with the name %q", e.Attribute.Name, p.ParameterName)
+			}
+			position = ap.Position - 1
+			p.ParameterID = ap.ID
+		}
+
+		if _, exists := resolved[position]; exists {
+			return fmt.Errorf("multiple values were given for the parameter at position %d", position+1)
+		}
+		resolved[position] = p
+		if position > maxPosition {
+			maxPosition = position
+		}
+	}
+
+	// Fill gaps with empty parameters
+	e.Parameters = make([]EntryParameter, maxPosition+1)
+	for position, p := range resolved {
+		e.Parameters[position] = p
+	}
+
+	return nil
 }
 
 // GetParameterValue returns the value of this parameter that should be
@@ -205,26 +370,34 @@ func (e Entry) ToSlice() []string {
 // This returns either the predefined parameter value or the raw value
 func (ep *EntryParameter) GetValue(attribute *Attribute) string {
 	if attribute != nil && ep.Preset != "" {
-		for _, p := range attribute.Parameter {
-			// Find parameter by id
-			if p.ID == ep.ParameterID {
-				// Find preset for this parameter
-				for _, pp := range p.Presets {
-					if strings.EqualFold(pp.Name, ep.Preset) {
-						return pp.Value
-					}
-				}
-				logger.Warning("No parameter preset found within the attribute %q: %q", attribute.Name, ep.Preset)
-			}
+		if preset := ep.findPreset(attribute); preset != nil {
+			return preset.Value
 		}
 
-		logger.Warning("No parameter with id %d found within the attribute %q: %q", ep.ParameterID, attribute.Name, ep.Preset)
 		return ""
 	} else {
 		return ep.GetParameter()
 	}
 }
 
+// findPreset returns the parameter preset of the attribute that is referenced
+// by this parameter. If no preset could be found, a warning is logged and nil is returned
+func (ep *EntryParameter) findPreset(attribute *Attribute) *ParameterPreset {
+	p, ok := attribute.ParameterByID(ep.ParameterID)
+	if !ok {
+		logger.Warning("No parameter with id %d found within the attribute %q: %q", ep.ParameterID, attribute.Name, ep.Preset)
+		return nil
+	}
+
+	pp, ok := p.PresetByName(ep.Preset)
+	if !ok {
+		logger.Warning("No parameter preset found within the attribute %q: %q", attribute.Name, ep.Preset)
+		return nil
+	}
+
+	return pp
+}
+
 // GetParameter returns the raw parameter value of the field "Parameter".
 // Null values are returned as an empty string
 func (ep *EntryParameter) GetParameter() string {
@@ -239,26 +412,12 @@ func (ep *EntryParameter) GetDisplay(attribute *Attribute, short bool) string {
 		return ep.GetParameter()
 	} else if !short {
 		return ep.Preset
-	} else {
-		for _, p := range attribute.Parameter {
-			// Find parameter by id
-			if p.ID == ep.ParameterID {
-				// Find preset for this parameter
-				for _, pp := range p.Presets {
-					if strings.EqualFold(pp.Name, ep.Preset) {
-						if pp.ShortName == "" {
-							return pp.Name
-						} else {
-							return pp.ShortName
-						}
-					}
-				}
-				logger.Warning("No parameter preset found within the attribute %q: %q", attribute.Name, ep.Preset)
-			}
-		}
-
-		logger.Warning("No parameter with id %d found within the attribute %q: %q", ep.ParameterID, attribute.Name, ep.Preset)
+	} else if pp := ep.findPreset(attribute); pp == nil {
 		return ep.Preset
+	} else if pp.ShortName == "" {
+		return pp.Name
+	} else {
+		return pp.ShortName
 	}
 }
 
@@ -277,6 +436,17 @@ func (e *Entry) IsPast(ignoreExecutionTime bool) bool {
 		(ignoreExecutionTime || e.DateTimeExecution.IsZero() || e.DateTimeExecution.Before(time.Now()))
 }
 
+// PastTime returns the time after which all time fields of this entry
+// lie in the past (see [Entry.IsPast]).
+// You can specify if the execution time should be ignored
+func (e *Entry) PastTime(ignoreExecutionTime bool) time.Time {
+	if !ignoreExecutionTime && e.DateTimeExecution.After(e.DateTime.Time) {
+		return e.DateTimeExecution.Time
+	}
+
+	return e.DateTime.Time
+}
+
 // WasExecuted states weather this entry was already executed
 // in an execution context.
 // Only use this function if the entry was created from the API and was not cloned!
@@ -304,6 +474,12 @@ func (e *Entry) SetExecuted(executed bool) {
 //   - the execution time of the entry is past and the attribute is of type exec_always
 //   - the execution time (dateTime - now()) is within an offset of +0.5 seconds / -2.0 seconds
 func (e *Entry) ShouldExecuteNow() bool {
+	return e.ShouldExecuteNowOn(false)
+}
+
+// ShouldExecuteNowOn is like [Entry.ShouldExecuteNow], but the field "DateTimeExecution"
+// is ignored when "ignoreExecutionTime" is set. The entry is then executed on its DateTime
+func (e *Entry) ShouldExecuteNowOn(ignoreExecutionTime bool) bool {
 
 	// If the entry got already executed return false
 	if e.WasExecuted() {
@@ -312,7 +488,7 @@ func (e *Entry) ShouldExecuteNow() bool {
 
 	// DateTime to use
 	dateTime := e.DateTime
-	if !e.DateTimeExecution.IsZero() {
+	if !ignoreExecutionTime && !e.DateTimeExecution.IsZero() {
 		dateTime = e.DateTimeExecution
 	}
 
@@ -343,6 +519,78 @@ func (e *Entry) GetExecutionTime(ignoreExecutionTime bool) time.Time {
 	}
 }
 
+// Identity returns a short description of the entry to identify it in log messages.
+// It consists of the ID (if any), the attribute, the date and the parameters
+func (e *Entry) Identity() string {
+	attribute := "<none>"
+	if e.Attribute != nil && e.Attribute.Name != "" {
+		attribute = fmt.Sprintf("%q", e.Attribute.Name)
+	} else if e.Attribute != nil {
+		attribute = fmt.Sprintf("#%d", e.Attribute.ID)
+	}
+
+	parameters := make([]string, len(e.Parameters))
+	for i := range e.Parameters {
+		parameters[i] = e.Parameters[i].GetParameter()
+	}
+
+	rtc := fmt.Sprintf("attribute %s at %s with parameters %q", attribute, e.DateTime.FormatPretty(), parameters)
+	if e.ID != 0 {
+		rtc = fmt.Sprintf("#%d (%s)", e.ID, rtc)
+	}
+
+	return rtc
+}
+
+// NextRun returns the effective execution time of the entry (DateTimeExecution
+// or DateTime) and whether this time lies in the future.
+// Use this after creating an entry to check when the server scheduled the execution
+func (e *Entry) NextRun() (time.Time, bool) {
+	t := e.GetExecutionTime(false)
+	return t, !t.IsZero() && t.After(time.Now())
+}
+
+// CompareExecution compares the scheduling order of this entry with the
+// given one. A negative value is returned if this entry should be scheduled
+// before the other entry, a positive value if the other entry should be scheduled
+// first and zero if no entry takes precedence.
+//
+// The entries are compared by the time on which they have to be handled next (see [Entry.NextScheduleTime])
+func (e *Entry) CompareExecution(other *Entry, ignoreExecTime bool) int {
+	return e.NextScheduleTime(ignoreExecTime).Compare(other.NextScheduleTime(ignoreExecTime))
+}
+
+// NextScheduleTime returns the time on which the entry has to be handled next by the scheduler.
+// This is the execution time, if the entry wasn't executed yet and the execution time is before
+// the DateTime. Otherwise, it's the DateTime (e.g. an already executed entry waiting for its DateTime)
+func (e *Entry) NextScheduleTime(ignoreExecTime bool) time.Time {
+	if !e.WasExecuted() && e.GetExecutionTime(ignoreExecTime).Before(e.DateTime.Time) {
+		return e.GetExecutionTime(ignoreExecTime)
+	}
+
+	return e.DateTime.Time
+}
+
+// OrderEntriesByIDs returns the entries with the given IDs in the same order as the IDs.
+// IDs for which no entry is contained in the given entries are returned as missing
+func OrderEntriesByIDs(entries []*Entry, ids []int) (ordered []*Entry, missing []int) {
+	byID := make(map[int]*Entry, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+
+	ordered = make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := byID[id]; ok {
+			ordered = append(ordered, e)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return
+}
+
 // SetFullMinutes sets the flag "fullMinutes" to 'true'
 func (e *Entry) SetFullMinutes() string {
 	e.FullMinutes = true
@@ -355,6 +603,17 @@ func (e *Entry) SetKeepDate() string {
 	return ""
 }
 
+// SetOffset sets the field "offset" to the given value after validating it
+func (e *Entry) SetOffset(val string) string {
+	offset, err := NormalizeOffset(val)
+	if err != nil {
+		return err.Error()
+	}
+	e.Offset = offset
+
+	return ""
+}
+
 // SetTimeout sets the field "timeout" to the given value
 func (e *Entry) SetTimeout(val string) string {
 	if n, err := strconv.Atoi(val); err != nil {
@@ -366,6 +625,18 @@ func (e *Entry) SetTimeout(val string) string {
 	return ""
 }
 
+// IsImmediateExecResponse returns whether this entry was executed immediately
+// during its creation and contains the execution response of the attribute type "exec_response".
+// This is the case if delayed executions are not allowed for the attribute or the
+// server returned an execution response
+func (e *Entry) IsImmediateExecResponse() bool {
+	if e.Attribute == nil || !e.Attribute.IsExecResponse() {
+		return false
+	}
+
+	return !e.Attribute.ExecResponse.AllowDelayedExecution || e.ExecutionResponseId != 0
+}
+
 // ExecutionResponse returns a nicely formatted string of the
 // execution response if the attribute of the entry was of the type
 // "exec response"
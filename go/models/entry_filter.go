@@ -2,6 +2,10 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +23,10 @@ type EntryFilter struct {
 	// Only entries that are having an attribute included in the list
 	Attributes []int `json:"attribute"`
 
+	// IDs or names of attributes that are resolved to their ID and added
+	// to "Attributes" by [EntryFilter.ResolveAttributes]
+	AttributeNames []string `json:"-"`
+
 	// Filter for the value or after the name of a parameter preset.
 	// The parameter ID is determined by its position in the provided Array.
 	// A null value for a member in this array means that the parameter at the arrays
@@ -28,6 +36,11 @@ type EntryFilter struct {
 	// only entries which do not have any parameter / all parameters are null will be returned
 	Parameters *[]NullString `json:"parameters"`
 
+	// Filter only after the name of a parameter preset (regardless of the presets value).
+	// The behaviour of null values and the position is the same as for "Parameters".
+	// An empty string ("") means that the parameter must not use a preset
+	ParameterPresets *[]NullString `json:"parameter_presets"`
+
 	// Only entries that were created by the given ID of the API key are returned
 	Creator int `json:"creator"`
 
@@ -57,6 +70,10 @@ type EntryFilter struct {
 	// The maximum amount of entries to return. Maximum value are 200
 	MaxEntries int `json:"max_entries" cli:"--max,-m"`
 
+	// Number of matching entries to skip. Use this together with "MaxEntries"
+	// to fetch the entries page by page
+	Offset int `json:"offset"`
+
 	// A list of entries with the flag "execute_always" that has already been
 	// executed from this client
 	Executed []int `json:"executed"`
@@ -66,16 +83,62 @@ type EntryFilter struct {
 	//  1 = "date_time > now()"
 	//  2 = "date_time_execution > now()"
 	IgnoreExecutionDate int
+
+	// Attributes fetched for resolving the attribute names
+	attributeCache []*Attribute
+}
+
+// AttributeProvider provides all attributes of the user.
+// It's implemented by the API and the persistence layer
+type AttributeProvider interface {
+	GetAttributes() ([]*Attribute, *ErrorResponse)
+}
+
+// ResolveAttributes resolves the IDs or names within "AttributeNames" to the ID of the
+// attribute and adds them to the field "Attributes". The names are compared case-insensitive
+// if no attribute matches exactly.
+// The attributes are fetched only once from the provider for this filter.
+// An error is returned if an attribute couldn't be found
+func (e *EntryFilter) ResolveAttributes(provider AttributeProvider) error {
+	if len(e.AttributeNames) == 0 {
+		return nil
+	}
+
+	if e.attributeCache == nil {
+		attributes, err := provider.GetAttributes()
+		if err != nil {
+			return fmt.Errorf("failed to fetch available attributes: %s", err)
+		}
+		e.attributeCache = attributes
+	}
+
+	for _, val := range e.AttributeNames {
+		attr := FindAttributeByIdOrName(e.attributeCache, val)
+		if attr == nil {
+			return fmt.Errorf("no attribute found for id / name %q", val)
+		}
+
+		e.Attributes = append(e.Attributes, attr.ID)
+	}
+
+	// The names are resolved now
+	e.AttributeNames = nil
+
+	return nil
 }
 
 func (e *EntryFilter) ToJson() []byte {
 
 	// Replace a NULL parameter with the search string for any parameter
-	if e.Parameters != nil {
-		for i, p := range *e.Parameters {
+	for _, params := range []*[]NullString{e.Parameters, e.ParameterPresets} {
+		if params == nil {
+			continue
+		}
+
+		for i, p := range *params {
 			// Null values are replaced by any value
 			if !p.Valid {
-				(*e.Parameters)[i] = NewNullString(ParameterAnyValue)
+				(*params)[i] = NewNullString(ParameterAnyValue)
 			}
 		}
 	}
@@ -90,15 +153,87 @@ func (e *EntryFilter) ToJson() []byte {
 }
 
 // CanHandleLocally returns whether the filtering can be handled
-// locally without calling the API by simple "==" comparisons
+// locally without calling the API by simple "==" comparisons.
+// The date conditions ("DatePattern", "LaterThan" and "EarlierThan") are evaluated
+// locally based on the client time zone (like the dates of the entries)
 func (e *EntryFilter) CanHandleLocally() bool {
 	return true &&
-		e.DatePattern == "" &&
-		e.LaterThan == "" &&
-		e.EarlierThan == "" &&
+		e.canMatchDatesLocally() &&
+		e.Offset == 0 &&
 		!e.OldDates // No old dates are fetched by default
 }
 
+// CacheKey returns a deterministic string representation of this filter in the format
+// of a URL query. Two filters with the same conditions return the same key, regardless
+// of the order of the IDs or attributes. This can be used as the key of a cache or
+// for logging the filter
+func (e *EntryFilter) CacheKey() string {
+	v := url.Values{}
+
+	addInts := func(key string, values []int) {
+		if len(values) == 0 {
+			return
+		}
+
+		sorted := append([]int(nil), values...)
+		sort.Ints(sorted)
+		str := make([]string, 0, len(sorted))
+		for i, val := range sorted {
+			if i == 0 || val != sorted[i-1] {
+				str = append(str, strconv.Itoa(val))
+			}
+		}
+		v.Set(key, strings.Join(str, ","))
+	}
+	addInts("ids", e.IDs)
+	addInts("attribute", e.Attributes)
+	addInts("ignore_execute_always_attribute", e.IgnoreEAAttribute)
+	addInts("executed", e.Executed)
+
+	if len(e.AttributeNames) > 0 {
+		names := append([]string(nil), e.AttributeNames...)
+		sort.Strings(names)
+		v["attribute_names"] = names
+	}
+
+	// The position of the parameters is relevant. A null value matches any value
+	for key, params := range map[string]*[]NullString{"parameters": e.Parameters, "parameter_presets": e.ParameterPresets} {
+		if params == nil {
+			continue
+		}
+
+		values := make([]string, len(*params))
+		for i, p := range *params {
+			if p.Valid {
+				values[i] = p.String
+			} else {
+				values[i] = ParameterAnyValue
+			}
+		}
+		encoded, _ := json.Marshal(values)
+		v.Set(key, string(encoded))
+	}
+
+	for key, val := range map[string]string{"pattern": e.DatePattern, "later_than": e.LaterThan, "earlier_than": e.EarlierThan} {
+		if val != "" {
+			v.Set(key, val)
+		}
+	}
+	for key, val := range map[string]int{"creator": e.Creator, "max_entries": e.MaxEntries, "offset": e.Offset, "ignore_execution_date": e.IgnoreExecutionDate} {
+		if val != 0 {
+			v.Set(key, strconv.Itoa(val))
+		}
+	}
+	for key, val := range map[string]bool{"old_dates": e.OldDates, "ignore_execute_always": e.IgnoreEA} {
+		if val {
+			v.Set(key, "true")
+		}
+	}
+
+	// The keys are sorted while encoding
+	return v.Encode()
+}
+
 // IsZero checks if this filter is empty and contains
 // no filter condition
 func (e *EntryFilter) IsZero() bool {
@@ -106,6 +241,7 @@ func (e *EntryFilter) IsZero() bool {
 		len(e.IDs) == 0 &&
 		len(e.Attributes) == 0 &&
 		e.Parameters == nil &&
+		e.ParameterPresets == nil &&
 		e.Creator == 0 &&
 		e.DatePattern == "" &&
 		e.LaterThan == "" &&
@@ -114,6 +250,7 @@ func (e *EntryFilter) IsZero() bool {
 		!e.IgnoreEA &&
 		len(e.IgnoreEAAttribute) == 0 &&
 		e.MaxEntries == 0 &&
+		e.Offset == 0 &&
 		len(e.Executed) == 0 &&
 		e.IgnoreExecutionDate == 0
 }
@@ -121,8 +258,27 @@ func (e *EntryFilter) IsZero() bool {
 // DoesMatch checks if the filter matches for the given entry.
 // Note that a correct result is only returned if all fields
 // can be handled locally.
-// Use the function "CanHandleLocally()" to check that
+// Use the function "CanHandleLocally()" to check that.
+// To match multiple entries, use [EntryFilter.Matcher]
 func (e *EntryFilter) DoesMatch(ent Entry) bool {
+	return e.doesMatchAt(ent, e.resolveDates(time.Now()))
+}
+
+// Matcher returns a function that checks if the filter matches for the given entry
+// like [EntryFilter.DoesMatch]. The date conditions are resolved only once relative
+// to the current time, so that all entries are compared against the same time.
+// Use a new matcher for every evaluation of the filter
+func (e *EntryFilter) Matcher() func(ent Entry) bool {
+	dates := e.resolveDates(time.Now())
+
+	return func(ent Entry) bool {
+		return e.doesMatchAt(ent, dates)
+	}
+}
+
+// doesMatchAt checks if the filter matches for the given entry using
+// the date conditions resolved relative to a single base time
+func (e *EntryFilter) doesMatchAt(ent Entry, dates *filterDates) bool {
 
 	// Validate that the entry is contained in the provided filter list
 	if len(e.IDs) != 0 {
@@ -173,11 +329,8 @@ func (e *EntryFilter) DoesMatch(ent Entry) bool {
 				// Both the filter parameter and entry parameter are null
 			} else if ent.Attribute != nil && p.Preset != "" && len(ent.Attribute.Parameter) > i {
 				// Check if the value equals the value of the parameter preset of the entry
-				for _, app := range ent.Attribute.Parameter[i].Presets {
-					// Find preset with the name
-					if strings.EqualFold(app.Name, p.Preset) {
-						return app.Value == filterP.String
-					}
+				if app, ok := ent.Attribute.Parameter[i].PresetByName(p.Preset); ok {
+					return app.Value == filterP.String
 				}
 
 				return false
@@ -187,6 +340,26 @@ func (e *EntryFilter) DoesMatch(ent Entry) bool {
 		}
 	}
 
+	// Validate parameter presets
+	if e.ParameterPresets != nil {
+		for i, p := range ent.Parameters {
+			// No parameter to compare against anymore → the parameters are equal
+			if i >= len(*e.ParameterPresets) {
+				break
+			}
+			filterP := (*e.ParameterPresets)[i]
+
+			// Accept any preset
+			if !filterP.Valid || filterP.String == ParameterAnyValue {
+				continue
+			}
+
+			if !strings.EqualFold(p.Preset, filterP.String) {
+				return false
+			}
+		}
+	}
+
 	// Validate creator
 	if e.Creator != 0 {
 		if e.Creator != ent.Creator {
@@ -194,6 +367,11 @@ func (e *EntryFilter) DoesMatch(ent Entry) bool {
 		}
 	}
 
+	// Validate the date conditions
+	if !e.doesDateMatch(&ent, dates) {
+		return false
+	}
+
 	// Ignore entries with the flag EA that are laying in the past
 	shouldIgnoreEA := e.IgnoreEA
 	for _, e := range e.IgnoreEAAttribute {
@@ -202,17 +380,18 @@ func (e *EntryFilter) DoesMatch(ent Entry) bool {
 		}
 	}
 
-	// Check the execution date
+	// Check the execution date relative to the same time as the date conditions
+	now := dates.base
 	if e.IgnoreExecutionDate == 0 {
-		if ent.DateTime.Time.Before(time.Now()) && ent.DateTimeExecution.Time.Before(time.Now()) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
+		if ent.DateTime.Time.Before(now) && ent.DateTimeExecution.Time.Before(now) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
 			return false
 		}
 	} else if e.IgnoreExecutionDate == 1 {
-		if ent.DateTime.Time.Before(time.Now()) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
+		if ent.DateTime.Time.Before(now) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
 			return false
 		}
 	} else if e.IgnoreExecutionDate == 2 {
-		if ent.DateTimeExecution.Time.Before(time.Now()) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
+		if ent.DateTimeExecution.Time.Before(now) && (!ent.Attribute.ExecuteAlways || shouldIgnoreEA) {
 			return false
 		}
 	}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// datePatternMatcher matches the date of an entry against the "DatePattern" of an [EntryFilter]
+type datePatternMatcher struct {
+	// For an offset ("/2h"), all dates between these times are matching
+	from time.Time
+	to   time.Time
+
+	// For a pattern, the resolved date the fields are compared to
+	resolved time.Time
+	// Regular expressions for the fields (year, month, day, hour, minute, second)
+	// containing a wildcard. Nil for fields without a wildcard
+	wildcards [6]*regexp.Regexp
+
+	isRange bool
+}
+
+// newDatePatternMatcher parses the given date pattern of a filter relative to the base time.
+// The pattern is either an offset like "/2h" (all dates between now and the offset) or an offset
+// pattern (see [ParseOffsetPattern]) whose fields can contain the wildcards "." (a single digit)
+// and "*" (any number of digits)
+func newDatePatternMatcher(base time.Time, pattern string) (*datePatternMatcher, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if offsetRegex.MatchString(pattern) {
+		t, err := ParseOffset(base, pattern, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Before(base) {
+			return &datePatternMatcher{from: t, to: base, isRange: true}, nil
+		}
+		return &datePatternMatcher{from: base, to: t, isRange: true}, nil
+	}
+
+	datePart, timePart, found := strings.Cut(pattern, "t")
+	if !found {
+		return nil, fmt.Errorf("invalid date pattern %q", pattern)
+	}
+	dateFields := strings.Split(datePart, "-")
+	timeFields := strings.Split(timePart, ":")
+	if len(timeFields) != 3 {
+		return nil, fmt.Errorf("invalid time of date pattern %q", pattern)
+	}
+
+	rtc := &datePatternMatcher{}
+
+	// Only a full date can contain wildcards
+	fields := timeFields
+	offset := 3
+	if len(dateFields) == 3 {
+		fields = append(dateFields, timeFields...)
+		offset = 0
+	}
+	for i, f := range fields {
+		if !strings.ContainsAny(f, ".*") {
+			continue
+		}
+
+		regex, err := regexp.Compile("^" + strings.NewReplacer(".", "[0-9]", "*", "[0-9]*").Replace(f) + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid wildcard %q in date pattern: %s", f, err)
+		}
+		rtc.wildcards[i+offset] = regex
+
+		// The field is resolved with the value of the base time
+		fields[i] = "+0"
+	}
+	if len(dateFields) == 3 {
+		datePart = strings.Join(fields[:3], "-")
+	}
+	timePart = strings.Join(fields[len(fields)-3:], ":")
+
+	resolved, err := ParseOffsetPattern(base, datePart+"T"+timePart, false)
+	if err != nil {
+		return nil, err
+	}
+	rtc.resolved = resolved
+
+	return rtc, nil
+}
+
+// matches returns whether the given date matches the pattern
+func (m *datePatternMatcher) matches(date time.Time) bool {
+	if m.isRange {
+		return !date.Before(m.from) && !date.After(m.to)
+	}
+
+	// The fields are compared within the time zone of the base time
+	actual := dateFields(date.In(m.resolved.Location()))
+	expected := dateFields(m.resolved)
+	for i := range actual {
+		if m.wildcards[i] != nil {
+			format := "%02d"
+			if i == 0 {
+				format = "%04d"
+			}
+			if !m.wildcards[i].MatchString(fmt.Sprintf(format, actual[i])) {
+				return false
+			}
+		} else if actual[i] != expected[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dateFields returns the year, month, day, hour, minute and second of the given time
+func dateFields(t time.Time) [6]int {
+	return [6]int{t.Year(), int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second()}
+}
+
+// resolveFilterDate resolves the value of "LaterThan" or "EarlierThan" of a filter.
+// This is either a date in the format [TimeFormat], an offset or an offset pattern
+func resolveFilterDate(base time.Time, value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(TimeFormat, value, base.Location()); err == nil {
+		return t, nil
+	}
+
+	if _, err := NormalizeOffset(value); err == nil {
+		return ParseOffset(base, value, false)
+	}
+
+	return ParseOffsetPattern(base, value, false)
+}
+
+// filterDates contains the date conditions of a filter resolved relative to a single base time
+type filterDates struct {
+	// Time the conditions were resolved relative to
+	base time.Time
+
+	// Matcher of the "DatePattern". Nil if no pattern is given or it couldn't be parsed
+	pattern *datePatternMatcher
+
+	// Resolved values of "LaterThan" and "EarlierThan". Zero if no value is given or
+	// it couldn't be resolved
+	laterThan   time.Time
+	earlierThan time.Time
+
+	// Whether all given date conditions could be resolved
+	valid bool
+}
+
+// resolveDates resolves the date conditions of the filter relative to the given base time
+func (e *EntryFilter) resolveDates(base time.Time) *filterDates {
+	rtc := &filterDates{base: base, valid: true}
+
+	if e.DatePattern != "" {
+		m, err := newDatePatternMatcher(base, e.DatePattern)
+		rtc.pattern = m
+		rtc.valid = rtc.valid && err == nil
+	}
+	for _, cond := range []struct {
+		value  string
+		target *time.Time
+	}{{e.LaterThan, &rtc.laterThan}, {e.EarlierThan, &rtc.earlierThan}} {
+		if cond.value == "" {
+			continue
+		}
+
+		t, err := resolveFilterDate(base, cond.value)
+		if err != nil {
+			rtc.valid = false
+			continue
+		}
+		*cond.target = t
+	}
+
+	return rtc
+}
+
+// canMatchDatesLocally returns whether the date conditions of the filter can be
+// evaluated locally
+func (e *EntryFilter) canMatchDatesLocally() bool {
+	return e.resolveDates(time.Now()).valid
+}
+
+// doesDateMatch returns whether the date of the given entry matches the resolved date
+// conditions of the filter. Conditions that cannot be evaluated locally are ignored
+func (e *EntryFilter) doesDateMatch(ent *Entry, dates *filterDates) bool {
+	if dates.pattern != nil && !dates.pattern.matches(ent.DateTime.Time) {
+		return false
+	}
+	if !dates.laterThan.IsZero() && !ent.DateTime.After(dates.laterThan) {
+		return false
+	}
+	if !dates.earlierThan.IsZero() && !ent.DateTime.Before(dates.earlierThan) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDoesDateMatch(t *testing.T) {
+	// The client runs in another time zone than the dates of the entries
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	base := time.Date(2024, time.January, 15, 12, 0, 0, 0, zone)
+	utc := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		date   time.Time
+		want   bool
+	}{
+		{name: "within the offset", filter: EntryFilter{DatePattern: "+2h"}, date: base.Add(time.Hour), want: true},
+		{name: "after the offset", filter: EntryFilter{DatePattern: "+2h"}, date: base.Add(3 * time.Hour)},
+		{name: "within the negative offset", filter: EntryFilter{DatePattern: "/2h"}, date: base.Add(-time.Hour), want: true},
+		{name: "wildcard in the time zone of the client", filter: EntryFilter{DatePattern: "2024-01-15T10:*:*"}, date: utc(15, 8, 30), want: true},
+		{name: "wildcard in the time zone of the entry", filter: EntryFilter{DatePattern: "2024-01-15T10:*:*"}, date: utc(15, 10, 30)},
+		{name: "day in the time zone of the client", filter: EntryFilter{DatePattern: "2024-01-16T*:*:*"}, date: utc(15, 23, 0), want: true},
+		{name: "single digit wildcard", filter: EntryFilter{DatePattern: "2024-01-1.T+0:00:00"}, date: utc(17, 10, 0), want: true},
+		{name: "later than a date of the client", filter: EntryFilter{LaterThan: "2024-01-15T10:00:00"}, date: utc(15, 8, 30), want: true},
+		{name: "earlier than a date of the client", filter: EntryFilter{LaterThan: "2024-01-15T10:00:00"}, date: utc(15, 7, 30)},
+		{name: "earlier than an offset", filter: EntryFilter{EarlierThan: "+1h"}, date: base.Add(30 * time.Minute), want: true},
+		{name: "later than an offset", filter: EntryFilter{EarlierThan: "+1h"}, date: base.Add(2 * time.Hour)},
+		{name: "invalid pattern is ignored", filter: EntryFilter{DatePattern: "invalid"}, date: base, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.doesDateMatch(&Entry{DateTime: DateTime{Time: tt.date}}, tt.filter.resolveDates(base)); got != tt.want {
+				t.Errorf("doesDateMatch() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter EntryFilter
+	}{
+		{name: "without date conditions"},
+		{name: "with date conditions", filter: EntryFilter{DatePattern: "+1h", LaterThan: "/1h", EarlierThan: "+0-+0-+0T+2:+0:+0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ent := Entry{Attribute: &Attribute{}, DateTime: DateTime{Time: time.Now().Add(50 * time.Millisecond)}}
+
+			if !tt.filter.CanHandleLocally() {
+				t.Fatalf("CanHandleLocally() = false, want true")
+			}
+			matches := tt.filter.Matcher()
+			time.Sleep(100 * time.Millisecond)
+
+			// All entries of an evaluation are compared against the time the matcher was created
+			if !matches(ent) {
+				t.Errorf("Matcher() = false for an entry that was in the future on creation, want true")
+			}
+
+			// A reused filter is compared against the current time
+			if tt.filter.DoesMatch(ent) {
+				t.Errorf("DoesMatch() = true for a past entry, want false")
+			}
+			if tt.filter.Matcher()(ent) {
+				t.Errorf("Matcher() = true for a past entry, want false")
+			}
+		})
+	}
+
+	// Invalid conditions can't be handled locally
+	invalid := EntryFilter{LaterThan: "invalid"}
+	if invalid.CanHandleLocally() {
+		t.Errorf("CanHandleLocally() = true for an invalid date, want false")
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nullStrings returns a pointer to the given values converted to null strings
+func nullStrings(values ...string) *[]NullString {
+	rtc := make([]NullString, len(values))
+	for i, v := range values {
+		rtc[i] = NewNullString(v)
+	}
+	return &rtc
+}
+
+func TestDoesMatchParameters(t *testing.T) {
+	attribute := &Attribute{ID: 1, Parameter: []AttributeParameter{{
+		ID:      1,
+		Presets: []ParameterPreset{{Name: "Kitchen", Value: "192.168.0.10"}},
+	}}}
+	date := DateTime{Time: time.Now().Add(time.Hour)}
+	withValue := Entry{ID: 1, Attribute: attribute, DateTime: date, Parameters: []EntryParameter{{Value: "192.168.0.10"}}}
+	withPreset := Entry{ID: 2, Attribute: attribute, DateTime: date, Parameters: []EntryParameter{{Preset: "Kitchen"}}}
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		// Whether the entry with the raw value and the entry with the preset matches
+		wantValue  bool
+		wantPreset bool
+	}{
+		{name: "parameter by value", filter: EntryFilter{Parameters: nullStrings("192.168.0.10")}, wantValue: true, wantPreset: true},
+		{name: "parameter by preset name", filter: EntryFilter{Parameters: nullStrings("kitchen")}, wantValue: false, wantPreset: true},
+		{name: "parameter with other value", filter: EntryFilter{Parameters: nullStrings("192.168.0.11")}},
+		{name: "parameter with any value", filter: EntryFilter{Parameters: nullStrings(ParameterAnyValue)}, wantValue: true, wantPreset: true},
+		{name: "preset name", filter: EntryFilter{ParameterPresets: nullStrings("KITCHEN")}, wantValue: false, wantPreset: true},
+		{name: "preset with the value of the preset", filter: EntryFilter{ParameterPresets: nullStrings("192.168.0.10")}},
+		{name: "preset not set", filter: EntryFilter{ParameterPresets: &[]NullString{{}}}, wantValue: true, wantPreset: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.DoesMatch(withValue); got != tt.wantValue {
+				t.Errorf("DoesMatch() of the entry with a value = %t, want %t", got, tt.wantValue)
+			}
+			if got := tt.filter.DoesMatch(withPreset); got != tt.wantPreset {
+				t.Errorf("DoesMatch() of the entry with a preset = %t, want %t", got, tt.wantPreset)
+			}
+		})
+	}
+}
+
+func TestEntryFilterParameterPresetsJson(t *testing.T) {
+	filter := EntryFilter{ParameterPresets: nullStrings("Kitchen", "")}
+
+	var got map[string]any
+	if err := json.Unmarshal(filter.ToJson(), &got); err != nil {
+		t.Fatalf("Failed to decode the filter: %s", err)
+	}
+
+	presets, _ := json.Marshal(got["parameter_presets"])
+	// A preset that is not set matches any preset
+	if want, _ := json.Marshal([]string{"Kitchen", ParameterAnyValue}); string(presets) != string(want) {
+		t.Errorf("parameter_presets = %s, want %s", presets, want)
+	}
+	if !strings.Contains(filter.CacheKey(), "Kitchen") {
+		t.Errorf("CacheKey() = %q doesn't contain the preset", filter.CacheKey())
+	}
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntryExecutionInitialized(t *testing.T) {
+	const entry = `{"id": 1, "attribute": {"id": 2}, "date_time": "2024-03-10T12:00:00"}`
+
+	tests := []struct {
+		name   string
+		decode func() ([]*Entry, error)
+	}{
+		{
+			name: "single entry",
+			decode: func() ([]*Entry, error) {
+				var ent Entry
+				err := json.Unmarshal([]byte(entry), &ent)
+				return []*Entry{&ent}, err
+			},
+		},
+		{
+			name: "NewEntry",
+			decode: func() ([]*Entry, error) {
+				return []*Entry{NewEntry(strings.NewReader(entry))}, nil
+			},
+		},
+		{
+			name: "NewEntry with an empty body",
+			decode: func() ([]*Entry, error) {
+				return []*Entry{NewEntry(strings.NewReader(""))}, nil
+			},
+		},
+		{
+			name: "NewEntry with an invalid body",
+			decode: func() ([]*Entry, error) {
+				return []*Entry{NewEntry(strings.NewReader("{"))}, nil
+			},
+		},
+		{
+			name: "array of entries",
+			decode: func() ([]*Entry, error) {
+				var ent []*Entry
+				err := json.Unmarshal([]byte("["+entry+","+entry+"]"), &ent)
+				return ent, err
+			},
+		},
+		{
+			name: "array of entry values",
+			decode: func() ([]*Entry, error) {
+				var values []Entry
+				err := json.Unmarshal([]byte("["+entry+"]"), &values)
+				return []*Entry{&values[0]}, err
+			},
+		},
+		{
+			name: "bulk response",
+			decode: func() ([]*Entry, error) {
+				var resp BulkResponse[Entry]
+				err := json.Unmarshal([]byte(`{"response": [{"status": "created", "code": 201, "data": `+entry+`}]}`), &resp)
+				return []*Entry{&resp.ResponseData[0].Data}, err
+			},
+		},
+		{
+			name: "WebSocket update",
+			decode: func() ([]*Entry, error) {
+				var msg WebSocketMessage
+				err := json.Unmarshal([]byte(`{"type": "update", "update": {"entry": {"created": [`+entry+`], "updated": [`+entry+`]}}}`), &msg)
+				return append(msg.Update.Entry.Created, msg.Update.Entry.Updated...), err
+			},
+		},
+		{
+			name: "clone",
+			decode: func() ([]*Entry, error) {
+				return []*Entry{(&Entry{ID: 1}).Clone()}, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := tt.decode()
+			if err != nil {
+				t.Fatalf("Failed to decode: %s", err)
+			}
+			if len(entries) == 0 {
+				t.Fatalf("No entries were decoded")
+			}
+
+			for _, e := range entries {
+				if e.execution == nil {
+					t.Fatalf("Execution state of entry #%d is not initialized", e.ID)
+				}
+
+				e.SetExecuted(true)
+				if !e.WasExecuted() {
+					t.Errorf("Entry #%d was not marked as executed", e.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestEntryToSliceWith(t *testing.T) {
+	attribute := &Attribute{Name: "light", Parameter: []AttributeParameter{{Presets: []ParameterPreset{{Name: "Kitchen", Value: "10"}}}}}
+	entry := Entry{
+		ID:         1,
+		Attribute:  attribute,
+		DateTime:   NewDateTime("2024-03-10T12:00:00"),
+		Note:       "my note",
+		Parameters: []EntryParameter{{Preset: "Kitchen"}},
+	}
+
+	tests := []struct {
+		name    string
+		columns EntryColumns
+		want    []string
+		headers []string
+	}{
+		{
+			name:    "default columns",
+			want:    []string{"1", "2024-03-10T12:00:00", "light", "0001-01-01T00:00:00"},
+			headers: []string{"id", "date_time", "attribute", "date_time_execution"},
+		},
+		{
+			name:    "note",
+			columns: EntryColumns{Note: true},
+			want:    []string{"1", "2024-03-10T12:00:00", "light", "0001-01-01T00:00:00", "my note"},
+			headers: []string{"id", "date_time", "attribute", "date_time_execution", "note"},
+		},
+		{
+			name:    "parameters",
+			columns: EntryColumns{Parameters: true},
+			want:    []string{"1", "2024-03-10T12:00:00", "light", "0001-01-01T00:00:00", "10"},
+			headers: []string{"id", "date_time", "attribute", "date_time_execution"},
+		},
+		{
+			name:    "presets",
+			columns: EntryColumns{Presets: true},
+			want:    []string{"1", "2024-03-10T12:00:00", "light", "0001-01-01T00:00:00", "Kitchen"},
+			headers: []string{"id", "date_time", "attribute", "date_time_execution"},
+		},
+		{
+			name:    "note and parameters",
+			columns: EntryColumns{Note: true, Parameters: true},
+			want:    []string{"1", "2024-03-10T12:00:00", "light", "0001-01-01T00:00:00", "my note", "10"},
+			headers: []string{"id", "date_time", "attribute", "date_time_execution", "note"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entry.ToSliceWith(tt.columns); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ToSliceWith() = %q, want %q", got, tt.want)
+			}
+			if got := tt.columns.Headers(); strings.Join(got, "|") != strings.Join(tt.headers, "|") {
+				t.Errorf("Headers() = %q, want %q", got, tt.headers)
+			}
+		})
+	}
+
+	// The default output doesn't contain any optional column
+	if got, want := entry.ToSlice(), entry.ToSliceWith(EntryColumns{}); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ToSlice() = %q, want %q", got, want)
+	}
+}
+
+func TestEntryPastTime(t *testing.T) {
+	dateTime := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		execution           time.Time
+		ignoreExecutionTime bool
+		want                time.Time
+	}{
+		{name: "without execution time", want: dateTime},
+		{name: "execution time before the date", execution: dateTime.Add(-time.Hour), want: dateTime},
+		{name: "execution time after the date", execution: dateTime.Add(time.Hour), want: dateTime.Add(time.Hour)},
+		{name: "ignored execution time", execution: dateTime.Add(time.Hour), ignoreExecutionTime: true, want: dateTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Entry{DateTime: DateTime{Time: dateTime}, DateTimeExecution: DateTime{Time: tt.execution}}
+			if got := e.PastTime(tt.ignoreExecutionTime); !got.Equal(tt.want) {
+				t.Errorf("PastTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,4 +8,13 @@ type Formattable interface {
 
 	// ToString returns "relevant" fields of the struct as a pretty string
 	String() string
+
+	// Headers returns the column names of the values returned by "ToSlice()"
+	Headers() []string
 }
+
+// Validate that all the models implement the interface
+var (
+	_ Formattable = (*Entry)(nil)
+	_ Formattable = (*Attribute)(nil)
+)
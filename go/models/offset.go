@@ -0,0 +1,231 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekdays maps the abbreviations used within an offset pattern to the weekday
+var weekdays = map[string]time.Weekday{
+	"mo": time.Monday,
+	"tu": time.Tuesday,
+	"we": time.Wednesday,
+	"th": time.Thursday,
+	"fr": time.Friday,
+	"sa": time.Saturday,
+	"su": time.Sunday,
+}
+
+// offsetRegex matches a simple offset to the current time like "+20m" or "now".
+// Only a single unit is supported, so "+1h30m" is rejected
+var offsetRegex = regexp.MustCompile(`^now$|^[+/][0-9]+[smhd]$`)
+
+// NormalizeOffset trims and lowercases the given offset to the current time (see [Entry.Offset])
+// and validates it. For malformed offsets an error describing the valid format is returned
+func NormalizeOffset(offset string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(offset))
+	if !offsetRegex.MatchString(normalized) {
+		return offset, fmt.Errorf("invalid offset %q. Expected 'now' or a number prefixed with '+' (or '/' for negative values) followed by one of the units 's', 'm', 'h' or 'd'. E.g.: '+20m'", offset)
+	}
+
+	return normalized, nil
+}
+
+// ParseOffset returns the time resulting from applying the given offset (see [Entry.Offset])
+// to the base time like the server does. With "fullMinutes" the seconds are set to zero
+func ParseOffset(base time.Time, offset string, fullMinutes bool) (time.Time, error) {
+	normalized, err := NormalizeOffset(offset)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	rtc := base
+	if normalized != "now" {
+		value, _ := strconv.Atoi(normalized[1 : len(normalized)-1])
+		if normalized[0] == '/' {
+			value = -value
+		}
+
+		switch normalized[len(normalized)-1] {
+		case 's':
+			rtc = base.Add(time.Duration(value) * time.Second)
+		case 'm':
+			rtc = base.Add(time.Duration(value) * time.Minute)
+		case 'h':
+			rtc = base.Add(time.Duration(value) * time.Hour)
+		case 'd':
+			rtc = base.AddDate(0, 0, value)
+		}
+	}
+
+	if fullMinutes {
+		rtc = time.Date(rtc.Year(), rtc.Month(), rtc.Day(), rtc.Hour(), rtc.Minute(), 0, 0, rtc.Location())
+	}
+
+	return rtc, nil
+}
+
+// ParseOffsetPattern returns the time resulting from applying the given offset pattern
+// (see [Entry.OffsetPattern]) to the base time.
+// Every field of the pattern "YYYY-MM-DDThh:mm:ss" can either be an absolute value, a positive
+// offset to the field of the base time (+2) or a negative one (/2). Instead of the date,
+// a weekday with a week offset can be given:
+//   - "Mo+1":       Monday of the next week (weeks start on Monday)
+//   - "2021-Mo2":   Monday of the calendar week 2 (ISO 8601) of the year 2021
+//   - "2021-01-Mo2": second Monday of January 2021
+//
+// When the time flows over the day (e.g. "+5" hours at 22:00), the day is changed
+// accordingly. With "keepDate" the date of the pattern is kept instead
+func ParseOffsetPattern(base time.Time, pattern string, keepDate bool) (time.Time, error) {
+	datePart, timePart, found := strings.Cut(strings.ToLower(strings.TrimSpace(pattern)), "t")
+	if !found {
+		return time.Time{}, fmt.Errorf("invalid offset pattern %q: expected a date and a time separated by 'T'", pattern)
+	}
+
+	date, err := parsePatternDate(base, datePart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date of offset pattern %q: %s", pattern, err)
+	}
+
+	timeFields := strings.Split(timePart, ":")
+	if len(timeFields) != 3 {
+		return time.Time{}, fmt.Errorf("invalid time of offset pattern %q: expected 'hh:mm:ss'", pattern)
+	}
+	var values [3]int
+	for i, baseValue := range []int{base.Hour(), base.Minute(), base.Second()} {
+		if values[i], err = parsePatternField(baseValue, timeFields[i]); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time of offset pattern %q: %s", pattern, err)
+		}
+	}
+
+	rtc := time.Date(date.Year(), date.Month(), date.Day(), values[0], values[1], values[2], 0, base.Location())
+
+	// Keep the date on an overflow of the time
+	if keepDate {
+		rtc = time.Date(date.Year(), date.Month(), date.Day(), rtc.Hour(), rtc.Minute(), rtc.Second(), 0, base.Location())
+	}
+
+	return rtc, nil
+}
+
+// parsePatternDate parses the date part of an offset pattern
+func parsePatternDate(base time.Time, datePart string) (time.Time, error) {
+	fields := strings.Split(datePart, "-")
+
+	switch len(fields) {
+	case 1:
+		// Weekday with an offset in weeks to the current week
+		weekday, offset, err := parsePatternWeekday(fields[0], true)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		monday := base.AddDate(0, 0, -daysSinceMonday(base.Weekday()))
+		return monday.AddDate(0, 0, offset*7+daysSinceMonday(weekday)), nil
+	case 2:
+		// Weekday of a calendar week
+		year, err := parsePatternField(base.Year(), fields[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+		weekday, week, err := parsePatternWeekday(fields[1], false)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		// The 4th of January is always within the first calendar week
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, base.Location())
+		firstMonday := jan4.AddDate(0, 0, -daysSinceMonday(jan4.Weekday()))
+		return firstMonday.AddDate(0, 0, (week-1)*7+daysSinceMonday(weekday)), nil
+	case 3:
+		year, err := parsePatternField(base.Year(), fields[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+		month, err := parsePatternField(int(base.Month()), fields[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		// N-th weekday within the month
+		if len(fields[2]) >= 2 {
+			if _, isWeekday := weekdays[fields[2][:2]]; isWeekday {
+				weekday, n, err := parsePatternWeekday(fields[2], false)
+				if err != nil {
+					return time.Time{}, err
+				}
+
+				first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, base.Location())
+				return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+(n-1)*7), nil
+			}
+		}
+
+		day, err := parsePatternField(base.Day(), fields[2])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, base.Location()), nil
+	}
+
+	return time.Time{}, fmt.Errorf("expected 'YYYY-MM-DD', 'YYYY-<weekday><week>' or '<weekday>+<weeks>'")
+}
+
+// parsePatternField parses a single field of an offset pattern. The field is either an
+// absolute value or an offset to the given base value ("+2" or "/2" for negative values)
+func parsePatternField(base int, field string) (int, error) {
+	if field == "" {
+		return 0, fmt.Errorf("empty field")
+	}
+
+	value, err := strconv.Atoi(strings.TrimLeft(field, "+/"))
+	if err != nil || len(field)-len(strings.TrimLeft(field, "+/")) > 1 {
+		return 0, fmt.Errorf("invalid field %q", field)
+	}
+
+	switch field[0] {
+	case '+':
+		return base + value, nil
+	case '/':
+		return base - value, nil
+	default:
+		return value, nil
+	}
+}
+
+// parsePatternWeekday parses a weekday like "Mo+2". If "relative" is set, the number
+// has to be prefixed with "+" or "/" (or can be omitted). Otherwise, a plain number is required
+func parsePatternWeekday(field string, relative bool) (time.Weekday, int, error) {
+	if len(field) < 2 {
+		return 0, 0, fmt.Errorf("invalid weekday %q", field)
+	}
+	weekday, ok := weekdays[field[:2]]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid weekday %q. Expected one of mo, tu, we, th, fr, sa or su", field[:2])
+	}
+
+	number := field[2:]
+	if relative {
+		if number == "" {
+			return weekday, 0, nil
+		} else if number[0] != '+' && number[0] != '/' {
+			return 0, 0, fmt.Errorf("invalid week offset %q: expected '+' or '/'", number)
+		}
+
+		offset, err := parsePatternField(0, number)
+		return weekday, offset, err
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("invalid week number %q", number)
+	}
+	return weekday, n, nil
+}
+
+// daysSinceMonday returns the number of days between the Monday and the given weekday
+func daysSinceMonday(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
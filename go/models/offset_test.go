@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOffset(t *testing.T) {
+	// Sunday at the end of the year
+	base := time.Date(2023, time.December, 31, 22, 20, 45, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		offset      string
+		fullMinutes bool
+		want        time.Time
+		wantErr     bool
+	}{
+		{name: "now", offset: "now", want: base},
+		{name: "seconds", offset: "+10s", want: base.Add(10 * time.Second)},
+		{name: "minutes", offset: "+20m", want: base.Add(20 * time.Minute)},
+		{name: "negative hours", offset: "/2h", want: base.Add(-2 * time.Hour)},
+		{name: "trimmed and lowercased", offset: " +20M ", want: base.Add(20 * time.Minute)},
+		{name: "full minutes", offset: "+30s", fullMinutes: true, want: time.Date(2023, time.December, 31, 22, 21, 0, 0, time.UTC)},
+		{name: "hours over the year", offset: "+2h", want: time.Date(2024, time.January, 1, 0, 20, 45, 0, time.UTC)},
+		{name: "days over the year", offset: "+1d", want: time.Date(2024, time.January, 1, 22, 20, 45, 0, time.UTC)},
+		{name: "days over the month", offset: "+32d", want: time.Date(2024, time.February, 1, 22, 20, 45, 0, time.UTC)},
+		{name: "multiple units", offset: "+1h30m", wantErr: true},
+		{name: "missing sign", offset: "20m", wantErr: true},
+		{name: "unknown unit", offset: "+2w", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOffset(base, tt.offset, tt.fullMinutes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOffset() returned the error %v, want an error: %t", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOffsetPattern(t *testing.T) {
+	// Sunday at the end of the year
+	base := time.Date(2023, time.December, 31, 22, 20, 45, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		keepDate bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{name: "absolute", pattern: "2024-02-10T08:30:00", want: time.Date(2024, time.February, 10, 8, 30, 0, 0, time.UTC)},
+		{name: "relative", pattern: "+0-+0-/1T/2:+10:00", want: time.Date(2023, time.December, 30, 20, 30, 0, 0, time.UTC)},
+		{name: "hours over the year", pattern: "+0-+0-+0T+5:+0:+0", want: time.Date(2024, time.January, 1, 3, 20, 45, 0, time.UTC)},
+		{name: "hours over the year with keepDate", pattern: "+0-+0-+0T+5:+0:+0", keepDate: true, want: time.Date(2023, time.December, 31, 3, 20, 45, 0, time.UTC)},
+		{name: "hours over the month", pattern: "2024-01-31T+3:00:00", want: time.Date(2024, time.February, 1, 1, 0, 0, 0, time.UTC)},
+		{name: "hours over the month with keepDate", pattern: "2024-01-31T+3:00:00", keepDate: true, want: time.Date(2024, time.January, 31, 1, 0, 0, 0, time.UTC)},
+		{name: "negative hours with keepDate", pattern: "+0-+0-+0T/23:00:00", keepDate: true, want: time.Date(2023, time.December, 31, 23, 0, 0, 0, time.UTC)},
+		{name: "minutes over the year", pattern: "+0-+0-+0T+1:+45:00", want: time.Date(2024, time.January, 1, 0, 5, 0, 0, time.UTC)},
+		{name: "days over the year", pattern: "+0-+0-+1T10:00:00", want: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "months over the year", pattern: "+0-+2-+0T10:00:00", want: time.Date(2024, time.March, 2, 10, 0, 0, 0, time.UTC)},
+		{name: "weekday of the next week", pattern: "mo+1T10:00:00", want: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "weekday of the current week", pattern: "MoT10:00:00", want: time.Date(2023, time.December, 25, 10, 0, 0, 0, time.UTC)},
+		{name: "weekday of a calendar week", pattern: "2021-mo2T10:00:00", want: time.Date(2021, time.January, 11, 10, 0, 0, 0, time.UTC)},
+		{name: "n-th weekday of the month", pattern: "2024-01-mo2T10:00:00", want: time.Date(2024, time.January, 8, 10, 0, 0, 0, time.UTC)},
+		{name: "missing time", pattern: "2024-01-01", wantErr: true},
+		{name: "incomplete time", pattern: "2024-01-01T10:00", wantErr: true},
+		{name: "invalid weekday", pattern: "xx+1T10:00:00", wantErr: true},
+		{name: "weekday offset without sign", pattern: "mo1T10:00:00", wantErr: true},
+		{name: "doubled sign", pattern: "2024-01-++1T10:00:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOffsetPattern(base, tt.pattern, tt.keepDate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOffsetPattern() returned the error %v, want an error: %t", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseOffsetPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
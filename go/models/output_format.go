@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutputFormat specifies the format in which data is printed to the user
+type OutputFormat string
+
+const (
+	// Human friendly output. This is also used if no format was given
+	FormatPretty OutputFormat = "pretty"
+
+	// Indented JSON output of the data
+	FormatJSON OutputFormat = "json"
+
+	// Comma separated values of the "relevant" fields
+	FormatCSV OutputFormat = "csv"
+)
+
+// OutputFormats contains all available output formats
+var OutputFormats = []OutputFormat{FormatPretty, FormatJSON, FormatCSV}
+
+// ParseOutputFormat converts the given string (case-insensitive) into
+// an OutputFormat. An empty string results in the format "pretty".
+// If the format is unknown an error listing all valid formats is returned
+func ParseOutputFormat(value string) (OutputFormat, error) {
+	if value == "" {
+		return FormatPretty, nil
+	}
+
+	for _, f := range OutputFormats {
+		if strings.EqualFold(string(f), value) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid output format %q. Valid formats are: %s", value, strings.Join(OutputFormatNames(), ", "))
+}
+
+// OutputFormatNames returns the names of all available output formats
+func OutputFormatNames() []string {
+	rtc := make([]string, len(OutputFormats))
+	for i, f := range OutputFormats {
+		rtc[i] = string(f)
+	}
+
+	return rtc
+}
+
+func (f OutputFormat) String() string {
+	if f == "" {
+		return string(FormatPretty)
+	}
+
+	return string(f)
+}
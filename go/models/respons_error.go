@@ -1,6 +1,34 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorIdClientOutdated is the ID of the error returned by the server if the version of
+// the client (sent via the header "Client-Version") is no longer supported. The server
+// responds with the status code 426 (Upgrade Required) in such a case
+const ErrorIdClientOutdated = "CLIENT_TOO_OLD"
+
+// ErrorIdEntryNotFound is the ID of the error returned if the requested entry does not exist
+const ErrorIdEntryNotFound = "ENTRY_NOT_FOUND"
+
+// ErrorIdAttributeNotFound is the ID of the error returned if the requested attribute does not exist
+const ErrorIdAttributeNotFound = "ATTRIBUTE_NOT_FOUND"
+
+// ErrEntryNotFound can be used to check whether an [ErrorResponse] was returned because
+// the requested entry does not exist: errors.Is(err, models.ErrEntryNotFound).
+// Don't return or modify this value!
+var ErrEntryNotFound = &ErrorResponse{ID: ErrorIdEntryNotFound, ResponseCode: 404, Message: "Entry was not found"}
+
+// ErrAttributeNotFound can be used to check whether an [ErrorResponse] was returned because
+// the requested attribute does not exist: errors.Is(err, models.ErrAttributeNotFound).
+// Don't return or modify this value!
+var ErrAttributeNotFound = &ErrorResponse{ID: ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
+
+// ErrClientOutdated is set as "ErrorGo" of an [ErrorResponse] if the server requires
+// a newer version of the client. You can check for it with "errors.Is()"
+var ErrClientOutdated = fmt.Errorf("the version %s of the client is no longer supported by the server. Please upgrade the client", LibraryVersion)
 
 // ErrorResponse represents a custom error returned from the PHP server
 // if the response was erroneous (status code 3xx or 4xx).
@@ -31,6 +59,10 @@ type ErrorResponse struct {
 	// Occurred go error (optional). If this field is given the Message is empty
 	ErrorGo error
 
+	// Duration to wait before sending the next request if the client was throttled
+	// by the server (status code 429). Zero if the server didn't send a duration
+	RetryAfter time.Duration
+
 	// Only in server debug mode
 	ErrorResponseDebug
 }
@@ -94,6 +126,29 @@ func (err *ErrorResponse) PrintLog(indent string) string {
 	return rtc + debug
 }
 
+// Unwrap returns the go error of the response (if any)
+func (err *ErrorResponse) Unwrap() error {
+	return err.ErrorGo
+}
+
+// Is reports whether the target is an [ErrorResponse] with the same ID. This makes the
+// error comparable with "errors.Is()" against the exported errors like [ErrEntryNotFound]
+func (err *ErrorResponse) Is(target error) bool {
+	t, ok := target.(*ErrorResponse)
+	return ok && t != nil && t.ID != "" && err.ID == t.ID
+}
+
+// HasID returns whether the error has the given ID (e.g. [ErrorIdEntryNotFound])
+func (err *ErrorResponse) HasID(id string) bool {
+	return err.ID == id
+}
+
+// IsClientOutdated returns if the server rejected the request because
+// the version of the client is too old
+func (err *ErrorResponse) IsClientOutdated() bool {
+	return err.ID == ErrorIdClientOutdated || err.ErrorGo == ErrClientOutdated
+}
+
 // IsZero returns if the error response if empty
 func (err *ErrorResponse) IsZero() bool {
 	return err.ID == ""
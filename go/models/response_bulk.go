@@ -28,7 +28,8 @@ type BulkResponse[T any] struct {
 	// Short phrase of the operation status (summary) for the client
 	Message ResponseMessage `json:"message"`
 
-	// The returned objects of the bulk response
+	// The returned objects of the bulk response.
+	// For every requested object a response is returned in the same order as requested
 	ResponseData []BulkResponseData[T] `json:"response"`
 }
 
@@ -110,6 +111,10 @@ type BulkResponseData[T any] struct {
 
 	// Optional error message if the StatusCode >= 300
 	Error ErrorResponse
+
+	// Identity of the requested object (e.g. the attribute and parameters of an entry)
+	// to identify failed operations. This is only set by the client
+	Identity string `json:"-"`
 }
 
 // String returns a pretty string with all debug information
@@ -129,6 +134,10 @@ func (r BulkResponse[T]) String() string {
     Code    -> %d
 `, d.Status, d.StatusCode)
 
+		if d.Identity != "" {
+			responseData += fmt.Sprintf("    Request -> %s\n", d.Identity)
+		}
+
 		if !d.Error.IsZero() {
 			responseData += "    Error:\n" + d.Error.PrintLog("       ")
 		}
@@ -1,7 +1,12 @@
 package models
 
+import "fmt"
+
 // Response of an execution for entries with an attribute of the type
-// exec_response
+// exec_response.
+//
+// It's encoded to JSON with the fields "entry_id", "response_code" and
+// "response". These names are also used by the server, so don't change them!
 type ExecutionResponse struct {
 	// The ID of the entry that was executed
 	EntryId int `json:"entry_id"`
@@ -13,3 +18,17 @@ type ExecutionResponse struct {
 	// The text message to display for the client
 	Text string `json:"response"`
 }
+
+// NewExecutionResponse creates a new execution response for the entry with
+// the given ID
+func NewExecutionResponse(entryID int, code int, text string) *ExecutionResponse {
+	return &ExecutionResponse{
+		EntryId: entryID,
+		Code:    code,
+		Text:    text,
+	}
+}
+
+func (r ExecutionResponse) String() string {
+	return fmt.Sprintf("Entry #%d exited with code %d:\n%s", r.EntryId, r.Code, r.Text)
+}
@@ -23,11 +23,12 @@ type ResponseMessageWrapper struct {
 }
 
 // NewResponseMessage decodes the JSON response of the given reader
-// to a new message
+// to a new message.
+// An empty body (e.g. "204 No Content") results in an empty value
 func NewResponseMessageWrapper(r io.Reader) *ResponseMessageWrapper {
 	var msg ResponseMessageWrapper
 
-	if err := json.NewDecoder(r).Decode(&msg); err != nil {
+	if err := json.NewDecoder(r).Decode(&msg); err != nil && err != io.EOF {
 		logger.Warning("Failed to decode response message: %s", err)
 	}
 
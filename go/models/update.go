@@ -85,12 +85,13 @@ func (up UpdateData[T]) String() string {
 }
 
 // NewUpdate decodes the JSON response of the given reader
-// to a new Update
+// to a new Update.
+// An empty body (e.g. "204 No Content") results in an empty value
 func NewUpdate(r io.Reader) *Update {
 	var upd Update
 
-	if err := json.NewDecoder(r).Decode(&upd); err != nil {
-		logger.Warning("Failed to decode entry: %s", err)
+	if err := json.NewDecoder(r).Decode(&upd); err != nil && err != io.EOF {
+		logger.Warning("Failed to decode update: %s", err)
 	}
 
 	return &upd
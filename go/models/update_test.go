@@ -0,0 +1,33 @@
+package models
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewFromEmptyBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func(r io.Reader) any
+		want   any
+	}{
+		{name: "NewUpdate", decode: func(r io.Reader) any { return NewUpdate(r) }, want: &Update{}},
+		{name: "NewAttribute", decode: func(r io.Reader) any { return NewAttribute(r) }, want: &Attribute{}},
+		{name: "NewResponseMessageWrapper", decode: func(r io.Reader) any { return NewResponseMessageWrapper(r) }, want: &ResponseMessageWrapper{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.decode(strings.NewReader("")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s() = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	// The entry contains the initialized execution state
+	if got := NewEntry(strings.NewReader("")); got == nil || got.ID != 0 || got.WasExecuted() {
+		t.Errorf("NewEntry() = %+v, want an empty entry", got)
+	}
+}
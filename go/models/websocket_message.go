@@ -23,6 +23,10 @@ type WebSocketMessage struct {
 
 	// Is set on "WebSocketTypeNoDb"
 	NoDb []*Entry `json:"no_db"`
+
+	// A message with the type "WebSocketTypeResync" doesn't contain any data.
+	// The server requests a full reload of the data because the version sent
+	// during the handshake is too old to provide an incremental update
 }
 
 // WebSocketMessageType defines the message type that was received by the
@@ -34,6 +38,7 @@ const (
 	WebSocketTypeExecResponse
 	WebSocketTypeNoDb
 	WebSocketTypeUnknown
+	WebSocketTypeResync
 )
 
 func (m *WebSocketMessageType) UnmarshalJSON(b []byte) error {
@@ -45,6 +50,8 @@ func (m *WebSocketMessageType) UnmarshalJSON(b []byte) error {
 		*m = WebSocketTypeExecResponse
 	case "no_db":
 		*m = WebSocketTypeNoDb
+	case "resync":
+		*m = WebSocketTypeResync
 	default:
 		// Don't throw an error because new message types could be added on the fly with
 		// newer versions
@@ -63,6 +70,8 @@ func (m WebSocketMessageType) String() string {
 		return "exec_response"
 	case WebSocketTypeNoDb:
 		return "no_db"
+	case WebSocketTypeResync:
+		return "resync"
 	case WebSocketTypeUnknown:
 		return "unknown"
 	default:
@@ -7,6 +7,7 @@ import (
 	"github.com/RPJoshL/RPdb/v4/go/api"
 	"github.com/RPJoshL/RPdb/v4/go/models"
 	"github.com/RPJoshL/RPdb/v4/go/pkg/utils"
+	"git.rpjosh.de/RPJosh/go-logger"
 )
 
 type persistenceAttribute struct {
@@ -17,6 +18,17 @@ type persistenceAttribute struct {
 
 	// Mutex to synchronize the access to the data
 	mux sync.RWMutex
+
+	// Whether the attributes are fetched without their parameters and presets.
+	// See [api.ApiOptions.LeanAttributes]
+	lean bool
+
+	// The IDs of the attributes whose details were already fetched (only for lean attributes)
+	expanded map[int]bool
+
+	// Function to call after the attributes were changed by an update, so that
+	// the entries reference the current attributes
+	relink func()
 }
 
 func (p *persistenceAttribute) loadData() error {
@@ -28,11 +40,95 @@ func (p *persistenceAttribute) loadData() error {
 	// Update locally stored data by replacing the value
 	p.mux.Lock()
 	p.data = attr
+	p.expanded = make(map[int]bool)
 	p.mux.Unlock()
 
 	return nil
 }
 
+// get returns the locally cached attribute with the given ID without
+// fetching its details
+func (p *persistenceAttribute) get(id int) (*models.Attribute, bool) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	for i := range p.data {
+		if p.data[i].ID == id {
+			return p.data[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// isExpanded returns whether the details of the given attribute are available locally
+func (p *persistenceAttribute) isExpanded(id int) bool {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	return !p.lean || p.expanded[id]
+}
+
+// expandAttribute fetches the details of the given lean attribute and replaces the locally
+// cached attribute with it. The attributes of the entries are linked to the new attribute.
+// If the details couldn't be fetched, the lean attribute is returned
+func (p *Persistence) expandAttribute(attr *models.Attribute) *models.Attribute {
+	full, err := p.Api.GetAttribute(attr.ID)
+	if err != nil {
+		logger.Warning("Failed to fetch the details of the attribute %q (#%d): %s", attr.Name, attr.ID, err)
+		return attr
+	}
+
+	// Lock in the same order as while linking the attributes
+	p.entry.mux.Lock()
+	defer p.entry.mux.Unlock()
+	p.attribute.mux.Lock()
+	defer p.attribute.mux.Unlock()
+
+	for i := range p.attribute.data {
+		if p.attribute.data[i].ID == full.ID {
+			p.attribute.data[i] = full
+		}
+	}
+	for _, e := range p.entry.data {
+		if e.Attribute != nil && e.Attribute.ID == full.ID {
+			e.Attribute = full
+		}
+	}
+	p.attribute.expanded[full.ID] = true
+
+	return full
+}
+
+// RelinkEntries points the attribute of every cached entry to the currently cached
+// attribute with the same ID. Otherwise, entries would reference an outdated attribute
+// after it was changed.
+// This is done automatically when attributes were created, updated or deleted by an update
+func (p *Persistence) RelinkEntries() {
+	// Lock in the same order as while linking the attributes
+	p.entry.mux.Lock()
+	defer p.entry.mux.Unlock()
+	p.attribute.mux.RLock()
+	defer p.attribute.mux.RUnlock()
+
+	attributes := make(map[int]*models.Attribute, len(p.attribute.data))
+	for _, a := range p.attribute.data {
+		attributes[a.ID] = a
+	}
+
+	for _, e := range p.entry.data {
+		if e.Attribute == nil {
+			continue
+		}
+
+		if attr, found := attributes[e.Attribute.ID]; found {
+			e.Attribute = attr
+		} else {
+			logger.Warning("The attribute #%d of the entry #%d was deleted", e.Attribute.ID, e.ID)
+		}
+	}
+}
+
 // addAndSortWithoutLock adds all the given attributes to the local cache and sorts the whole
 // array again.
 // This method does NOT lock the data mutex
@@ -52,17 +148,20 @@ func (p *Persistence) GetAttributesAll() []*models.Attribute {
 	return attr
 }
 
+// GetAttribute returns the locally cached attribute with the given ID.
+// For lean attributes (see [api.ApiOptions.LeanAttributes]) the details are fetched
+// from the server on the first access
 func (p *Persistence) GetAttribute(id int) (*models.Attribute, *models.ErrorResponse) {
-	p.attribute.mux.RLocker().Lock()
-	defer p.attribute.mux.RLocker().Unlock()
+	attr, found := p.attribute.get(id)
+	if !found {
+		return nil, &models.ErrorResponse{ID: models.ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
+	}
 
-	for i := range p.attribute.data {
-		if (p.attribute.data[i]).ID == id {
-			return p.attribute.data[i], nil
-		}
+	if !p.attribute.isExpanded(id) {
+		return p.expandAttribute(attr), nil
 	}
 
-	return nil, &models.ErrorResponse{ID: "ATTRIBUTE_NOT_FOUND", ResponseCode: 404, Message: "Attribute was not found"}
+	return attr, nil
 }
 
 func (p *Persistence) GetAttributes() (rtc []*models.Attribute, err *models.ErrorResponse) {
@@ -78,15 +177,33 @@ func (p *Persistence) GetAttributes() (rtc []*models.Attribute, err *models.Erro
 // If the attribute does not exist an error is returned
 func (p *Persistence) GetAttributeByName(name string) (*models.Attribute, *models.ErrorResponse) {
 	p.attribute.mux.RLock()
-	defer p.attribute.mux.RUnlock()
-
+	var attr *models.Attribute
 	for i := range p.attribute.data {
 		if p.attribute.data[i].Name == name {
-			return p.attribute.data[i], nil
+			attr = p.attribute.data[i]
+			break
 		}
 	}
+	p.attribute.mux.RUnlock()
 
-	return nil, &models.ErrorResponse{ID: "ATTRIBUTE_NOT_FOUND", ResponseCode: 404, Message: "Attribute was not found"}
+	if attr == nil {
+		return nil, &models.ErrorResponse{ID: models.ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
+	}
+
+	return p.GetAttribute(attr.ID)
+}
+
+// GetAttributeByNameFold is like "GetAttributeByName()" but the name is compared
+// case-insensitive if no attribute matches exactly
+func (p *Persistence) GetAttributeByNameFold(name string) (*models.Attribute, *models.ErrorResponse) {
+	p.attribute.mux.RLock()
+	defer p.attribute.mux.RUnlock()
+
+	if attr := models.FindAttributeByName(p.attribute.data, name); attr != nil {
+		return attr, nil
+	}
+
+	return nil, &models.ErrorResponse{ID: models.ErrorIdAttributeNotFound, ResponseCode: 404, Message: "Attribute was not found"}
 }
 
 // handleUpdate handles the merge of the given update for the locally
@@ -99,6 +216,13 @@ func (p *persistenceAttribute) handleUpdate(upd models.UpdateData[*models.Attrib
 		utils.Filter(&upd.Deleted, &p.data, func(a int, b *models.Attribute) bool { return a == b.ID })
 	}
 
+	// Attributes of an update are always sent with their details
+	for _, attributes := range [][]*models.Attribute{upd.Created, upd.Updated} {
+		for _, a := range attributes {
+			p.expanded[a.ID] = true
+		}
+	}
+
 	// Add created entries
 	if len(upd.Created) > 0 {
 		p.addAndSortWithoutLock(upd.Created...)
@@ -114,4 +238,9 @@ func (p *persistenceAttribute) handleUpdate(upd models.UpdateData[*models.Attrib
 	}
 
 	p.mux.Unlock()
+
+	// The entry lock has to be acquired before the attribute lock
+	if p.relink != nil {
+		p.relink()
+	}
 }
@@ -0,0 +1,125 @@
+package persistence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestRelinkEntries(t *testing.T) {
+	date := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		// Attributes that replace the cached attributes before relinking
+		attributes []*models.Attribute
+
+		// Expected attribute name of the entries #1 and #2
+		want []string
+	}{
+		{
+			name:       "changed attribute",
+			attributes: []*models.Attribute{{ID: 1, Name: "a new"}, {ID: 2, Name: "b"}},
+			want:       []string{"a new", "b"},
+		},
+		{
+			name:       "all attributes changed",
+			attributes: []*models.Attribute{{ID: 1, Name: "a new"}, {ID: 2, Name: "b new"}},
+			want:       []string{"a new", "b new"},
+		},
+		{
+			name:       "deleted attribute",
+			attributes: []*models.Attribute{{ID: 2, Name: "b new"}},
+			want:       []string{"a", "b new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := []*models.Attribute{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+			p := newTestPersistence(t, respondJSON(nil), attributes,
+				&models.Entry{ID: 1, Attribute: attributes[0], DateTime: models.DateTime{Time: date}},
+				&models.Entry{ID: 2, Attribute: attributes[1], DateTime: models.DateTime{Time: date.Add(time.Minute)}},
+			)
+
+			p.attribute.data = tt.attributes
+			p.RelinkEntries()
+
+			for i, e := range p.GetEntriesAll() {
+				if e.Attribute.Name != tt.want[i] {
+					t.Errorf("Attribute of entry #%d = %q, want %q", e.ID, e.Attribute.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAttributeUpdateRelinksEntries(t *testing.T) {
+	newAttribute := func(name string, preset string) *models.Attribute {
+		return &models.Attribute{ID: 1, Name: name, Parameter: []models.AttributeParameter{
+			{ID: 10, Position: 1, Presets: []models.ParameterPreset{{Name: "Kitchen", Value: preset}}},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		update models.UpdateData[*models.Attribute]
+
+		wantName  string
+		wantValue string
+	}{
+		{
+			name:      "renamed",
+			update:    models.UpdateData[*models.Attribute]{Updated: []*models.Attribute{newAttribute("new", "10")}},
+			wantName:  "new",
+			wantValue: "10",
+		},
+		{
+			name:      "preset changed",
+			update:    models.UpdateData[*models.Attribute]{Updated: []*models.Attribute{newAttribute("old", "20")}},
+			wantName:  "old",
+			wantValue: "20",
+		},
+		{
+			name:      "other attribute created",
+			update:    models.UpdateData[*models.Attribute]{Created: []*models.Attribute{{ID: 2, Name: "other"}}},
+			wantName:  "old",
+			wantValue: "10",
+		},
+		{
+			// The entry keeps the previous attribute
+			name:      "deleted",
+			update:    models.UpdateData[*models.Attribute]{Deleted: []int{1}},
+			wantName:  "old",
+			wantValue: "10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := []*models.Attribute{newAttribute("old", "10")}
+			p := newTestPersistence(t, respondJSON(nil), attributes, &models.Entry{
+				ID:         1,
+				Attribute:  attributes[0],
+				DateTime:   models.DateTime{Time: time.Now().Add(time.Hour)},
+				Parameters: []models.EntryParameter{{ParameterID: 10, Preset: "Kitchen"}},
+			})
+
+			msg := models.WebSocketMessage{Type: models.WebSocketTypeUpdate}
+			msg.Update.Attribute = tt.update
+			p.handleWebSocketMessage(msg)
+
+			ent, err := p.GetEntry(1)
+			if err != nil {
+				t.Fatalf("GetEntry() returned an error: %s", err)
+			}
+			if ent.Attribute.Name != tt.wantName {
+				t.Errorf("Attribute name = %q, want %q", ent.Attribute.Name, tt.wantName)
+			}
+			if got := ent.Parameters[0].GetValue(ent.Attribute); got != tt.wantValue {
+				t.Errorf("Parameter value = %q, want %q", got, tt.wantValue)
+			}
+		})
+	}
+}
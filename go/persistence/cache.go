@@ -0,0 +1,170 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// CacheFormat is the encoding used to dump the local cache (see [Persistence.DumpCache])
+type CacheFormat int
+
+const (
+	// Human-readable JSON encoding. This is the default
+	CacheFormatJSON CacheFormat = iota
+
+	// Compact binary encoding using "encoding/gob". It's smaller and faster to
+	// load than JSON, which is useful for the cold start of clients with large caches
+	CacheFormatGob
+)
+
+// cacheFormatVersion is the version of the structure of a dumped cache. It's
+// increased on every incompatible change, so that old dumps are detected while loading
+const cacheFormatVersion = 1
+
+// cacheMagic is the first word of the header line of a dumped cache
+const cacheMagic = "rpdb-cache"
+
+func (f CacheFormat) String() string {
+	switch f {
+	case CacheFormatJSON:
+		return "json"
+	case CacheFormatGob:
+		return "gob"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(f))
+	}
+}
+
+// parseCacheFormat returns the format with the given name of the header
+func parseCacheFormat(name string) (CacheFormat, error) {
+	for _, f := range []CacheFormat{CacheFormatJSON, CacheFormatGob} {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown cache format %q", name)
+}
+
+// cacheDump is the content of a dumped cache
+type cacheDump struct {
+	Version    int                 `json:"version"`
+	Attributes []*models.Attribute `json:"attributes"`
+
+	// The attributes of the entries only contain the ID. They are linked
+	// to the attributes again while loading
+	Entries []*models.Entry `json:"entries"`
+
+	// IDs of the entries that were already executed
+	Executed []int `json:"executed"`
+}
+
+// DumpCache writes all locally cached entries and attributes together with the version
+// of the data to the given writer. The dump starts with a header line containing the version
+// of the structure and the format, followed by the data encoded in the given format.
+// Use [Persistence.LoadCache] to restore the dump
+func (p *Persistence) DumpCache(w io.Writer, format CacheFormat) error {
+	entries, attributes, version := p.Snapshot()
+
+	dump := cacheDump{Version: version, Attributes: attributes, Entries: entries}
+	for _, e := range entries {
+		if e.WasExecuted() {
+			dump.Executed = append(dump.Executed, e.ID)
+		}
+		// The attributes are already dumped once
+		if e.Attribute != nil {
+			e.Attribute = &models.Attribute{ID: e.Attribute.ID}
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s %d %s\n", cacheMagic, cacheFormatVersion, format); err != nil {
+		return err
+	}
+
+	switch format {
+	case CacheFormatJSON:
+		return json.NewEncoder(w).Encode(dump)
+	case CacheFormatGob:
+		return gob.NewEncoder(w).Encode(dump)
+	default:
+		return fmt.Errorf("unknown cache format %s", format)
+	}
+}
+
+// LoadCache replaces the locally cached entries and attributes with the dump written by
+// [Persistence.DumpCache]. The format is detected from the header of the dump. An error
+// is returned for a dump of another structure version.
+// This can be used to read the data without waiting for the server (e.g. on a cold start
+// or while the server is unreachable). [Persistence.Start] replaces the data with the
+// current data of the server
+func (p *Persistence) LoadCache(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read the header of the cache: %s", err)
+	}
+
+	var magic, formatName string
+	var version int
+	if _, err := fmt.Sscanf(strings.TrimSpace(header), "%s %d %s", &magic, &version, &formatName); err != nil || magic != cacheMagic {
+		return fmt.Errorf("invalid header of the cache %q", strings.TrimSpace(header))
+	}
+	if version != cacheFormatVersion {
+		return fmt.Errorf("unsupported version %d of the cache. Expected %d", version, cacheFormatVersion)
+	}
+	format, err := parseCacheFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	var dump cacheDump
+	switch format {
+	case CacheFormatJSON:
+		err = json.NewDecoder(reader).Decode(&dump)
+	case CacheFormatGob:
+		err = gob.NewDecoder(reader).Decode(&dump)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode the cache: %s", err)
+	}
+
+	attributes := make(map[int]*models.Attribute, len(dump.Attributes))
+	for _, a := range dump.Attributes {
+		attributes[a.ID] = a
+	}
+	executed := make(map[int]bool, len(dump.Executed))
+	for _, id := range dump.Executed {
+		executed[id] = true
+	}
+	for _, e := range dump.Entries {
+		e.InitExecution()
+		e.SetExecuted(executed[e.ID])
+		if e.Attribute != nil {
+			if attr, ok := attributes[e.Attribute.ID]; ok {
+				e.Attribute = attr
+			}
+		}
+	}
+
+	// Lock in the same order as while linking the attributes
+	p.entry.mux.Lock()
+	defer p.entry.mux.Unlock()
+	p.attribute.mux.Lock()
+	defer p.attribute.mux.Unlock()
+	p.Update.versionLock.Lock()
+	defer p.Update.versionLock.Unlock()
+
+	p.attribute.data = dump.Attributes
+	p.attribute.expanded = make(map[int]bool)
+	p.entry.data = nil
+	p.entry.addAndSortWithoutLock(dump.Entries...)
+	p.Update.Version = dump.Version
+
+	return nil
+}
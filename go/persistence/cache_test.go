@@ -0,0 +1,128 @@
+package persistence
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// newCachedPersistence returns a persistence layer with the given number of cached entries
+// and the version 5. Every second entry was already executed
+func newCachedPersistence(tb testing.TB, count int) *Persistence {
+	p := NewPersistence("key", api.ApiOptions{BaseUrl: "http://rpdb.invalid"}, &PersistenceOptions{})
+	tb.Cleanup(func() { p.Close() })
+
+	p.attribute.data = []*models.Attribute{
+		{ID: 1, Name: "a", Parameter: []models.AttributeParameter{{ID: 1, Name: "ip", Presets: []models.ParameterPreset{{Name: "Kitchen", Value: "192.168.0.10"}}}}},
+		{ID: 2, Name: "b", ExecuteAlways: true},
+	}
+	date := time.Now().Add(time.Hour).Truncate(time.Second)
+	entries := make([]*models.Entry, count)
+	for i := range entries {
+		entries[i] = newTestEntry(i+1, date.Add(time.Duration(i)*time.Minute))
+		entries[i].Attribute = p.attribute.data[i%2]
+		entries[i].Parameters = []models.EntryParameter{{Value: fmt.Sprintf("value %d", i)}}
+		entries[i].SetExecuted(i%2 == 1)
+	}
+	p.entry.addAndSort(entries...)
+	p.Update.Version = 5
+
+	return p
+}
+
+func TestDumpLoadCache(t *testing.T) {
+	for _, format := range []CacheFormat{CacheFormatJSON, CacheFormatGob} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := newCachedPersistence(t, 3).DumpCache(&buf, format); err != nil {
+				t.Fatalf("DumpCache() returned an error: %s", err)
+			}
+			if want := fmt.Sprintf("rpdb-cache 1 %s\n", format); !strings.HasPrefix(buf.String(), want) {
+				t.Errorf("DumpCache() header = %q, want %q", strings.SplitN(buf.String(), "\n", 2)[0], want)
+			}
+
+			p := NewPersistence("key", api.ApiOptions{BaseUrl: "http://rpdb.invalid"}, &PersistenceOptions{})
+			defer p.Close()
+			if err := p.LoadCache(&buf); err != nil {
+				t.Fatalf("LoadCache() returned an error: %s", err)
+			}
+
+			entries, attributes, version := p.Snapshot()
+			if version != 5 || len(attributes) != 2 || len(entries) != 3 {
+				t.Fatalf("Snapshot() = (%d entries, %d attributes, version %d), want (3, 2, 5)", len(entries), len(attributes), version)
+			}
+			if len(attributes[0].Parameter) != 1 || len(attributes[0].Parameter[0].Presets) != 1 {
+				t.Errorf("Attribute #1 = %+v, want the parameter with the preset", attributes[0])
+			}
+			for i, e := range entries {
+				if e.ID != i+1 {
+					t.Errorf("Entry %d has the ID #%d, want #%d", i, e.ID, i+1)
+				}
+				if e.Attribute != attributes[i%2] {
+					t.Errorf("Attribute of entry #%d is not linked to the cached attribute", e.ID)
+				}
+				if e.WasExecuted() != (i%2 == 1) {
+					t.Errorf("WasExecuted() of entry #%d = %t, want %t", e.ID, e.WasExecuted(), i%2 == 1)
+				}
+				if want := fmt.Sprintf("value %d", i); len(e.Parameters) != 1 || e.Parameters[0].Value != want {
+					t.Errorf("Parameters of entry #%d = %+v, want %q", e.ID, e.Parameters, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCacheInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{name: "empty", dump: ""},
+		{name: "other file", dump: "{\"version\": 1}\n"},
+		{name: "newer version", dump: "rpdb-cache 2 json\n{}\n"},
+		{name: "unknown format", dump: "rpdb-cache 1 xml\n<cache/>\n"},
+		{name: "invalid data", dump: "rpdb-cache 1 gob\n{}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newCachedPersistence(t, 1)
+			if err := p.LoadCache(strings.NewReader(tt.dump)); err == nil {
+				t.Fatalf("LoadCache() returned no error")
+			}
+
+			// The cached data is kept
+			if entries, _, version := p.Snapshot(); len(entries) != 1 || version != 5 {
+				t.Errorf("Snapshot() = (%d entries, version %d), want (1, 5)", len(entries), version)
+			}
+		})
+	}
+}
+
+func BenchmarkLoadCache(b *testing.B) {
+	for _, format := range []CacheFormat{CacheFormatJSON, CacheFormatGob} {
+		var buf bytes.Buffer
+		if err := newCachedPersistence(b, 10_000).DumpCache(&buf, format); err != nil {
+			b.Fatalf("DumpCache() returned an error: %s", err)
+		}
+		dump := buf.Bytes()
+
+		b.Run(format.String(), func(b *testing.B) {
+			p := NewPersistence("key", api.ApiOptions{BaseUrl: "http://rpdb.invalid"}, &PersistenceOptions{})
+			defer p.Close()
+
+			b.SetBytes(int64(len(dump)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.LoadCache(bytes.NewReader(dump)); err != nil {
+					b.Fatalf("LoadCache() returned an error: %s", err)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package persistence
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/api"
 	"github.com/RPJoshL/RPdb/v4/go/models"
@@ -15,10 +17,17 @@ type persistenceEntry struct {
 	// API interface to load the data from (Persistence)
 	api api.Apiler
 
+	// The locally cached attributes to link the entries to
+	attributes *persistenceAttribute
+
 	data []*models.Entry
 
 	// Mutex to synchronize the access to the data
 	mux sync.RWMutex
+
+	// Whether the attributes are not expanded by the server and have to be
+	// linked to the locally cached ones
+	cacheAttributes bool
 }
 
 // loadData fetches all attributes from the API and stores it locally.
@@ -29,9 +38,12 @@ func (p *persistenceEntry) loadData() error {
 		return err
 	}
 
-	// Update locally stored data by replacing the value
+	// Update locally stored data by replacing the value.
+	// The order of the server may vary, so the entries are sorted again
 	p.mux.Lock()
-	p.data = ent
+	p.inheritExecutionStateWithoutLock(ent)
+	p.data = nil
+	p.addAndSortWithoutLock(ent...)
 	p.mux.Unlock()
 
 	return nil
@@ -47,9 +59,16 @@ func (p *persistenceEntry) linkAttributes(entries *[]*models.Entry) {
 }
 
 // linkAttribute links the attribute of the given entry to the locally
-// fetched attribute
+// fetched attribute.
+// If attributes are not cached locally, only attributes that weren't expanded by
+// the server are linked
 func (p *persistenceEntry) linkAttribute(entry *models.Entry) {
-	if attr, err := p.api.GetAttribute(entry.Attribute.ID); err == nil {
+	// The attribute was already expanded by the server
+	if !p.cacheAttributes && entry.Attribute != nil && entry.Attribute.Name != "" {
+		return
+	}
+
+	if attr, found := p.attributes.get(entry.Attribute.ID); found {
 		entry.Attribute = attr
 	} else {
 		logger.Error("Failed to find attribute with id %d for entry %d", entry.Attribute.ID, entry.ID)
@@ -67,11 +86,46 @@ func (p *persistenceEntry) addAndSort(entries ...*models.Entry) {
 
 // addAndSortWithoutLock adds all the given entries to the local cache and sorts the whole
 // array again.
+// Already cached entries with the same ID are replaced. This happens for example with the
+// option "MultiInstance" when the own created entries are also received via the WebSocket.
+// If the given entries contain the same ID multiple times, only the last one is added.
+// The execution state of replaced entries is kept (see [persistenceEntry.inheritExecutionStateWithoutLock]).
 // This method does NOT lock the data mutex
 func (p *persistenceEntry) addAndSortWithoutLock(entries ...*models.Entry) {
-	p.data = append(p.data, entries...)
+	// Remove the duplicates of the given entries by keeping the last one
+	last := make(map[int]int, len(entries))
+	for i, e := range entries {
+		last[e.ID] = i
+	}
+	unique := make([]*models.Entry, 0, len(entries))
+	ids := make(map[int]bool, len(entries))
+	for i, e := range entries {
+		if e.ID == 0 {
+			unique = append(unique, e)
+		} else if last[e.ID] == i {
+			unique = append(unique, e)
+			ids[e.ID] = true
+		}
+	}
+	entries = unique
+
+	p.inheritExecutionStateWithoutLock(entries)
+
+	// Remove the already cached entries
+	filtered := p.data[:0]
+	for _, e := range p.data {
+		if !ids[e.ID] {
+			filtered = append(filtered, e)
+		}
+	}
+
+	p.data = append(filtered, entries...)
 	sort.SliceStable(p.data, func(i, j int) bool {
-		return p.data[i].DateTime.Compare(p.data[j].DateTime.Time) == -1
+		// Entries with the same date are ordered by their ID to get a deterministic order
+		if cmp := p.data[i].DateTime.Compare(p.data[j].DateTime.Time); cmp != 0 {
+			return cmp == -1
+		}
+		return p.data[i].ID < p.data[j].ID
 	})
 }
 
@@ -85,7 +139,7 @@ func (p *Persistence) GetEntry(id int) (*models.Entry, *models.ErrorResponse) {
 		}
 	}
 
-	return nil, &models.ErrorResponse{ID: "ENTRY_NOT_FOUND", ResponseCode: 404, Message: "Entry was not found"}
+	return nil, &models.ErrorResponse{ID: models.ErrorIdEntryNotFound, ResponseCode: 404, Message: "Entry was not found"}
 }
 
 func (p *Persistence) GetEntries(filter models.EntryFilter) (rtc []*models.Entry, err *models.ErrorResponse) {
@@ -102,9 +156,10 @@ func (p *Persistence) GetEntries(filter models.EntryFilter) (rtc []*models.Entry
 	// The filtering can be applied on the client side with no additional
 	// api call
 	if filter.CanHandleLocally() && len(filter.Executed) == 0 {
+		matches := filter.Matcher()
 		p.entry.mux.RLocker().Lock()
 		for i, e := range p.entry.data {
-			if filter.DoesMatch(*e) {
+			if matches(*e) {
 				rtc = append(rtc, p.entry.data[i])
 			}
 		}
@@ -112,11 +167,135 @@ func (p *Persistence) GetEntries(filter models.EntryFilter) (rtc []*models.Entry
 		return
 	}
 
-	// The filtering can not be executed locally so an additional api call is required
+	// The filtering can not be executed locally so an additional api call is required.
+	// The result of identical queries may be cached for a short time
+	key := filter.CacheKey()
+	updates := p.Update.notifications.Load()
+	if cached, ok := p.queryCache.get(key, updates); ok {
+		return cached, nil
+	}
+
 	rtc, err = p.Api.GetEntries(filter)
-	if err != nil {
+	if err == nil {
 		p.entry.linkAttributes(&rtc)
+		p.queryCache.set(key, updates, rtc)
+	}
+	return
+}
+
+// IterateEntries calls the callback for every entry matching the given filter.
+// If the filter can be applied locally, the matching cached entries are collected first
+// and the callback is called without holding the lock of the entries. The callback can
+// therefore use the persistence layer, but it may receive entries that were already
+// updated or deleted in the meantime
+func (p *Persistence) IterateEntries(filter models.EntryFilter, callback func(*models.Entry) error) *models.ErrorResponse {
+	if all := filter.IsZero(); all || (filter.CanHandleLocally() && len(filter.Executed) == 0) {
+		matches := filter.Matcher()
+		p.entry.mux.RLock()
+		matching := make([]*models.Entry, 0, len(p.entry.data))
+		for _, e := range p.entry.data {
+			if all || matches(*e) {
+				matching = append(matching, e)
+			}
+		}
+		p.entry.mux.RUnlock()
+
+		for _, e := range matching {
+			if err := callback(e); err != nil {
+				return &models.ErrorResponse{ErrorGo: err}
+			}
+		}
+
+		return nil
+	}
+
+	return p.Api.IterateEntries(filter, func(e *models.Entry) error {
+		p.entry.linkAttribute(e)
+		return callback(e)
+	})
+}
+
+// GetEntriesCount returns the number of entries matching the given filter.
+// If the filter can be applied locally, the cached entries are counted
+func (p *Persistence) GetEntriesCount(filter models.EntryFilter) (int, *models.ErrorResponse) {
+	if filter.IsZero() || (filter.CanHandleLocally() && len(filter.Executed) == 0) {
+		entries, err := p.GetEntries(filter)
+		return len(entries), err
+	}
+
+	return p.Api.GetEntriesCount(filter)
+}
+
+// GetEntriesByIDs returns the entries with the given IDs in the same order as requested.
+// The entries are taken from the local cache. Only entries that are not cached (e.g. because
+// they are already past) are fetched from the API.
+// IDs for which no entry was found are returned as missing
+func (p *Persistence) GetEntriesByIDs(ids []int) ([]*models.Entry, []int, *models.ErrorResponse) {
+	p.entry.mux.RLock()
+	ordered, missing := models.OrderEntriesByIDs(p.entry.data, ids)
+	p.entry.mux.RUnlock()
+
+	if len(missing) == 0 {
+		return ordered, nil, nil
+	}
+
+	fetched, _, err := p.Api.GetEntriesByIDs(missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.entry.linkAttributes(&fetched)
+
+	ordered, missing = models.OrderEntriesByIDs(append(ordered, fetched...), ids)
+	return ordered, missing, nil
+}
+
+// ForEachEntry calls the given function for every entry matching the filter
+// without building a result slice. When the function returns false, the iteration is stopped.
+//
+// If the filter can be applied locally, the cached entries are iterated while holding the
+// read lock of the entries. Don't modify any entries of the persistence layer within this function!
+// Otherwise, the entries are streamed from the API like in "IterateEntries()"
+func (p *Persistence) ForEachEntry(filter models.EntryFilter, fn func(*models.Entry) bool) *models.ErrorResponse {
+	matchAll := filter.IsZero()
+	if !matchAll && (!filter.CanHandleLocally() || len(filter.Executed) != 0) {
+		err := p.Api.IterateEntries(filter, func(e *models.Entry) error {
+			p.entry.linkAttribute(e)
+			if !fn(e) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && errors.Is(err, errStopIteration) {
+			return nil
+		}
+		return err
+	}
+
+	matches := filter.Matcher()
+	p.entry.mux.RLock()
+	defer p.entry.mux.RUnlock()
+
+	for _, e := range p.entry.data {
+		if (matchAll || matches(*e)) && !fn(e) {
+			return nil
+		}
 	}
+
+	return nil
+}
+
+// errStopIteration is returned by the callback of "IterateEntries()" to stop the iteration early
+var errStopIteration = errors.New("iteration stopped")
+
+// FindEntry returns the first entry matching the given filter.
+// See [Persistence.ForEachEntry] for details about the evaluation of the filter
+func (p *Persistence) FindEntry(filter models.EntryFilter) (rtc *models.Entry, found bool, err *models.ErrorResponse) {
+	err = p.ForEachEntry(filter, func(e *models.Entry) bool {
+		rtc = e
+		found = true
+		return false
+	})
+
 	return
 }
 
@@ -131,8 +310,12 @@ func (p *Persistence) GetEntriesAll() []*models.Entry {
 
 func (p *Persistence) DeleteEntry(id int) (resp *models.ResponseMessageWrapper, err *models.ErrorResponse) {
 	// Only call api for an entry that is not of the type no_db
-	if ent, err2 := p.GetEntry(id); err2 == nil || ent == nil || !ent.Attribute.NoDb {
+	ent, err2 := p.GetEntry(id)
+	isNoDb := err2 == nil && ent != nil && ent.Attribute != nil && ent.Attribute.NoDb
+	if !isNoDb {
 		resp, err = p.Api.DeleteEntry(id)
+	} else {
+		p.executeNoDbDeleteHook(ent)
 	}
 
 	if err == nil {
@@ -161,13 +344,17 @@ func (p *Persistence) DeleteEntries(idsToDelete []int) (deleted []int, resp *mod
 
 	// Filter entries that are of the type no_db
 	entriesNoDb := make([]int, 0)
-	for i, id := range idsToDelete {
-		if ent, err := p.GetEntry(id); err == nil && ent != nil && ent.Attribute.NoDb {
+	entriesApi := make([]int, 0, len(idsToDelete))
+	for _, id := range idsToDelete {
+		if ent, err := p.GetEntry(id); err == nil && ent != nil && ent.Attribute != nil && ent.Attribute.NoDb {
 			// Add it to the list of no_db and remove it from api deletion
 			entriesNoDb = append(entriesNoDb, id)
-			idsToDelete = utils.Remove(&idsToDelete, i)
+			p.executeNoDbDeleteHook(ent)
+		} else {
+			entriesApi = append(entriesApi, id)
 		}
 	}
+	idsToDelete = entriesApi
 
 	// Execute the api request
 	if len(idsToDelete) > 0 {
@@ -197,6 +384,20 @@ func (p *Persistence) DeleteEntries(idsToDelete []int) (deleted []int, resp *mod
 
 	return deleted, resp, err
 }
+
+// executeNoDbDeleteHook calls the delete hook for an entry of the type no_db that was
+// deleted locally. The server doesn't know anything about the deletion of such entries,
+// so that the hook is not triggered through an update like for other entries.
+// Like for the other entries, the hook is only called if the execution time of the entry
+// is already past while the entry itself is not past yet.
+// When a no_db entry is removed because all its dates are past, the hook is never called
+func (p *Persistence) executeNoDbDeleteHook(ent *models.Entry) {
+	exec := &p.Options.Exeuction
+	if ent.GetExecutionTime(exec.executeOnDateTime()).Before(time.Now()) && !ent.IsPast(exec.ignoreExecutionTime()) {
+		p.Options.Exeuction.ExecuteDelete(ent)
+	}
+}
+
 func (p *Persistence) DeleteEntriesFiltered(filter models.EntryFilter) (api.EntryDeleteFiltered, *models.ErrorResponse) {
 	deleted, err := p.Api.DeleteEntriesFiltered(filter)
 	if err == nil {
@@ -216,8 +417,19 @@ func (p *Persistence) CreateEntry(entry models.Entry) (*models.Entry, *models.Er
 	ent, err := p.Api.CreateEntry(entry)
 	if err == nil {
 		p.entry.linkAttribute(ent)
+
+		// The entry was already executed and contains only the execution response
+		if ent.IsImmediateExecResponse() {
+			return ent, err
+		}
+
 		p.entry.addAndSort(ent)
 
+		// Execute the entry before the scheduling
+		if p.Options.ExecuteOnCreate {
+			p.Options.Exeuction.ExecuteIfDue(ent)
+		}
+
 		// Notify for updates
 		p.Update.notifyForUpdates(models.NewUpdateWithData([]int{}, []*models.Entry{}, []*models.Entry{ent}))
 	}
@@ -238,10 +450,39 @@ func (p *Persistence) CreateEntries(entries []*models.Entry) ([]*models.Entry, *
 	return ent, resp, err
 }
 
+// CreateEntryWithoutCaching creates the entry like [Persistence.CreateEntry] but does not
+// add the created entry to the local cache and does not notify the observers.
+// Use this for creating a high volume of entries.
+//
+// The local cache is inconsistent until the entry is received through the WebSocket
+// or the data is reloaded with [Persistence.ReloadData]. Note that the server only notifies
+// this client about its own changes when the option "MultiInstance" is set.
+// Until then, the entry won't be executed by this client
+func (p *Persistence) CreateEntryWithoutCaching(entry models.Entry) (*models.Entry, *models.ErrorResponse) {
+	ent, err := p.Api.CreateEntry(entry)
+	if err == nil {
+		p.entry.linkAttribute(ent)
+	}
+
+	return ent, err
+}
+
+// CreateEntriesWithoutCaching is like [Persistence.CreateEntryWithoutCaching] but for
+// creating multiple entries at once
+func (p *Persistence) CreateEntriesWithoutCaching(entries []*models.Entry) ([]*models.Entry, *models.BulkResponse[models.Entry], *models.ErrorResponse) {
+	ent, resp, err := p.Api.CreateEntries(entries)
+	if err == nil && len(ent) > 0 {
+		p.entry.linkAttributes(&ent)
+	}
+
+	return ent, resp, err
+}
+
 func (p *Persistence) UpdateEntry(entry *models.Entry) (*models.Entry, *models.ErrorResponse) {
 	newEnt, err := p.Api.UpdateEntry(entry)
 	if err == nil {
 		p.entry.mux.Lock()
+		p.entry.inheritExecutionStateWithoutLock([]*models.Entry{newEnt})
 
 		// Remove the netry first
 		for i, e := range p.entry.data {
@@ -268,6 +509,7 @@ func (p *Persistence) UpdateEntries(entries []*models.Entry) ([]*models.Entry, *
 	if err == nil && len(updated) > 0 {
 		entCopied := updated
 		p.entry.mux.Lock()
+		p.entry.inheritExecutionStateWithoutLock(entCopied)
 
 		// Remove the entries first
 		utils.Filter(&entCopied, &p.entry.data, func(a *models.Entry, b *models.Entry) bool { return a.ID == b.ID })
@@ -289,6 +531,7 @@ func (p *Persistence) PatchEntries(entries []*models.Entry) ([]*models.Entry, *m
 	if err == nil && len(updated) > 0 {
 		entCopied := updated
 		p.entry.mux.Lock()
+		p.entry.inheritExecutionStateWithoutLock(entCopied)
 
 		// Remove the entries first
 		utils.Filter(&entCopied, &p.entry.data, func(a *models.Entry, b *models.Entry) bool { return a.ID == b.ID })
@@ -305,6 +548,35 @@ func (p *Persistence) PatchEntries(entries []*models.Entry) ([]*models.Entry, *m
 	return updated, resp, err
 }
 
+// inheritExecutionStateWithoutLock marks the given entries as executed if a cached entry
+// with the same ID and the same dates was already executed.
+// With the option "MultiInstance" the own changes are received again via the WebSocket
+// as a new entry. Without this, an already executed entry would be executed twice.
+// This method does NOT lock the data mutex
+func (p *persistenceEntry) inheritExecutionStateWithoutLock(entries []*models.Entry) {
+	executed := make(map[int]*models.Entry)
+	for _, e := range p.data {
+		if e.WasExecuted() {
+			executed[e.ID] = e
+		}
+	}
+	if len(executed) == 0 {
+		return
+	}
+
+	for _, e := range entries {
+		old, ok := executed[e.ID]
+		if !ok || old == e || e.WasExecuted() {
+			continue
+		}
+
+		// A changed date has to be executed again
+		if old.DateTime.Equal(e.DateTime.Time) && old.DateTimeExecution.Equal(e.DateTimeExecution.Time) {
+			e.SetExecuted(true)
+		}
+	}
+}
+
 // handleUpdate handles the merge of the given update for the locally
 // cached data
 func (p *persistenceEntry) handleUpdate(upd models.UpdateData[*models.Entry]) {
@@ -323,6 +595,8 @@ func (p *persistenceEntry) handleUpdate(upd models.UpdateData[*models.Entry]) {
 
 	// Update updated entries
 	if len(upd.Updated) > 0 {
+		p.inheritExecutionStateWithoutLock(upd.Updated)
+
 		entCopied := upd.Updated
 		// Remove the entries first
 		utils.Filter(&entCopied, &p.data, func(a *models.Entry, b *models.Entry) bool { return a.ID == b.ID })
@@ -0,0 +1,516 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// newTestPersistence returns a persistence layer whose API requests are handled by the
+// given handler. The attributes and entries are stored in the local cache without loading them
+func newTestPersistence(t *testing.T, handler http.HandlerFunc, attributes []*models.Attribute, entries ...*models.Entry) *Persistence {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	p := NewPersistence("key", api.ApiOptions{BaseUrl: srv.URL}, &PersistenceOptions{})
+	t.Cleanup(func() { p.Close() })
+
+	p.attribute.data = attributes
+	p.entry.addAndSort(entries...)
+
+	return p
+}
+
+// respondJSON returns a handler that responds with the given value for every request
+func respondJSON(v any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func TestForEachEntry(t *testing.T) {
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	now := time.Now().Add(time.Hour)
+	cached := []*models.Entry{
+		{ID: 1, Attribute: attributes[0], DateTime: models.DateTime{Time: now}},
+		{ID: 2, Attribute: attributes[1], DateTime: models.DateTime{Time: now.Add(time.Minute)}},
+		{ID: 3, Attribute: attributes[0], DateTime: models.DateTime{Time: now.Add(2 * time.Minute)}},
+	}
+	// Old entries are only known by the server
+	remote := []models.Entry{
+		{ID: 10, Attribute: &models.Attribute{ID: 2}},
+		{ID: 11, Attribute: &models.Attribute{ID: 1}},
+	}
+
+	tests := []struct {
+		name   string
+		filter models.EntryFilter
+		// Number of entries after which the iteration is stopped. Zero for all
+		stopAfter int
+		want      []int
+	}{
+		{name: "all cached", want: []int{1, 2, 3}},
+		{name: "filtered locally", filter: models.EntryFilter{Attributes: []int{1}}, want: []int{1, 3}},
+		{name: "stopped early", stopAfter: 1, want: []int{1}},
+		{name: "not handled locally", filter: models.EntryFilter{OldDates: true}, want: []int{10, 11}},
+		{name: "not handled locally stopped early", filter: models.EntryFilter{OldDates: true}, stopAfter: 1, want: []int{10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON(remote), attributes, cached...)
+
+			var got []int
+			err := p.ForEachEntry(tt.filter, func(e *models.Entry) bool {
+				if e.Attribute == nil || e.Attribute.Name == "" {
+					t.Errorf("Attribute of entry #%d is not linked", e.ID)
+				}
+				got = append(got, e.ID)
+				return tt.stopAfter == 0 || len(got) < tt.stopAfter
+			})
+			if err != nil {
+				t.Fatalf("ForEachEntry() returned an error: %s", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("ForEachEntry() iterated %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ForEachEntry() iterated %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestForEachEntryApiError(t *testing.T) {
+	p := newTestPersistence(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, nil)
+
+	if err := p.ForEachEntry(models.EntryFilter{OldDates: true}, func(e *models.Entry) bool { return true }); err == nil {
+		t.Errorf("ForEachEntry() didn't return the error of the API")
+	}
+	if _, found, err := p.FindEntry(models.EntryFilter{OldDates: true}); err == nil || found {
+		t.Errorf("FindEntry() = (%t, %v), want the error of the API", found, err)
+	}
+}
+
+func TestIterateEntriesWithoutLock(t *testing.T) {
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	now := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter models.EntryFilter
+		// Return an error of the callback after this number of entries. Zero for never
+		failAfter int
+
+		want    []int
+		wantErr bool
+	}{
+		{name: "all entries", want: []int{1, 2, 3}},
+		{name: "filtered locally", filter: models.EntryFilter{Attributes: []int{2}}, want: []int{2}},
+		{name: "stopped by the callback", failAfter: 2, want: []int{1, 2}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON([]models.Entry{}), attributes,
+				&models.Entry{ID: 1, Attribute: attributes[0], DateTime: models.DateTime{Time: now}},
+				&models.Entry{ID: 2, Attribute: attributes[1], DateTime: models.DateTime{Time: now.Add(time.Minute)}},
+				&models.Entry{ID: 3, Attribute: attributes[0], DateTime: models.DateTime{Time: now.Add(2 * time.Minute)}},
+			)
+
+			var got []int
+			done := make(chan *models.ErrorResponse)
+			go func() {
+				done <- p.IterateEntries(tt.filter, func(e *models.Entry) error {
+					got = append(got, e.ID)
+
+					// Modifying the cache within the callback must not block
+					p.entry.addAndSort(&models.Entry{ID: 100 + e.ID, Attribute: attributes[0], DateTime: models.DateTime{Time: now}})
+
+					if len(got) == tt.failAfter {
+						return fmt.Errorf("failed")
+					}
+					return nil
+				})
+			}()
+
+			select {
+			case err := <-done:
+				if (err != nil) != tt.wantErr {
+					t.Errorf("IterateEntries() returned the error %v, want an error: %t", err, tt.wantErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("IterateEntries() blocked while modifying the cache within the callback")
+			}
+
+			// Only the entries matching at the start of the iteration are passed
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("IterateEntries() passed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddAndSortDeduplicates(t *testing.T) {
+	date := time.Now().Add(time.Hour)
+	newEntry := func(id int, note string) *models.Entry {
+		return &models.Entry{ID: id, Attribute: &models.Attribute{ID: 1}, DateTime: models.DateTime{Time: date}, Note: note}
+	}
+
+	tests := []struct {
+		name   string
+		cached []*models.Entry
+		added  []*models.Entry
+		want   []string
+	}{
+		{
+			name:   "replaces cached entry",
+			cached: []*models.Entry{newEntry(1, "old"), newEntry(2, "other")},
+			added:  []*models.Entry{newEntry(1, "new")},
+			want:   []string{"new", "other"},
+		},
+		{
+			name:  "duplicates within the added entries",
+			added: []*models.Entry{newEntry(1, "first"), newEntry(2, "other"), newEntry(1, "last")},
+			want:  []string{"last", "other"},
+		},
+		{
+			name:   "duplicates within the added and cached entries",
+			cached: []*models.Entry{newEntry(1, "old")},
+			added:  []*models.Entry{newEntry(1, "first"), newEntry(1, "last")},
+			want:   []string{"last"},
+		},
+		{
+			name:   "entries without an ID are kept",
+			cached: []*models.Entry{newEntry(0, "cached")},
+			added:  []*models.Entry{newEntry(0, "first"), newEntry(0, "second")},
+			want:   []string{"cached", "first", "second"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &persistenceEntry{data: tt.cached}
+			p.addAndSortWithoutLock(tt.added...)
+
+			got := make([]string, len(p.data))
+			for i, e := range p.data {
+				got[i] = e.Note
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Cached entries = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Cached entries = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAddAndSortOrder(t *testing.T) {
+	date := time.Now().Add(time.Hour)
+	newEntry := func(id int, offset time.Duration) *models.Entry {
+		return &models.Entry{ID: id, Attribute: &models.Attribute{ID: 1}, DateTime: models.DateTime{Time: date.Add(offset)}}
+	}
+
+	tests := []struct {
+		name   string
+		cached []*models.Entry
+		added  []*models.Entry
+		want   []int
+	}{
+		{
+			name:  "same date ordered by ID",
+			added: []*models.Entry{newEntry(3, 0), newEntry(1, 0), newEntry(2, 0)},
+			want:  []int{1, 2, 3},
+		},
+		{
+			name:  "date before ID",
+			added: []*models.Entry{newEntry(1, time.Minute), newEntry(2, 0)},
+			want:  []int{2, 1},
+		},
+		{
+			name:   "added to cached entries with the same date",
+			cached: []*models.Entry{newEntry(2, 0), newEntry(4, 0)},
+			added:  []*models.Entry{newEntry(3, 0), newEntry(1, time.Minute), newEntry(5, -time.Minute)},
+			want:   []int{5, 2, 3, 4, 1},
+		},
+		{
+			name:   "replaced entry is moved",
+			cached: []*models.Entry{newEntry(1, 0), newEntry(2, 0)},
+			added:  []*models.Entry{newEntry(1, time.Minute)},
+			want:   []int{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &persistenceEntry{data: tt.cached}
+			p.addAndSortWithoutLock(tt.added...)
+
+			if got := entryIDs(p.data); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Cached entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The order of the server is not relied on when loading the entries
+	p := newTestPersistence(t, respondJSON([]*models.Entry{newEntry(3, 0), newEntry(1, 0), newEntry(2, -time.Minute)}), nil)
+	if err := p.entry.loadData(); err != nil {
+		t.Fatalf("loadData() returned an error: %s", err)
+	}
+	if got, want := entryIDs(p.entry.data), []int{2, 1, 3}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Loaded entries = %v, want %v", got, want)
+	}
+}
+
+// entryIDs returns the IDs of the given entries
+func entryIDs(entries []*models.Entry) []int {
+	ids := make([]int, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestCreateEntryMultiInstanceEcho(t *testing.T) {
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}}
+	created := map[string]any{
+		"id":        5,
+		"attribute": map[string]any{"id": 1},
+		"date_time": time.Now().Add(time.Hour).Format(models.TimeFormat),
+	}
+
+	tests := []struct {
+		name string
+		// Number of times the created entry is echoed within the update
+		echoes int
+	}{
+		{name: "single echo", echoes: 1},
+		{name: "duplicated echo", echoes: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON(created), attributes)
+			p.MultiInstance = true
+
+			if _, err := p.CreateEntry(models.Entry{Attribute: attributes[0], Offset: "+1h"}); err != nil {
+				t.Fatalf("CreateEntry() returned an error: %s", err)
+			}
+
+			// The server notifies this client also about its own created entry
+			msg := models.WebSocketMessage{Type: models.WebSocketTypeUpdate}
+			for i := 0; i < tt.echoes; i++ {
+				msg.Update.Entry.Created = append(msg.Update.Entry.Created, &models.Entry{ID: 5, Attribute: &models.Attribute{ID: 1}})
+			}
+			p.handleWebSocketMessage(msg)
+
+			if entries := p.GetEntriesAll(); len(entries) != 1 || entries[0].ID != 5 {
+				t.Errorf("GetEntriesAll() returned %d entries, want only the created entry", len(entries))
+			}
+		})
+	}
+}
+
+func TestInheritExecutionState(t *testing.T) {
+	// The dates are transferred by the server without fractional seconds
+	date := time.Now().Add(time.Hour).Truncate(time.Second)
+	newEntry := func(id int, dateTime time.Time, execution time.Time, executed bool) *models.Entry {
+		ent := (&models.Entry{ID: id, Attribute: &models.Attribute{ID: 1}, DateTime: models.DateTime{Time: dateTime}, DateTimeExecution: models.DateTime{Time: execution}}).Clone()
+		ent.SetExecuted(executed)
+		return ent
+	}
+
+	tests := []struct {
+		name     string
+		cached   *models.Entry
+		replaced *models.Entry
+		want     bool
+	}{
+		{name: "same dates", cached: newEntry(1, date, date, true), replaced: newEntry(1, date, date, false), want: true},
+		{name: "changed date time", cached: newEntry(1, date, date, true), replaced: newEntry(1, date.Add(time.Minute), date, false)},
+		{name: "changed execution time", cached: newEntry(1, date, date, true), replaced: newEntry(1, date, date.Add(-time.Minute), false)},
+		{name: "cached entry not executed", cached: newEntry(1, date, date, false), replaced: newEntry(1, date, date, false)},
+		{name: "other entry", cached: newEntry(1, date, date, true), replaced: newEntry(2, date, date, false)},
+		{name: "already executed", cached: newEntry(1, date, date, false), replaced: newEntry(1, date, date, true), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &persistenceEntry{data: []*models.Entry{tt.cached}}
+			p.inheritExecutionStateWithoutLock([]*models.Entry{tt.replaced})
+
+			if got := tt.replaced.WasExecuted(); got != tt.want {
+				t.Errorf("WasExecuted() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	// The state is kept for all ways an executed entry is replaced within the cache
+	paths := []struct {
+		name    string
+		replace func(p *Persistence, ent *models.Entry)
+	}{
+		{name: "added", replace: func(p *Persistence, ent *models.Entry) { p.entry.addAndSort(ent) }},
+		{name: "echo of a created entry", replace: func(p *Persistence, ent *models.Entry) {
+			p.entry.handleUpdate(models.UpdateData[*models.Entry]{Created: []*models.Entry{ent}})
+		}},
+		{name: "echo of an updated entry", replace: func(p *Persistence, ent *models.Entry) {
+			p.entry.handleUpdate(models.UpdateData[*models.Entry]{Updated: []*models.Entry{ent}})
+		}},
+		{name: "reloaded", replace: func(p *Persistence, ent *models.Entry) {
+			if err := p.entry.loadData(); err != nil {
+				t.Fatalf("loadData() returned an error: %s", err)
+			}
+		}},
+	}
+
+	for _, path := range paths {
+		t.Run(path.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON([]*models.Entry{newEntry(1, date, date, false)}), nil, newEntry(1, date, date, true))
+			path.replace(p, newEntry(1, date, date, false))
+
+			entries := p.GetEntriesAll()
+			if len(entries) != 1 || !entries[0].WasExecuted() {
+				t.Errorf("Cached entries = %v, want the executed entry #1", entryIDs(entries))
+			}
+		})
+	}
+}
+
+func TestDeleteEntryNoDbHook(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		noDb      bool
+		dateTime  time.Duration
+		execution time.Duration
+
+		wantHook bool
+	}{
+		{name: "execution time past", noDb: true, dateTime: time.Hour, execution: -time.Minute, wantHook: true},
+		{name: "execution time not reached", noDb: true, dateTime: time.Hour, execution: time.Minute},
+		{name: "without execution time", noDb: true, dateTime: time.Hour},
+		// The hook of these entries is triggered through the update of the server
+		{name: "stored in the database", dateTime: time.Hour, execution: -time.Minute},
+	}
+
+	deletes := []struct {
+		name   string
+		delete func(p *Persistence, id int) *models.ErrorResponse
+	}{
+		{
+			name: "DeleteEntry",
+			delete: func(p *Persistence, id int) *models.ErrorResponse {
+				_, err := p.DeleteEntry(id)
+				return err
+			},
+		},
+		{
+			name: "DeleteEntries",
+			delete: func(p *Persistence, id int) *models.ErrorResponse {
+				_, _, err := p.DeleteEntries([]int{id})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, d := range deletes {
+			t.Run(tt.name+" "+d.name, func(t *testing.T) {
+				attributes := []*models.Attribute{{ID: 1, Name: "a", NoDb: tt.noDb}}
+				ent := &models.Entry{ID: 1, Attribute: attributes[0], DateTime: models.DateTime{Time: now.Add(tt.dateTime)}}
+				if tt.execution != 0 {
+					ent.DateTimeExecution = models.DateTime{Time: now.Add(tt.execution)}
+				}
+				p := newTestPersistence(t, respondJSON(map[string]any{}), attributes, ent)
+
+				hooks := make(chan ExecutionType, 1)
+				p.Options.Exeuction.Executor = func(ent models.Entry, t ExecutionType) { hooks <- t }
+
+				if err := d.delete(p, 1); err != nil {
+					t.Fatalf("%s() returned an error: %s", d.name, err)
+				}
+
+				select {
+				case typ := <-hooks:
+					if !tt.wantHook {
+						t.Errorf("Executor was called with the type %v", typ)
+					} else if typ != DELETE {
+						t.Errorf("Executor was called with the type %v, want %v", typ, DELETE)
+					}
+				case <-time.After(100 * time.Millisecond):
+					if tt.wantHook {
+						t.Errorf("Delete hook was not called")
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCreateEntryExecuteOnCreate(t *testing.T) {
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}}
+
+	tests := []struct {
+		name            string
+		executeOnCreate bool
+		// Date of the created entry relative to now
+		dateTime time.Duration
+
+		wantExecuted bool
+	}{
+		{name: "disabled", dateTime: 0},
+		{name: "due entry", executeOnCreate: true, dateTime: 0, wantExecuted: true},
+		{name: "future entry", executeOnCreate: true, dateTime: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := map[string]any{
+				"id":        5,
+				"attribute": map[string]any{"id": 1},
+				"date_time": time.Now().Add(tt.dateTime).Format(models.TimeFormat),
+			}
+			p := newTestPersistence(t, respondJSON(created), attributes)
+			p.Options.ExecuteOnCreate = tt.executeOnCreate
+
+			executed := make(chan int, 2)
+			p.Options.Exeuction.Executor = func(ent models.Entry, t ExecutionType) { executed <- ent.ID }
+
+			if _, err := p.CreateEntry(models.Entry{Attribute: attributes[0], Offset: "now"}); err != nil {
+				t.Fatalf("CreateEntry() returned an error: %s", err)
+			}
+
+			if got := len(executed) == 1; got != tt.wantExecuted {
+				t.Errorf("Entry executed on return of CreateEntry() = %t, want %t", got, tt.wantExecuted)
+			}
+
+			// The scheduling doesn't execute the entry again
+			if tt.wantExecuted {
+				p.Options.Exeuction.schedule()
+				time.Sleep(50 * time.Millisecond)
+				if len(executed) != 1 {
+					t.Errorf("Entry was executed %d times", len(executed))
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,6 +13,30 @@ import (
 	"git.rpjosh.de/RPJosh/go-logger"
 )
 
+// DefaultIdleTimerHorizon is the duration after which the timer fires when no entry
+// has to be scheduled. The timer is always reset when the entries change, so the
+// value only has to be far enough in the future to never fire
+const DefaultIdleTimerHorizon = 85 * 365 * time.Hour
+
+// DefaultRescheduleDebounce is the default time window in which multiple reschedules
+// triggered by updates are collapsed into a single one
+const DefaultRescheduleDebounce = 50 * time.Millisecond
+
+// Interval in which the watchdog checks if the timer for the next execution
+// did not fire although the execution time has been exceeded
+const executionWatchdogInterval = 1 * time.Minute
+
+// The maximum time the server waits for an execution response
+const maxExecResponseTimeout = 60 * time.Second
+
+// ExecResponseTimeoutCode is the response code returned when an execution response
+// was not received within the timeout (like the code of the unix command "timeout")
+const ExecResponseTimeoutCode = 124
+
+// ExecResponseAbortedCode is the response code returned when an execution response
+// was canceled (like the code of a program terminated by SIGINT)
+const ExecResponseAbortedCode = 130
+
 // ExecutionType states the type of execution for which the
 // entry should be executed
 type ExecutionType int
@@ -25,6 +50,27 @@ const (
 	DELETE
 )
 
+// ScheduleOn states the date field of an entry that drives the scheduling.
+//
+// The server computes "DateTimeExecution" as "DateTime + execution offset" of the token
+// that is used. Without an execution offset both fields are equal
+type ScheduleOn int
+
+const (
+	// The entry is executed on "DateTimeExecution" (falling back to "DateTime").
+	// Whether the entry is kept in the list until "DateTimeExecution" is past depends on
+	// [Execution.IgnoreExecutionTime]
+	ScheduleOnAuto ScheduleOn = iota
+
+	// The entry is always executed on "DateTime". The execution offset of the token
+	// is ignored and the entry is removed after "DateTime" is past
+	ScheduleOnDateTime
+
+	// The entry is executed on "DateTimeExecution" (falling back to "DateTime") and kept in
+	// the list until both dates are past, regardless of [Execution.IgnoreExecutionTime]
+	ScheduleOnExecutionTime
+)
+
 // Execution manages the scheduling of entries and calls your custom
 // function on execution.
 //
@@ -40,19 +86,43 @@ type Execution struct {
 	// You have to return an execution response or nil for no response
 	ExecuterExecResponse func(models.Entry) *models.ExecutionResponse
 
+	// Same as "ExecuterExecResponse" but with a context that is canceled when the
+	// timeout of the entry was exceeded. If this is set, "ExecuterExecResponse" is not used
+	ExecuterExecResponseContext func(context.Context, models.Entry) *models.ExecutionResponse
+
 	// By default, an entry is kept in the locale list until the date fields
 	// "DateTime" and "DateTimeExecution" are past.
 	//
 	// When this field is set the "DateTimeExecution" field will be ignored
-	// and the entries are immediately removed from the list
+	// and the entries are immediately removed from the list.
+	// This is only respected for [ScheduleOnAuto]
 	IgnoreExecutionTime bool
 
+	// Date field of the entries that drives the execution. Defaulting to [ScheduleOnAuto]
+	ScheduleOn ScheduleOn
+
 	// By default, an update is only triggered if the DatetimeExecution AND
 	// the DateTime are in the past.
 	// With this option an (empty) update will also be fired when the DateTime
 	// is overwritten
 	TriggerUpdateOnDateTimeChanges bool
 
+	// Logger to use for this module. Defaulting to the global logger
+	Logger *logger.Logger
+
+	// Time window in which multiple reschedules triggered by updates (e.g. an entry
+	// that is updated rapidly) are collapsed into a single one. Use a negative value
+	// to disable the debouncing. Defaulting to [DefaultRescheduleDebounce]
+	RescheduleDebounce time.Duration
+
+	// Duration after which the timer initially fires when no entry is scheduled.
+	// Defaulting to [DefaultIdleTimerHorizon]
+	IdleTimerHorizon time.Duration
+
+	// Managed by persistence: duration for which executed entries are kept
+	// in the list after they are past
+	RemovalGracePeriod time.Duration
+
 	// Managed by persistence: update struct for tiggering updates
 	Update *PersistenceUpdate
 
@@ -62,21 +132,59 @@ type Execution struct {
 	// Managed by persistence: base context to use for scheduling
 	BaseContext context.Context
 
+	// Managed by persistence: minimum difference between the wall clock and the
+	// monotonic clock that is treated as a suspend of the system.
+	// Defaulting to [executionWatchdogInterval]
+	SuspendThreshold time.Duration
+
+	// Managed by persistence: maximum age of past entries with the flag "execute always"
+	// that are still executed (e.g. after a restart). Older entries are only marked as executed.
+	// Zero means that all past entries are executed
+	MaxCatchUpAge time.Duration
+
+	// Managed by persistence: function to call after a resume from a suspend
+	// was detected (e.g. to reload the possibly missed updates).
+	// The entries are rescheduled after this function returned
+	OnResume func()
+
 	// Persitence entry to remove the entries from
 	persEntry *persistenceEntry
 
+	// Clock used to detect a suspend. Defaulting to the system clock
+	clock clock
+
 	// The context of the currently scheduled executions
 	context       context.Context
 	cancelContext context.CancelFunc
 
+	// The context of the currently running exec responses
+	execResponseContext       context.Context
+	cancelExecResponseContext context.CancelFunc
+
+	// Whether the exec responses were canceled. Later received exec responses are aborted directly
+	execResponsesCanceled bool
+
 	// Mutex to synchronize cancel function and context access
 	mtx sync.Mutex
 
+	// Mutex to prevent concurrent starts of the scheduler
+	startMtx sync.Mutex
+
+	// Closed when the currently running scheduler goroutine exited
+	schedulerDone chan struct{}
+
+	// Whether a debounced reschedule is pending
+	reschedulePending atomic.Bool
+
 	// Timer for removing the entry from the list
 	normalTimer *time.Timer
 
 	// The ID of the entry to execute next
 	nextEntry atomic.Int64
+
+	// The time (unix nano) on which the timer should fire next. Zero if no
+	// execution is scheduled
+	nextExecution atomic.Int64
 }
 
 // NewExecution creates a new struct for scheduling the execution of entries.
@@ -90,35 +198,107 @@ func NewExecution(executor func(models.Entry, ExecutionType), executerExecRespon
 	}
 }
 
+// executeOnDateTime returns whether the entries are executed on their
+// "DateTime" instead of "DateTimeExecution"
+func (e *Execution) executeOnDateTime() bool {
+	return e.ScheduleOn == ScheduleOnDateTime
+}
+
+// ignoreExecutionTime returns whether "DateTimeExecution" is ignored when checking
+// if an entry is past
+func (e *Execution) ignoreExecutionTime() bool {
+	switch e.ScheduleOn {
+	case ScheduleOnDateTime:
+		return true
+	case ScheduleOnExecutionTime:
+		return false
+	default:
+		return e.IgnoreExecutionTime
+	}
+}
+
+// NextEntry returns the entry for which the timer is scheduled next and the time
+// on which the timer fires. If nothing is scheduled, nil is returned
+func (e *Execution) NextEntry() (*models.Entry, time.Time) {
+	next := e.nextExecution.Load()
+	if next == 0 || e.persEntry == nil {
+		return nil, time.Time{}
+	}
+
+	id := int(e.nextEntry.Load())
+	e.persEntry.mux.RLock()
+	defer e.persEntry.mux.RUnlock()
+	for _, ent := range e.persEntry.data {
+		if ent.ID == id {
+			return ent, time.Unix(0, next)
+		}
+	}
+
+	return nil, time.Time{}
+}
+
 // StartScheduling starts the scheduling of the executions.
 // If an entry was executed it will be removed from the local list and
 // the "Executor()" function with a copy of the entry will be called.
-// After that the scheduling will be resetted for the next entry
+// After that the scheduling will be resetted for the next entry.
+//
+// When called again, the scheduling of the previous call is stopped before.
+// So only one scheduler is running at a time
+//
+// After a suspend of the system, all entries that became due in the meantime are
+// executed within [executionWatchdogInterval] after the system was resumed
 func (e *Execution) StartScheduling() {
-	e.mtx.Lock()
+	e.startMtx.Lock()
+	defer e.startMtx.Unlock()
 
-	// Cancel contexts
+	// Cancel the previous scheduler and wait until it exited.
+	// The mutex has to be released in the meantime because the scheduler could be
+	// within an execution that does require the lock
+	e.mtx.Lock()
 	if e.cancelContext != nil {
 		e.cancelContext()
 	}
+	schedulerDone := e.schedulerDone
+	e.mtx.Unlock()
+	if schedulerDone != nil {
+		<-schedulerDone
+	}
+
+	e.mtx.Lock()
+
 	// Create a new context
 	e.context, e.cancelContext = context.WithCancel(e.BaseContext)
+	ctx := e.context
+	done := make(chan struct{})
+	e.schedulerDone = done
 
 	// Stop old timers
 	if e.normalTimer != nil {
-		e.normalTimer.Stop()
+		e.stopTimer()
 	} else {
-		// Start a "fake" timer which will fier in 85 days
-		e.normalTimer = time.NewTimer(85 * 365 * time.Hour)
+		// A timer can't be created without starting it. So a "fake" timer is
+		// started which doesn't fire until the first entry is scheduled
+		horizon := e.IdleTimerHorizon
+		if horizon <= 0 {
+			horizon = DefaultIdleTimerHorizon
+		}
+		e.normalTimer = time.NewTimer(horizon)
 	}
 
 	// Start a channel which is listening for the timers event
 	go func() {
+		defer close(done)
+		watchdog := time.NewTicker(executionWatchdogInterval)
+		defer watchdog.Stop()
+		lastTick := readClock(e.getClock())
+
 		for {
 			select {
 			case <-e.normalTimer.C:
 				e.handleExecution()
-			case <-e.context.Done():
+			case <-watchdog.C:
+				lastTick = e.watchdogTick(lastTick)
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -128,6 +308,57 @@ func (e *Execution) StartScheduling() {
 	e.schedule()
 }
 
+// StopScheduling stops the scheduling started with [Execution.StartScheduling] and
+// waits until the scheduler exited. Running executions are not aborted.
+// It's safe to call this multiple times
+func (e *Execution) StopScheduling() {
+	e.startMtx.Lock()
+	defer e.startMtx.Unlock()
+
+	// The mutex has to be released while waiting (see "StartScheduling()")
+	e.mtx.Lock()
+	if e.cancelContext != nil {
+		e.cancelContext()
+	}
+	schedulerDone := e.schedulerDone
+	e.mtx.Unlock()
+	if schedulerDone != nil {
+		<-schedulerDone
+	}
+
+	e.mtx.Lock()
+	if e.normalTimer != nil {
+		e.stopTimer()
+	}
+	e.nextExecution.Store(0)
+	e.mtx.Unlock()
+}
+
+// requestSchedule schedules the next execution after the debounce time window
+// (see [Execution.RescheduleDebounce]). All requests within this window are
+// collapsed into a single reschedule that is based on the latest data
+func (e *Execution) requestSchedule() {
+	debounce := e.RescheduleDebounce
+	if debounce == 0 {
+		debounce = DefaultRescheduleDebounce
+	}
+	if debounce < 0 {
+		e.schedule()
+		return
+	}
+
+	// A reschedule is already pending
+	if !e.reschedulePending.CompareAndSwap(false, true) {
+		return
+	}
+
+	time.AfterFunc(debounce, func() {
+		// Reset the flag before scheduling so that no update is missed
+		e.reschedulePending.Store(false)
+		e.schedule()
+	})
+}
+
 // schedule schedules the next execution of the entries and stops all
 // old timers
 func (e *Execution) schedule() {
@@ -135,33 +366,163 @@ func (e *Execution) schedule() {
 	defer e.mtx.Unlock()
 
 	// Get the next entry to execute
-	nextEntry := e.getNextEntryNormal(nil)
+	nextEntry, graced := e.getNextEntryNormal()
 
 	// Start timer
-	if nextEntry != nil {
-		// Update the next ID
-		e.nextEntry.Store(int64(nextEntry.ID))
-
+	if nextEntry != nil || graced != nil {
 		// Get the date on which the timer should fire
-		dateTime := nextEntry.DateTime.Time
-		if !nextEntry.WasExecuted() && !nextEntry.DateTimeExecution.IsZero() && nextEntry.DateTimeExecution.Before(dateTime) && nextEntry.DateTimeExecution.After(time.Now()) {
-			dateTime = nextEntry.DateTimeExecution.Time
+		var dateTime time.Time
+		if nextEntry != nil {
+			dateTime = nextEntry.DateTime.Time
+			if e.ScheduleOn != ScheduleOnDateTime && !nextEntry.WasExecuted() && !nextEntry.DateTimeExecution.IsZero() && nextEntry.DateTimeExecution.Before(dateTime) && nextEntry.DateTimeExecution.After(time.Now()) {
+				dateTime = nextEntry.DateTimeExecution.Time
+			}
+		}
+
+		// The removal of an entry within the grace period is due earlier.
+		// The timer fires for the (already executed) entry only to remove it
+		if graced != nil {
+			removal := graced.PastTime(e.ignoreExecutionTime()).Add(e.RemovalGracePeriod)
+			if nextEntry == nil || removal.Before(dateTime) {
+				nextEntry = graced
+				dateTime = removal
+			}
 		}
 
-		logger.Debug(utils.Sprintfl("Scheduled next execution in %.1f seconds (#%d)", time.Until(dateTime).Seconds(), nextEntry.ID))
+		// Update the next ID
+		e.nextEntry.Store(int64(nextEntry.ID))
+		e.nextExecution.Store(dateTime.UnixNano())
+
+		e.log().Debug(utils.Sprintfl("Scheduled next execution in %.1f seconds (#%d)", time.Until(dateTime).Seconds(), nextEntry.ID))
 
 		if e.normalTimer == nil {
 			return
 		} else {
-			e.normalTimer.Stop()
+			e.stopTimer()
 			e.normalTimer.Reset(time.Until(dateTime))
 		}
-	} else {
+	} else if e.normalTimer != nil {
 		// Reset the times
-		logger.Debug("Clearing timer for execution")
-		e.normalTimer.Stop()
+		e.log().Debug("Clearing timer for execution")
+		e.stopTimer()
 		e.nextEntry.Store(0)
+		e.nextExecution.Store(0)
+	}
+}
+
+// watchdogTick checks if the system was suspended since the last tick. After a resume,
+// the data is reloaded (see "OnResume") before all entries are rescanned, so that entries
+// deleted or changed while being suspended are not executed.
+// Otherwise, it's checked if the timer got stuck.
+// The reading of the clock for the next tick is returned
+func (e *Execution) watchdogTick(last clockReading) clockReading {
+	now := readClock(e.getClock())
+
+	if jump := clockJump(last, now); jump > e.suspendThreshold() {
+		// The wall clock advanced more than the monotonic clock. Because the
+		// timer is based on the monotonic clock, the next execution could be delayed
+		e.log().Info("Detected a clock jump of %.0f seconds (e.g. after a suspend). Rescanning all entries", jump.Seconds())
+		if e.OnResume != nil {
+			e.OnResume()
+		}
+		e.schedule()
+	} else {
+		e.checkStuckTimer()
 	}
+
+	return now
+}
+
+// clock is the source of the wall clock and the monotonic clock used to detect a suspend
+type clock interface {
+	// Now returns the current time of the wall clock
+	Now() time.Time
+
+	// Monotonic returns the current reading of the monotonic clock that
+	// does not advance while the system is suspended
+	Monotonic() time.Duration
+}
+
+// systemClock is the [clock] of the system
+type systemClock struct {
+	start time.Time
+}
+
+func (c systemClock) Now() time.Time {
+	// Strip the monotonic reading so that only the wall clock is compared
+	return time.Now().Round(0)
+}
+
+func (c systemClock) Monotonic() time.Duration {
+	return time.Since(c.start)
+}
+
+// processStart is the base of the monotonic readings of the [systemClock]
+var processStart = time.Now()
+
+// getClock returns the clock to use for the detection of a suspend
+func (e *Execution) getClock() clock {
+	if e.clock != nil {
+		return e.clock
+	}
+
+	return systemClock{start: processStart}
+}
+
+// clockReading is the reading of the wall clock and the monotonic clock at the same time
+type clockReading struct {
+	wall      time.Time
+	monotonic time.Duration
+}
+
+// readClock reads the current time of the given clock
+func readClock(c clock) clockReading {
+	return clockReading{wall: c.Now(), monotonic: c.Monotonic()}
+}
+
+// clockJump returns the duration the wall clock advanced more than the
+// monotonic clock between the two given readings.
+//
+// Go timers are based on the monotonic clock which does not advance while the
+// system is suspended. So a timer scheduled before a suspend fires too late by the
+// duration of the suspend. The wall clock (which the execution dates are based on)
+// does advance, so this difference is used to detect a suspend
+func clockJump(last clockReading, now clockReading) time.Duration {
+	return now.wall.Sub(last.wall) - (now.monotonic - last.monotonic)
+}
+
+// suspendThreshold returns the minimum clock jump that is treated as a suspend
+func (e *Execution) suspendThreshold() time.Duration {
+	if e.SuspendThreshold > 0 {
+		return e.SuspendThreshold
+	}
+
+	return executionWatchdogInterval
+}
+
+// stopTimer stops the timer and drains a not yet received value from the channel.
+// Otherwise, an old value would trigger an execution directly after the timer
+// was resetted
+func (e *Execution) stopTimer() {
+	if !e.normalTimer.Stop() {
+		select {
+		case <-e.normalTimer.C:
+		default:
+		}
+	}
+}
+
+// checkStuckTimer reschedules the executions if the timer did not fire
+// although the time of the next execution is already past for a longer time.
+// This can be the case if an error path did miss to reset the timer
+func (e *Execution) checkStuckTimer() {
+	next := e.nextExecution.Load()
+	if next == 0 || time.Since(time.Unix(0, next)) < executionWatchdogInterval {
+		return
+	}
+
+	e.log().Warning("Timer for the next execution (#%d) did not fire. Rescheduling", e.nextEntry.Load())
+	e.schedule()
 }
 
 // handleExecution handles the immediate execution of the next entry
@@ -171,32 +532,38 @@ func (e *Execution) handleExecution() {
 	// Get the entry to execute next
 	nextEntryId := e.nextEntry.Load()
 	if nextEntryId == 0 {
-		logger.Warning("Should execute entry now but couldn't determine the next entry")
+		e.log().Warning("Should execute entry now but couldn't determine the next entry")
 		e.mtx.Unlock()
+
+		// Schedule again so that the timer doesn't stall
+		e.schedule()
 		return
 	}
 
 	nextEntry, _ := e.Api.GetEntry(int(nextEntryId))
 	if nextEntry == nil {
-		logger.Warning("Should execute entry now but couldn't find an entry with id %d", nextEntryId)
+		e.log().Warning("Should execute entry now but couldn't find an entry with id %d", nextEntryId)
 		e.mtx.Unlock()
+
+		// The entry was removed in the meantime. Schedule the next one
+		e.schedule()
 		return
 	}
 
 	e.mtx.Unlock()
 
 	// Check weather to execute the entry or just trigger an update
-	if nextEntry.ShouldExecuteNow() {
-		e.Execute(nextEntry)
+	if nextEntry.ShouldExecuteNowOn(e.executeOnDateTime()) {
+		e.executeDue(nextEntry)
 	}
 
 	// The entry can be removed because the dates of "DateTime" and
 	// "DateTimeExecution" are passed
-	if nextEntry.IsPast(e.IgnoreExecutionTime) {
+	if nextEntry.IsPast(e.ignoreExecutionTime()) {
 		// The entry will be removed within the next reschedule
 		e.schedule()
-	} else if e.TriggerUpdateOnDateTimeChanges && !nextEntry.ShouldExecuteNow() {
-		logger.Debug("Triggering an update that the entries DateTime is past")
+	} else if e.TriggerUpdateOnDateTimeChanges && !nextEntry.ShouldExecuteNowOn(e.executeOnDateTime()) {
+		e.log().Debug("Triggering an update that the entries DateTime is past")
 		// A rescheduling is not needed because reschedule is triggered from outside
 		e.Update.notifyForUpdates(nil)
 	} else {
@@ -208,58 +575,64 @@ func (e *Execution) handleExecution() {
 // getNextEntryNormal returns the entry that should be executed
 // at the next time. If no entry was found nil will be returned.
 // If any old entries are found they got removed / executed immediately.
+// Additionally, the executed entry within the removal grace period that should
+// be removed next is returned (if any).
 //
-// Because this function can call itself recursively you have to provide nil or
-// the previous update object for the update.
-// In the last function call all previously removed entries will be handled
-func (e *Execution) getNextEntryNormal(update *models.UpdateData[*models.Entry]) (rtc *models.Entry) {
+// When entries were removed, nil is returned because the update of the
+// removed entries does trigger a rescheduling
+func (e *Execution) getNextEntryNormal() (*models.Entry, *models.Entry) {
 	e.persEntry.mux.RLock()
 
-	// First call of the function
-	if update == nil {
-		update = &models.UpdateData[*models.Entry]{}
-	}
-
+	// Execute all entries that are due now. Because this marks the entries as executed,
+	// the selection has to be done afterwards
 	for i := range e.persEntry.data {
-		if e.persEntry.data[i].ShouldExecuteNow() {
-			// Execute the entry immediate
-			e.Execute(e.persEntry.data[i])
-
-			// The entry should be removed if it's dateTime is in the past
-			if e.persEntry.data[i].IsPast(e.IgnoreExecutionTime) {
-				// Mark it for removal
-				update.Deleted = append(update.Deleted, e.persEntry.data[i].ID)
-			} else {
-				// It could be possible that this entry should be executed next.
-				// So the rescheduling has to be done again from the beginning
-				e.persEntry.mux.RUnlock()
-				return e.getNextEntryNormal(update)
-			}
-		} else if e.persEntry.data[i].IsPast(e.IgnoreExecutionTime) {
-			// Mark it for removal
-			update.Deleted = append(update.Deleted, e.persEntry.data[i].ID)
-		} else if rtc == nil ||
-			// Check if the execution time is before rtc and both were not already executed
-			(e.persEntry.data[i].GetExecutionTime(e.IgnoreExecutionTime).Before(rtc.GetExecutionTime(e.IgnoreExecutionTime)) && !e.persEntry.data[i].WasExecuted()) && !rtc.WasExecuted() ||
-			// If rtc was scheduled for DateTime (already executed) and this DateTimeExecution is less than rtc's DateTime
-			(rtc.WasExecuted() && !e.persEntry.data[i].WasExecuted() && e.persEntry.data[i].GetExecutionTime(e.IgnoreExecutionTime).Before(rtc.DateTime.Time)) ||
-			// Check also if the normal date is before rtc's execution time
-			e.persEntry.data[i].DateTime.Time.Before(rtc.GetExecutionTime(e.IgnoreExecutionTime)) ||
-			// And finally check if the normal date is before rtc's normal time
-			e.persEntry.data[i].DateTime.Time.Before(rtc.DateTime.Time) {
-			// We finally found an entry which execution time or dateTime is before rtc, and it is not in the past
-			rtc = e.persEntry.data[i]
+		if e.persEntry.data[i].ShouldExecuteNowOn(e.executeOnDateTime()) {
+			e.executeDue(e.persEntry.data[i])
 		}
 	}
+
+	rtc, past, graced := SelectNextEntry(e.persEntry.data, e.ignoreExecutionTime(), e.RemovalGracePeriod)
 	e.persEntry.mux.RUnlock()
 
-	// Notify for updates if an entry was deleted or removed
-	if len(update.Deleted) > 0 {
-		e.persEntry.handleUpdate(*update)
+	// Notify for updates if an entry was removed
+	if len(past) > 0 {
+		update := models.UpdateData[*models.Entry]{Deleted: past}
+		e.persEntry.handleUpdate(update)
 		e.Update.notifyForUpdates(models.NewUpdateWithData(update.Deleted, update.Updated, update.Created))
 
 		// Return nil because update calls this function again
-		return nil
+		return nil, nil
+	}
+
+	return rtc, graced
+}
+
+// SelectNextEntry selects the entry of the given entries that should be executed
+// at the next time. Entries whose time fields all lie in the past are not taken
+// into account and their IDs are returned as "past" for the removal.
+// This function doesn't execute any entry or modify the given entries.
+//
+// Executed entries are only returned as "past" after the grace period has expired.
+// Until then, the entry that has to be removed next is returned as "graced".
+//
+// The order of the entries is determined by [models.Entry.CompareExecution]
+func SelectNextEntry(entries []*models.Entry, ignoreExecutionTime bool, gracePeriod time.Duration) (rtc *models.Entry, past []int, graced *models.Entry) {
+	for _, ent := range entries {
+		if ent.IsPast(ignoreExecutionTime) {
+			if gracePeriod > 0 && ent.WasExecuted() && time.Since(ent.PastTime(ignoreExecutionTime)) < gracePeriod {
+				// Keep the entry within the grace period
+				if graced == nil || ent.PastTime(ignoreExecutionTime).Before(graced.PastTime(ignoreExecutionTime)) {
+					graced = ent
+				}
+				continue
+			}
+
+			// Mark it for removal
+			past = append(past, ent.ID)
+		} else if rtc == nil || ent.CompareExecution(rtc, ignoreExecutionTime) < 0 {
+			// We finally found an entry which execution time or dateTime is before rtc, and it is not in the past
+			rtc = ent
+		}
 	}
 
 	return
@@ -268,28 +641,74 @@ func (e *Execution) getNextEntryNormal(update *models.UpdateData[*models.Entry])
 // Execute executes the given entry and marks the entry as executed
 // if the attribute is from the type "exec_response"
 func (e *Execution) Execute(ent *models.Entry) {
-	logger.Debug("Executing entry %s with attribute %q (#%d)", ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
+	e.log().Debug("Executing entry %s with attribute %q (#%d)", ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
 
-	// Mark entry as exeucted (locally and also in the api for EA)
+	e.markExecuted(ent)
+
+	// Call the execute function
+	if e.Executor != nil {
+		go func(ent models.Entry) {
+			e.Executor(ent, DEFAULT)
+		}(*ent)
+	}
+}
+
+// markExecuted marks the entry as exeucted (locally and also in the api for EA)
+func (e *Execution) markExecuted(ent *models.Entry) {
 	ent.SetExecuted(true)
 	if ent.Attribute.ExecuteAlways {
 		go func(id int) {
 			if err := e.Api.MarkEntryAsExecuted(id); err != nil {
-				logger.Warning("Failed to register entry %d as executed: %s", id, err)
+				e.log().Warning("Failed to register entry %d as executed: %s", id, err)
 			}
 		}(ent.ID)
 	}
+}
+
+// ExecuteIfDue executes the given entry synchronously if it should be executed now
+// and wasn't executed yet. This function returns after the executor returned.
+// Whether the entry was executed is returned
+func (e *Execution) ExecuteIfDue(ent *models.Entry) bool {
+	// The lock prevents a concurrent execution through the scheduling
+	e.mtx.Lock()
+	if !ent.ShouldExecuteNowOn(e.executeOnDateTime()) {
+		e.mtx.Unlock()
+		return false
+	}
+	e.log().Debug("Executing entry %s with attribute %q (#%d) immediately", ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
+	e.markExecuted(ent)
+	e.mtx.Unlock()
 
-	// Call the execute function
 	if e.Executor != nil {
-		go func(ent models.Entry) {
-			e.Executor(ent, DEFAULT)
-		}(*ent)
+		e.Executor(*ent, DEFAULT)
 	}
+	return true
 }
 
+// executeDue executes the given entry that is due now. Past entries with the flag "execute always"
+// that are older than [Execution.MaxCatchUpAge] are only marked as executed
+func (e *Execution) executeDue(ent *models.Entry) {
+	if e.MaxCatchUpAge > 0 && ent.Attribute.ExecuteAlways && time.Since(ent.GetExecutionTime(e.executeOnDateTime())) > e.MaxCatchUpAge {
+		e.log().Info("Skipping execution of entry #%d because it's due since %s", ent.ID, ent.GetExecutionTime(e.executeOnDateTime()).Format(models.TimeFormatPretty))
+
+		ent.SetExecuted(true)
+		go func(id int) {
+			if err := e.Api.MarkEntryAsExecuted(id); err != nil {
+				e.log().Warning("Failed to register entry %d as executed: %s", id, err)
+			}
+		}(ent.ID)
+		return
+	}
+
+	e.Execute(ent)
+}
+
+// ExecuteDelete calls the executor for an entry with an execution time in the past
+// that was deleted by the user ("onDeleteHook").
+// For entries of the type no_db this is only the case when the entry was deleted
+// through the persistence layer because the server doesn't store these entries
 func (e *Execution) ExecuteDelete(ent *models.Entry) {
-	logger.Debug("Executing delete hook for entry %s with attribute %q (#%d)", ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
+	e.log().Debug("Executing delete hook for entry %s with attribute %q (#%d)", ent.DateTime.FormatPretty(), ent.Attribute.Name, ent.ID)
 
 	// Call the execute function
 	if e.Executor != nil {
@@ -301,14 +720,110 @@ func (e *Execution) ExecuteDelete(ent *models.Entry) {
 
 // ExecuteExecResponse executes an entry with an attribute of the
 // type "exec_response" and returns the execution response. This method
-// does block until a response was received
+// does block until a response was received or the timeout of the entry
+// was exceeded. In the latter case a response with the code [ExecResponseTimeoutCode]
+// is returned.
 //
 // If no function was provided to execute this entry nil is returned as
 // a response.
 func (e *Execution) ExecuteExecResponse(ent *models.Entry) *models.ExecutionResponse {
-	if e.ExecuterExecResponse == nil {
+	if e.ExecuterExecResponse == nil && e.ExecuterExecResponseContext == nil {
 		return nil
-	} else {
-		return e.ExecuterExecResponse(*ent)
 	}
+
+	timeout := ExecResponseTimeout(ent)
+	ctx, cancel := context.WithTimeout(e.getExecResponseContext(), timeout)
+	defer cancel()
+
+	// Don't start the execution at all if the exec responses were already canceled
+	if ctx.Err() == context.Canceled {
+		e.log().Info("Execution of entry #%d was aborted", ent.ID)
+		return models.NewExecutionResponse(ent.ID, ExecResponseAbortedCode, "Execution was aborted")
+	}
+
+	// Execute it in the background to not block longer than the timeout
+	result := make(chan *models.ExecutionResponse, 1)
+	go func(ent models.Entry) {
+		if e.ExecuterExecResponseContext != nil {
+			result <- e.ExecuterExecResponseContext(ctx, ent)
+		} else {
+			result <- e.ExecuterExecResponse(ent)
+		}
+	}(*ent)
+
+	select {
+	case resp := <-result:
+		return resp
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			e.log().Info("Execution of entry #%d was aborted", ent.ID)
+			return models.NewExecutionResponse(ent.ID, ExecResponseAbortedCode, "Execution was aborted")
+		}
+
+		e.log().Warning("Execution of entry #%d did not finish within %.0f seconds", ent.ID, timeout.Seconds())
+		return models.NewExecutionResponse(ent.ID, ExecResponseTimeoutCode, fmt.Sprintf("Execution timed out after %.0f seconds", timeout.Seconds()))
+	}
+}
+
+// getExecResponseContext returns the context to use for running exec responses.
+// After "CancelExecResponses()" was called, the returned context is always canceled
+func (e *Execution) getExecResponseContext() context.Context {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.execResponseContext == nil {
+		baseContext := e.BaseContext
+		if baseContext == nil {
+			baseContext = context.Background()
+		}
+		e.execResponseContext, e.cancelExecResponseContext = context.WithCancel(baseContext)
+
+		if e.execResponsesCanceled {
+			e.cancelExecResponseContext()
+		}
+	}
+
+	return e.execResponseContext
+}
+
+// CancelExecResponses cancels all currently running executions of exec_response
+// entries. For these entries a response with the code [ExecResponseAbortedCode] is
+// returned to the server.
+// The cancellation is permanent: exec responses received afterwards (e.g. while shutting down)
+// are aborted without calling the executor.
+// This is also done automatically when the base context is canceled
+func (e *Execution) CancelExecResponses() {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.execResponsesCanceled = true
+	if e.cancelExecResponseContext != nil {
+		e.cancelExecResponseContext()
+	}
+}
+
+// ExecResponseTimeout returns the maximum time to wait for an execution response
+// of the given entry. This is the timeout of the entry or the default timeout of
+// the attribute limited to [maxExecResponseTimeout]
+func ExecResponseTimeout(ent *models.Entry) time.Duration {
+	timeout := maxExecResponseTimeout
+	if ent.Timeout.Valid {
+		timeout = time.Duration(ent.Timeout.Int32) * time.Second
+	} else if ent.Attribute != nil && ent.Attribute.ExecResponse.DefaultTimeout > 0 {
+		timeout = time.Duration(ent.Attribute.ExecResponse.DefaultTimeout) * time.Second
+	}
+
+	if timeout <= 0 || timeout > maxExecResponseTimeout {
+		return maxExecResponseTimeout
+	}
+	return timeout
+}
+
+// log returns the logger to use for the scheduling of executions
+func (e *Execution) log() *logger.Logger {
+	if e.Logger != nil {
+		return e.Logger
+	}
+
+	return logger.GetGlobalLogger()
 }
@@ -0,0 +1,881 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// fakeClock is a [clock] whose readings are advanced manually
+type fakeClock struct {
+	wall      time.Time
+	monotonic time.Duration
+}
+
+func (c *fakeClock) Now() time.Time           { return c.wall }
+func (c *fakeClock) Monotonic() time.Duration { return c.monotonic }
+
+func (c *fakeClock) advance(wall time.Duration, monotonic time.Duration) {
+	c.wall = c.wall.Add(wall)
+	c.monotonic += monotonic
+}
+
+// newTestExecution returns an execution scheduling the given entries. The IDs
+// of the executed entries are sent to the returned channel
+func newTestExecution(entries ...*models.Entry) (*Execution, chan int) {
+	executed := make(chan int, len(entries)+1)
+
+	pe := &persistenceEntry{}
+	pe.addAndSortWithoutLock(entries...)
+
+	return &Execution{
+		Executor:  func(ent models.Entry, t ExecutionType) { executed <- ent.ID },
+		Update:    &PersistenceUpdate{},
+		persEntry: pe,
+	}, executed
+}
+
+// newTestEntry returns an entry with the given ID that is due at the given time
+func newTestEntry(id int, dateTime time.Time) *models.Entry {
+	return (&models.Entry{
+		ID:        id,
+		Attribute: &models.Attribute{ID: 1, Name: "test"},
+		DateTime:  models.DateTime{Time: dateTime},
+	}).Clone()
+}
+
+func TestWatchdogTick(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// Advance of the wall and monotonic clock since the last tick
+		wall      time.Duration
+		monotonic time.Duration
+
+		// Whether the entry is deleted by the reload
+		deleteOnResume bool
+
+		wantResume  bool
+		wantExecute bool
+	}{
+		{name: "no jump", wall: time.Minute, monotonic: time.Minute},
+		{name: "jump below threshold", wall: time.Minute + 30*time.Second, monotonic: time.Minute},
+		{name: "suspend", wall: time.Hour, monotonic: time.Minute, wantResume: true, wantExecute: true},
+		{name: "entry deleted while suspended", wall: time.Hour, monotonic: time.Minute, deleteOnResume: true, wantResume: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The entry became due while the system was suspended
+			e, executed := newTestExecution(newTestEntry(1, time.Now().Add(-time.Second)))
+			clk := &fakeClock{wall: time.Now()}
+			e.clock = clk
+
+			var mtx sync.Mutex
+			var events []string
+			e.OnResume = func() {
+				mtx.Lock()
+				events = append(events, "resume")
+				mtx.Unlock()
+
+				if tt.deleteOnResume {
+					e.persEntry.handleUpdate(models.UpdateData[*models.Entry]{Deleted: []int{1}})
+				}
+			}
+
+			last := readClock(clk)
+			clk.advance(tt.wall, tt.monotonic)
+			if got := e.watchdogTick(last); got != readClock(clk) {
+				t.Errorf("watchdogTick() returned %v, want %v", got, readClock(clk))
+			}
+
+			select {
+			case id := <-executed:
+				if !tt.wantExecute {
+					t.Errorf("entry #%d was executed", id)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantExecute {
+					t.Errorf("entry was not executed")
+				}
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if resumed := len(events) == 1; resumed != tt.wantResume {
+				t.Errorf("OnResume called = %t, want %t", resumed, tt.wantResume)
+			}
+		})
+	}
+}
+
+func TestClockJump(t *testing.T) {
+	base := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		last clockReading
+		now  clockReading
+		want time.Duration
+	}{
+		{
+			name: "in sync",
+			last: clockReading{wall: base, monotonic: time.Second},
+			now:  clockReading{wall: base.Add(time.Minute), monotonic: time.Second + time.Minute},
+			want: 0,
+		},
+		{
+			name: "suspended",
+			last: clockReading{wall: base, monotonic: time.Second},
+			now:  clockReading{wall: base.Add(time.Hour), monotonic: time.Second + time.Minute},
+			want: 59 * time.Minute,
+		},
+		{
+			name: "wall clock set back",
+			last: clockReading{wall: base, monotonic: 0},
+			now:  clockReading{wall: base.Add(-time.Minute), monotonic: time.Minute},
+			want: -2 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clockJump(tt.last, tt.now); got != tt.want {
+				t.Errorf("clockJump() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteExecResponseCanceled(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the exec responses are canceled before the entry is received
+		cancelBefore bool
+		// Whether the exec responses are canceled while the entry is executed
+		cancelDuring bool
+		// Whether the base context is canceled before the entry is received
+		baseCanceled bool
+
+		wantCode   int
+		wantCalled bool
+	}{
+		{name: "not canceled", wantCode: 0, wantCalled: true},
+		{name: "canceled while running", cancelDuring: true, wantCode: ExecResponseAbortedCode, wantCalled: true},
+		{name: "received after cancel", cancelBefore: true, wantCode: ExecResponseAbortedCode},
+		{name: "base context canceled", baseCanceled: true, wantCode: ExecResponseAbortedCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			called := make(chan struct{}, 1)
+			e := &Execution{BaseContext: base}
+			e.ExecuterExecResponseContext = func(ctx context.Context, ent models.Entry) *models.ExecutionResponse {
+				called <- struct{}{}
+				if tt.cancelDuring {
+					e.CancelExecResponses()
+				}
+
+				select {
+				case <-ctx.Done():
+					return models.NewExecutionResponse(ent.ID, -1, "Executor noticed the cancellation")
+				case <-time.After(50 * time.Millisecond):
+					return models.NewExecutionResponse(ent.ID, 0, "Done")
+				}
+			}
+
+			if tt.baseCanceled {
+				cancel()
+			}
+			if tt.cancelBefore {
+				// The first exec response was already finished
+				e.getExecResponseContext()
+				e.CancelExecResponses()
+			}
+
+			resp := e.ExecuteExecResponse(newTestEntry(1, time.Now()))
+			if resp == nil || resp.Code != tt.wantCode {
+				t.Errorf("ExecuteExecResponse() = %+v, want the code %d", resp, tt.wantCode)
+			}
+			if wasCalled := len(called) == 1; wasCalled != tt.wantCalled {
+				t.Errorf("Executor called = %t, want %t", wasCalled, tt.wantCalled)
+			}
+		})
+	}
+}
+
+// scheduledEntry describes an entry for the selection of the next entry.
+// The dates are relative to the current time. A zero execution offset means no execution time
+type scheduledEntry struct {
+	id            int
+	dateTime      time.Duration
+	execution     time.Duration
+	executed      bool
+	executeAlways bool
+}
+
+func (s scheduledEntry) entry(now time.Time) *models.Entry {
+	ent := newTestEntry(s.id, now.Add(s.dateTime))
+	ent.Attribute.ExecuteAlways = s.executeAlways
+	if s.execution != 0 {
+		ent.DateTimeExecution = models.DateTime{Time: now.Add(s.execution)}
+	}
+	ent.SetExecuted(s.executed)
+
+	return ent
+}
+
+func TestSelectNextEntry(t *testing.T) {
+	tests := []struct {
+		name                string
+		entries             []scheduledEntry
+		ignoreExecutionTime bool
+		gracePeriod         time.Duration
+
+		wantNext   int
+		wantPast   []int
+		wantGraced int
+	}{
+		{name: "no entries"},
+		{
+			name:     "earliest date",
+			entries:  []scheduledEntry{{id: 1, dateTime: 2 * time.Minute}, {id: 2, dateTime: time.Minute}},
+			wantNext: 2,
+		},
+		{
+			name:     "execution time before the date",
+			entries:  []scheduledEntry{{id: 1, dateTime: 10 * time.Minute, execution: time.Minute}, {id: 2, dateTime: 5 * time.Minute}},
+			wantNext: 1,
+		},
+		{
+			name:     "execution time before the date in reversed order",
+			entries:  []scheduledEntry{{id: 2, dateTime: 5 * time.Minute}, {id: 1, dateTime: 10 * time.Minute, execution: time.Minute}},
+			wantNext: 1,
+		},
+		{
+			name:     "date before the execution time",
+			entries:  []scheduledEntry{{id: 1, dateTime: time.Minute, execution: 10 * time.Minute}, {id: 2, dateTime: 5 * time.Minute, execution: 6 * time.Minute}},
+			wantNext: 1,
+		},
+		{
+			name:                "execution time ignored",
+			entries:             []scheduledEntry{{id: 1, dateTime: 10 * time.Minute, execution: time.Minute}, {id: 2, dateTime: 5 * time.Minute}},
+			ignoreExecutionTime: true,
+			wantNext:            2,
+		},
+		{
+			name:     "executed entry waiting for its date after the next execution",
+			entries:  []scheduledEntry{{id: 1, dateTime: 10 * time.Minute, execution: -time.Minute, executed: true}, {id: 2, dateTime: 5 * time.Minute}},
+			wantNext: 2,
+		},
+		{
+			name:     "executed entry waiting for its date before the next execution",
+			entries:  []scheduledEntry{{id: 1, dateTime: 10 * time.Minute, execution: -time.Minute, executed: true}, {id: 2, dateTime: 15 * time.Minute}},
+			wantNext: 1,
+		},
+		{
+			name:     "past entries",
+			entries:  []scheduledEntry{{id: 1, dateTime: -time.Minute}, {id: 2, dateTime: time.Minute}, {id: 3, dateTime: -time.Hour, execution: -time.Minute}},
+			wantNext: 2,
+			wantPast: []int{1, 3},
+		},
+		{
+			name:     "past entry with a later execution time",
+			entries:  []scheduledEntry{{id: 1, dateTime: -time.Minute, execution: time.Minute}},
+			wantNext: 1,
+		},
+		{
+			name:                "past entry with an ignored execution time",
+			entries:             []scheduledEntry{{id: 1, dateTime: -time.Minute, execution: time.Minute}},
+			ignoreExecutionTime: true,
+			wantPast:            []int{1},
+		},
+		{
+			name:     "past entry with execute always",
+			entries:  []scheduledEntry{{id: 1, dateTime: -time.Hour, executeAlways: true}, {id: 2, dateTime: time.Minute, executeAlways: true}},
+			wantNext: 2,
+			wantPast: []int{1},
+		},
+		{
+			name:        "executed entry within the grace period",
+			entries:     []scheduledEntry{{id: 1, dateTime: -time.Second, executed: true}, {id: 2, dateTime: -2 * time.Second, executed: true}, {id: 3, dateTime: time.Minute}},
+			gracePeriod: time.Minute,
+			wantNext:    3,
+			wantGraced:  2,
+		},
+		{
+			name:        "not executed entry within the grace period",
+			entries:     []scheduledEntry{{id: 1, dateTime: -time.Second}},
+			gracePeriod: time.Minute,
+			wantPast:    []int{1},
+		},
+		{
+			name:        "executed entry after the grace period",
+			entries:     []scheduledEntry{{id: 1, dateTime: -2 * time.Minute, executed: true}},
+			gracePeriod: time.Minute,
+			wantPast:    []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			entries := make([]*models.Entry, len(tt.entries))
+			for i, s := range tt.entries {
+				entries[i] = s.entry(now)
+			}
+
+			next, past, graced := SelectNextEntry(entries, tt.ignoreExecutionTime, tt.gracePeriod)
+			if id := entryID(next); id != tt.wantNext {
+				t.Errorf("SelectNextEntry() next = #%d, want #%d", id, tt.wantNext)
+			}
+			if id := entryID(graced); id != tt.wantGraced {
+				t.Errorf("SelectNextEntry() graced = #%d, want #%d", id, tt.wantGraced)
+			}
+			if len(past) != len(tt.wantPast) {
+				t.Fatalf("SelectNextEntry() past = %v, want %v", past, tt.wantPast)
+			}
+			for i := range past {
+				if past[i] != tt.wantPast[i] {
+					t.Errorf("SelectNextEntry() past = %v, want %v", past, tt.wantPast)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleRemovalGracePeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []scheduledEntry
+		gracePeriod time.Duration
+
+		wantNext int
+		// Time on which the timer fires relative to now
+		wantAt time.Duration
+	}{
+		{
+			// The entry is removed immediately. The scheduling is triggered again by the update
+			name:    "without grace period",
+			entries: []scheduledEntry{{id: 1, dateTime: -time.Second, executed: true}, {id: 2, dateTime: time.Hour}},
+		},
+		{
+			name:        "removal before the next execution",
+			entries:     []scheduledEntry{{id: 1, dateTime: -time.Second, executed: true}, {id: 2, dateTime: time.Hour}},
+			gracePeriod: time.Minute,
+			wantNext:    1,
+			wantAt:      time.Minute - time.Second,
+		},
+		{
+			name:        "removal after the next execution",
+			entries:     []scheduledEntry{{id: 1, dateTime: -time.Second, executed: true}, {id: 2, dateTime: 10 * time.Second}},
+			gracePeriod: time.Minute,
+			wantNext:    2,
+			wantAt:      10 * time.Second,
+		},
+		{
+			name:        "only removal",
+			entries:     []scheduledEntry{{id: 1, dateTime: -time.Second, executed: true}},
+			gracePeriod: time.Minute,
+			wantNext:    1,
+			wantAt:      time.Minute - time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			entries := make([]*models.Entry, len(tt.entries))
+			for i, s := range tt.entries {
+				entries[i] = s.entry(now)
+			}
+			e, _ := newTestExecution(entries...)
+			e.RemovalGracePeriod = tt.gracePeriod
+
+			e.schedule()
+
+			next, at := e.NextEntry()
+			if id := entryID(next); id != tt.wantNext {
+				t.Errorf("NextEntry() = #%d, want #%d", id, tt.wantNext)
+			}
+			if want := now.Add(tt.wantAt); tt.wantNext != 0 && !at.Equal(want) {
+				t.Errorf("NextEntry() fires at %s, want %s", at, want)
+			}
+		})
+	}
+}
+
+// entryID returns the ID of the given entry or zero for nil
+func entryID(ent *models.Entry) int {
+	if ent == nil {
+		return 0
+	}
+	return ent.ID
+}
+
+func TestExecResponseTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        models.NullInt
+		defaultTimeout int
+		want           time.Duration
+	}{
+		{name: "no timeout", want: maxExecResponseTimeout},
+		{name: "timeout of the entry", timeout: models.NullInt{Int32: 5, Valid: true}, defaultTimeout: 10, want: 5 * time.Second},
+		{name: "default timeout of the attribute", defaultTimeout: 10, want: 10 * time.Second},
+		{name: "timeout exceeding the maximum", timeout: models.NullInt{Int32: 120, Valid: true}, want: maxExecResponseTimeout},
+		{name: "zero timeout", timeout: models.NullInt{Valid: true}, defaultTimeout: 10, want: maxExecResponseTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ent := &models.Entry{Timeout: tt.timeout, Attribute: &models.Attribute{ExecResponse: models.AttributeExecResponse{DefaultTimeout: tt.defaultTimeout}}}
+			if got := ExecResponseTimeout(ent); got != tt.want {
+				t.Errorf("ExecResponseTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// entryApi is an [api.Apiler] that only returns the entries of the given execution
+// for "GetEntry()". An API error is returned for entries that are not present
+type entryApi struct {
+	api.Apiler
+	e *Execution
+}
+
+func (a entryApi) GetEntry(id int) (*models.Entry, *models.ErrorResponse) {
+	a.e.persEntry.mux.RLock()
+	defer a.e.persEntry.mux.RUnlock()
+
+	for _, ent := range a.e.persEntry.data {
+		if ent.ID == id {
+			return ent, nil
+		}
+	}
+
+	return nil, &models.ErrorResponse{ResponseCode: 404, Message: "not found"}
+}
+
+func TestCheckStuckTimer(t *testing.T) {
+	tests := []struct {
+		name string
+		// Time on which the timer should have fired relative to now. Zero if nothing is scheduled
+		scheduled time.Duration
+
+		wantReschedule bool
+	}{
+		{name: "nothing scheduled"},
+		{name: "scheduled in the future", scheduled: time.Minute},
+		{name: "recently exceeded", scheduled: -time.Second},
+		{name: "stuck", scheduled: -2 * executionWatchdogInterval, wantReschedule: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := time.Now().Add(time.Hour)
+			e, _ := newTestExecution(newTestEntry(2, next))
+			e.nextEntry.Store(1)
+			if tt.scheduled != 0 {
+				e.nextExecution.Store(time.Now().Add(tt.scheduled).UnixNano())
+			}
+
+			e.checkStuckTimer()
+
+			if rescheduled := e.nextEntry.Load() == 2; rescheduled != tt.wantReschedule {
+				t.Errorf("Rescheduled = %t, want %t", rescheduled, tt.wantReschedule)
+			}
+			if tt.wantReschedule && e.nextExecution.Load() != next.UnixNano() {
+				t.Errorf("Next execution = %s, want %s", time.Unix(0, e.nextExecution.Load()), next)
+			}
+		})
+	}
+}
+
+func TestHandleExecutionReschedules(t *testing.T) {
+	tests := []struct {
+		name string
+		// ID of the entry for which the timer fired
+		fired int64
+	}{
+		{name: "unknown next entry", fired: 0},
+		{name: "api error for the next entry", fired: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := time.Now().Add(time.Hour)
+			e, executed := newTestExecution(newTestEntry(2, next))
+			e.Api = entryApi{e: e}
+			e.nextEntry.Store(tt.fired)
+
+			e.handleExecution()
+
+			if id, at := e.NextEntry(); entryID(id) != 2 || !at.Equal(next) {
+				t.Errorf("NextEntry() = (#%d, %s), want (#2, %s)", entryID(id), at, next)
+			}
+			select {
+			case id := <-executed:
+				t.Errorf("Entry #%d was executed", id)
+			default:
+			}
+		})
+	}
+}
+
+func TestExecuteAfterNextEntryVanished(t *testing.T) {
+	tests := []struct {
+		name string
+		// IDs of the entries that are removed after the scheduling without an update
+		removed []int
+		want    []int
+	}{
+		{name: "next entry removed", removed: []int{1}, want: []int{2}},
+		{name: "next entries removed", removed: []int{1, 2}, want: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			// Entries are executed up to half a second early (see [models.Entry.ShouldExecuteNow])
+			e, executed := newTestExecution(
+				newTestEntry(1, now.Add(time.Second)),
+				newTestEntry(2, now.Add(1600*time.Millisecond)),
+				newTestEntry(3, now.Add(2200*time.Millisecond)),
+			)
+			e.Api = entryApi{e: e}
+			e.BaseContext = context.Background()
+
+			e.StartScheduling()
+			defer e.StopScheduling()
+
+			// The entry is deleted between the scheduling and the firing of the timer
+			e.persEntry.mux.Lock()
+			for _, id := range tt.removed {
+				for i, ent := range e.persEntry.data {
+					if ent.ID == id {
+						e.persEntry.data = append(e.persEntry.data[:i], e.persEntry.data[i+1:]...)
+						break
+					}
+				}
+			}
+			e.persEntry.mux.Unlock()
+
+			for _, want := range tt.want {
+				select {
+				case id := <-executed:
+					if id != want {
+						t.Errorf("Executed entry #%d, want #%d", id, want)
+					}
+				case <-time.After(3 * time.Second):
+					t.Fatalf("Entry #%d was not executed", want)
+				}
+			}
+		})
+	}
+}
+
+func TestStartSchedulingSingleScheduler(t *testing.T) {
+	tests := []struct {
+		name       string
+		starts     int
+		concurrent bool
+	}{
+		{name: "single start", starts: 1},
+		{name: "repeated starts", starts: 10},
+		{name: "concurrent starts", starts: 10, concurrent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExecution(newTestEntry(1, time.Now().Add(time.Hour)))
+			e.BaseContext = context.Background()
+			before := runtime.NumGoroutine()
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.starts; i++ {
+				if tt.concurrent {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						e.StartScheduling()
+					}()
+				} else {
+					e.StartScheduling()
+				}
+			}
+			wg.Wait()
+
+			// Exactly one scheduler goroutine is running
+			if got := waitForGoroutines(before + 1); got != before+1 {
+				t.Errorf("%d scheduler goroutines are running, want 1", got-before)
+			}
+
+			e.StopScheduling()
+			if got := waitForGoroutines(before); got != before {
+				t.Errorf("%d scheduler goroutines are running after the stop, want 0", got-before)
+			}
+		})
+	}
+}
+
+// waitForGoroutines waits until the given number of goroutines is running, so that
+// goroutines of previous tests that are still exiting are ignored.
+// The number of running goroutines is returned
+func waitForGoroutines(want int) int {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() != want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return runtime.NumGoroutine()
+}
+
+// markingApi is an [api.Apiler] that sends the IDs of the entries marked as executed to the channel
+type markingApi struct {
+	api.Apiler
+	marked chan int
+}
+
+func (a markingApi) MarkEntryAsExecuted(id int) *models.ErrorResponse {
+	a.marked <- id
+	return nil
+}
+
+// receiveIDs receives IDs from the channel until no further ID was received within
+// a short time. The IDs are returned sorted
+func receiveIDs(c chan int) []int {
+	var rtc []int
+	for {
+		select {
+		case id := <-c:
+			rtc = append(rtc, id)
+		case <-time.After(200 * time.Millisecond):
+			sort.Ints(rtc)
+			return rtc
+		}
+	}
+}
+
+func TestExecuteDueMaxCatchUpAge(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxCatchUpAge time.Duration
+		entries       []scheduledEntry
+
+		wantExecuted []int
+		wantMarked   []int
+	}{
+		{
+			name:         "without maximum age",
+			entries:      []scheduledEntry{{id: 1, dateTime: -2 * time.Hour, executeAlways: true}, {id: 2, dateTime: -time.Minute, executeAlways: true}},
+			wantExecuted: []int{1, 2},
+			wantMarked:   []int{1, 2},
+		},
+		{
+			name:          "stale entries",
+			maxCatchUpAge: 30 * time.Minute,
+			entries: []scheduledEntry{
+				{id: 1, dateTime: -2 * time.Hour, executeAlways: true},
+				{id: 2, dateTime: -time.Hour, executeAlways: true},
+				{id: 3, dateTime: -time.Minute, executeAlways: true},
+			},
+			wantExecuted: []int{3},
+			wantMarked:   []int{1, 2, 3},
+		},
+		{
+			name:          "stale execution time",
+			maxCatchUpAge: 30 * time.Minute,
+			entries:       []scheduledEntry{{id: 1, dateTime: time.Hour, execution: -time.Hour, executeAlways: true}},
+			wantMarked:    []int{1},
+		},
+		{
+			name:          "entries without execute always",
+			maxCatchUpAge: 30 * time.Minute,
+			entries:       []scheduledEntry{{id: 1, dateTime: -time.Hour}, {id: 2, dateTime: -time.Second}},
+			wantExecuted:  []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			entries := make([]*models.Entry, len(tt.entries))
+			for i, s := range tt.entries {
+				entries[i] = s.entry(now)
+			}
+			e, executed := newTestExecution(entries...)
+			marked := make(chan int, len(entries))
+			e.Api = markingApi{marked: marked}
+			e.MaxCatchUpAge = tt.maxCatchUpAge
+
+			// Scheduling the entries on startup
+			e.schedule()
+
+			if got := receiveIDs(executed); fmt.Sprint(got) != fmt.Sprint(tt.wantExecuted) {
+				t.Errorf("Executed entries %v, want %v", got, tt.wantExecuted)
+			}
+			if got := receiveIDs(marked); fmt.Sprint(got) != fmt.Sprint(tt.wantMarked) {
+				t.Errorf("Marked entries %v as executed, want %v", got, tt.wantMarked)
+			}
+		})
+	}
+}
+
+func TestRequestScheduleDebounce(t *testing.T) {
+	tests := []struct {
+		name     string
+		debounce time.Duration
+
+		// Whether the latest date is scheduled immediately after the flood of requests
+		wantImmediate bool
+	}{
+		{name: "debounced", debounce: 100 * time.Millisecond},
+		{name: "default debounce"},
+		{name: "disabled", debounce: -1, wantImmediate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now().Add(time.Hour)
+			ent := newTestEntry(1, start)
+			e, _ := newTestExecution(ent)
+			e.RescheduleDebounce = tt.debounce
+			e.schedule()
+
+			// Flood the scheduler with updates of the date
+			var latest time.Time
+			for i := 1; i <= 100; i++ {
+				latest = start.Add(time.Duration(i) * time.Minute)
+				e.persEntry.mux.Lock()
+				ent.DateTime = models.DateTime{Time: latest}
+				e.persEntry.mux.Unlock()
+				e.requestSchedule()
+			}
+
+			if _, at := e.NextEntry(); at.Equal(latest) != tt.wantImmediate {
+				t.Errorf("NextEntry() fires at %s directly after the updates, want the latest date: %t", at, tt.wantImmediate)
+			}
+
+			// The collapsed reschedule reflects the latest state
+			time.Sleep(200 * time.Millisecond)
+			if _, at := e.NextEntry(); !at.Equal(latest) {
+				t.Errorf("NextEntry() fires at %s, want %s", at, latest)
+			}
+			if e.reschedulePending.Load() {
+				t.Errorf("A reschedule is still pending")
+			}
+		})
+	}
+}
+
+func TestExecuteIfDue(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry scheduledEntry
+
+		want bool
+	}{
+		{name: "due", entry: scheduledEntry{id: 1}, want: true},
+		{name: "due execution time", entry: scheduledEntry{id: 1, dateTime: time.Hour, execution: -time.Second}, want: true},
+		{name: "not due", entry: scheduledEntry{id: 1, dateTime: time.Hour}},
+		{name: "already executed", entry: scheduledEntry{id: 1, executed: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ent := tt.entry.entry(time.Now())
+			e, executed := newTestExecution(ent)
+
+			if got := e.ExecuteIfDue(ent); got != tt.want {
+				t.Errorf("ExecuteIfDue() = %t, want %t", got, tt.want)
+			}
+
+			// The executor was called synchronously
+			select {
+			case <-executed:
+				if !tt.want {
+					t.Errorf("Executor was called")
+				}
+			default:
+				if tt.want {
+					t.Errorf("Executor was not called before returning")
+				}
+			}
+
+			// The entry is executed only once
+			if tt.want && (e.ExecuteIfDue(ent) || !ent.WasExecuted()) {
+				t.Errorf("Entry was not marked as executed")
+			}
+		})
+	}
+}
+
+func TestScheduleOn(t *testing.T) {
+	tests := []struct {
+		name                string
+		scheduleOn          ScheduleOn
+		ignoreExecutionTime bool
+
+		wantExecuteOnDateTime   bool
+		wantIgnoreExecutionTime bool
+		// Time on which an entry with an earlier execution time fires relative to now
+		wantAt time.Duration
+		// Whether an entry whose date time is due and whose execution time is in the future
+		// is executed and kept in the list
+		wantExecuted bool
+		wantKept     bool
+	}{
+		{name: "auto", scheduleOn: ScheduleOnAuto, wantAt: time.Hour, wantKept: true},
+		{name: "auto ignoring the execution time", scheduleOn: ScheduleOnAuto, ignoreExecutionTime: true, wantIgnoreExecutionTime: true, wantAt: time.Hour},
+		{name: "date time", scheduleOn: ScheduleOnDateTime, wantExecuteOnDateTime: true, wantIgnoreExecutionTime: true, wantAt: 2 * time.Hour, wantExecuted: true},
+		{name: "date time ignoring the execution time", scheduleOn: ScheduleOnDateTime, ignoreExecutionTime: true, wantExecuteOnDateTime: true, wantIgnoreExecutionTime: true, wantAt: 2 * time.Hour, wantExecuted: true},
+		{name: "execution time", scheduleOn: ScheduleOnExecutionTime, wantAt: time.Hour, wantKept: true},
+		{name: "execution time ignoring the execution time", scheduleOn: ScheduleOnExecutionTime, ignoreExecutionTime: true, wantAt: time.Hour, wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			e, _ := newTestExecution(scheduledEntry{id: 1, dateTime: 2 * time.Hour, execution: time.Hour}.entry(now))
+			e.ScheduleOn = tt.scheduleOn
+			e.IgnoreExecutionTime = tt.ignoreExecutionTime
+
+			if got := e.executeOnDateTime(); got != tt.wantExecuteOnDateTime {
+				t.Errorf("executeOnDateTime() = %t, want %t", got, tt.wantExecuteOnDateTime)
+			}
+			if got := e.ignoreExecutionTime(); got != tt.wantIgnoreExecutionTime {
+				t.Errorf("ignoreExecutionTime() = %t, want %t", got, tt.wantIgnoreExecutionTime)
+			}
+
+			e.schedule()
+			if next, at := e.NextEntry(); entryID(next) != 1 || !at.Equal(now.Add(tt.wantAt)) {
+				t.Errorf("NextEntry() = (#%d, %s), want (#1, %s)", entryID(next), at, now.Add(tt.wantAt))
+			}
+
+			// The date time is due while the execution time is still in the future
+			e, executed := newTestExecution(scheduledEntry{id: 2, dateTime: -time.Second, execution: time.Hour}.entry(now))
+			e.ScheduleOn = tt.scheduleOn
+			e.IgnoreExecutionTime = tt.ignoreExecutionTime
+
+			e.schedule()
+			if got := receiveIDs(executed); (len(got) == 1) != tt.wantExecuted {
+				t.Errorf("Executed entries = %v, want the entry executed: %t", got, tt.wantExecuted)
+			}
+			if next, _ := e.NextEntry(); (entryID(next) == 2) != tt.wantKept {
+				t.Errorf("NextEntry() = #%d, want the entry kept: %t", entryID(next), tt.wantKept)
+			}
+		})
+	}
+}
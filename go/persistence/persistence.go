@@ -11,8 +11,9 @@ import (
 	"sync"
 	"time"
 
-	"github.com/RPJoshL/RPdb/v4/go/api"
 	"git.rpjosh.de/RPJosh/go-logger"
+	"github.com/RPJoshL/RPdb/v4/go/api"
+	"github.com/RPJoshL/RPdb/v4/go/models"
 )
 
 // Persistence is a wrapper around the API interface with additional
@@ -38,8 +39,21 @@ type Persistence struct {
 	// Information to handle an update of the locally cached data
 	Update *PersistenceUpdate
 
-	// Base context for all operations
+	// Cache for the results of server side filtered queries
+	queryCache queryCache
+
+	// Base context for all operations. It's canceled by "Close()"
 	context context.Context
+	cancel  context.CancelFunc
+
+	// Observer of the updates used for the scheduling of the executions
+	executionObserver chan models.Update
+
+	// Ensures that the persistence layer is only closed once
+	closeOnce sync.Once
+
+	// Ensures that "BeforeInitialUpdateRequest" is only called after the first load
+	initialLoad sync.Once
 }
 
 // PersistenceOptions contains options for various modules of the persistence layer
@@ -49,9 +63,69 @@ type PersistenceOptions struct {
 
 	Exeuction Execution
 
-	// Function to call before triggering an update after a full reload of the
-	// data (or after the initial trough of the [Start] function)
+	// Function to call before triggering the update after the first successful load of
+	// the data (within [Persistence.Start]). It's not called again for later reloads like
+	// a resync or a resume from a suspend
 	BeforeInitialUpdateRequest func(p *Persistence)
+
+	// Whether the attributes of entries should not be expanded by the server because
+	// they are linked to the locally cached attributes. This saves a lot of bandwidth.
+	// When set to false, the server will return the full attribute for every entry.
+	// Defaulting to true (if nil)
+	CacheAttributesLocally *bool
+
+	// Retry the initial loading of the data within [Persistence.Start] with an increasing
+	// waiting time (see [GetReconnectTimeout]) until it succeeds or the base context is canceled
+	RetryInitialLoad bool
+
+	// Maximum number of attempts for "RetryInitialLoad". Zero means unlimited
+	RetryInitialLoadMaxAttempts int
+
+	// Maximum duration in which failed loads are retried with "RetryInitialLoad".
+	// No further attempt is made if it would start after this duration. Zero means unlimited
+	RetryInitialLoadMaxDuration time.Duration
+
+	// Duration for which executed entries are kept in the local cache after all
+	// of their date fields are past. Observers and UIs can still read the just executed
+	// entry within this time. The entry is not executed again.
+	// By default, the entries are removed immediately
+	RemovalGracePeriod time.Duration
+
+	// Function to call after the server requested a full reload of the data
+	// because the local version was too old (e.g. after a long disconnect).
+	// The data was already reloaded when this function is called. The error
+	// of the reload is passed (if any).
+	// See [CloseCodeResyncRequired] and [models.WebSocketTypeResync]
+	OnResyncRequired func(p *Persistence, err error)
+
+	// Duration for which the results of identical "GetEntries()" calls, that cannot be filtered
+	// locally, are cached. This reduces the API calls for bursty identical queries (e.g. polling).
+	// The cache is invalidated on every update of the data. Changes made without notifying the
+	// observers (e.g. [Persistence.CreateEntryWithoutCaching]) are only visible after the duration.
+	// By default, the results are not cached
+	QueryCacheTTL time.Duration
+
+	// Minimum duration for which the system has to be suspended (e.g. a sleeping laptop) to
+	// reload all data after the resume. While suspended, updates could have been missed.
+	// Defaulting to one minute
+	SuspendThreshold time.Duration
+
+	// Maximum age of past entries with the flag "execute always" that are still executed when they are due
+	// (e.g. after a restart of the service following a longer downtime). Older entries are marked as
+	// executed without running them, to prevent a flood of catch-up executions.
+	// By default, all past entries are executed
+	MaxCatchUpAge time.Duration
+
+	// Execute an entry created with [Persistence.CreateEntry] synchronously if it should be executed now.
+	// "CreateEntry()" returns after the executor returned. This is useful for tests or request / response
+	// flows. An entry is still only executed once
+	ExecuteOnCreate bool
+}
+
+// cacheAttributesLocally returns the value of "CacheAttributesLocally" or
+// the default value if it wasn't set
+func (o *PersistenceOptions) cacheAttributesLocally() bool {
+	return o.CacheAttributesLocally == nil || *o.CacheAttributesLocally
 }
 
 // NewPersistence creates a new persistence layer based on the given API.
@@ -62,35 +136,44 @@ func NewPersistence(apiKey string, apiOptions api.ApiOptions, persistenceOptions
 
 // NewPersistenceWithContext creates a new persistence layout based on the given API.
 // To finish the creation you have to call "Start()".
-func NewPersistenceWithContext(context context.Context, apiKey string, apiOptions api.ApiOptions, persistenceOptions *PersistenceOptions) *Persistence {
+func NewPersistenceWithContext(parent context.Context, apiKey string, apiOptions api.ApiOptions, persistenceOptions *PersistenceOptions) *Persistence {
 	// Don't resolve attributes because they are cached locally
-	apiOptions.TreatAsJavaClient = true
+	apiOptions.TreatAsJavaClient = persistenceOptions.cacheAttributesLocally()
 
+	context, cancel := context.WithCancel(parent)
 	pers := &Persistence{
-		Api:     *api.NewApiWithContext(context, apiKey, apiOptions),
+		Api:     *api.NewApiWithContext(parent, apiKey, apiOptions),
 		Options: persistenceOptions,
 		Update:  &PersistenceUpdate{},
 		context: context,
+		cancel:  cancel,
+
+		queryCache: queryCache{ttl: persistenceOptions.QueryCacheTTL},
 	}
 
 	// Set default values for persistence options
 	pers.Options.WebSocket.ApiKey = apiKey
 	pers.Options.WebSocket.BaseContext = context
 	pers.Options.WebSocket.OnMessage = pers.handleWebSocketMessage
+	pers.Options.WebSocket.OnResyncRequired = pers.resync
 	pers.Options.WebSocket.Update = pers.Update
 	if pers.Options.WebSocket.SocketURL == "" {
 		pers.Options.WebSocket.SocketURL = "wss://rpdb.rpjosh.de/api/v1/socket"
 	}
 
 	// Create persistence data layout for every entity
-	pers.entry = persistenceEntry{api: pers}
-	pers.attribute = persistenceAttribute{api: pers}
+	pers.attribute = persistenceAttribute{api: pers, lean: apiOptions.LeanAttributes, expanded: make(map[int]bool), relink: pers.RelinkEntries}
+	pers.entry = persistenceEntry{api: pers, attributes: &pers.attribute, cacheAttributes: persistenceOptions.cacheAttributesLocally()}
 
 	// Initialize executor
 	pers.Options.Exeuction.BaseContext = context
 	pers.Options.Exeuction.Api = pers
 	pers.Options.Exeuction.Update = pers.Update
 	pers.Options.Exeuction.persEntry = &pers.entry
+	pers.Options.Exeuction.RemovalGracePeriod = persistenceOptions.RemovalGracePeriod
+	pers.Options.Exeuction.SuspendThreshold = persistenceOptions.SuspendThreshold
+	pers.Options.Exeuction.MaxCatchUpAge = persistenceOptions.MaxCatchUpAge
+	pers.Options.Exeuction.OnResume = pers.resume
 
 	return pers
 }
@@ -104,19 +187,23 @@ func NewPersistenceWithContext(context context.Context, apiKey string, apiOption
 func (p *Persistence) Start() error {
 
 	// Try to laod the data
-	loadError := p.ReloadData()
-	if loadError != nil {
-		return loadError
+	if err := p.loadInitialData(); err != nil {
+		return err
 	}
 
 	// Start the executor listen for updates
 	p.Options.Exeuction.StartScheduling()
 	executionUpdateChannel := p.Update.RegisterObserver()
+	p.executionObserver = executionUpdateChannel
 	go func() {
 		for {
 			select {
-			case <-executionUpdateChannel:
-				p.Options.Exeuction.schedule()
+			case _, ok := <-executionUpdateChannel:
+				if !ok {
+					// The observer was removed by "Close()"
+					return
+				}
+				p.Options.Exeuction.requestSchedule()
 			case <-p.context.Done():
 				logger.Debug("Aborted to listen for updates (execution)")
 				return
@@ -130,6 +217,175 @@ func (p *Persistence) Start() error {
 	return nil
 }
 
+// loadInitialData loads the data for the start of the persistence layer. With "RetryInitialLoad"
+// a failed load is retried with an increasing waiting time until the configured maximum number
+// of attempts or retry duration is reached.
+// The waiting is aborted immediately when the base context is canceled
+func (p *Persistence) loadInitialData() error {
+	opts := p.Options
+	startTime := time.Now()
+
+	for attempt := int32(1); ; attempt++ {
+		err := p.ReloadData()
+		if err == nil {
+			return nil
+		} else if !opts.RetryInitialLoad {
+			return err
+		}
+
+		// Validate that the limits are not exceeded yet
+		waitTime := GetReconnectTimeout(attempt)
+		if opts.RetryInitialLoadMaxAttempts > 0 && int(attempt) >= opts.RetryInitialLoadMaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %s", attempt, err)
+		} else if opts.RetryInitialLoadMaxDuration > 0 && time.Since(startTime)+waitTime > opts.RetryInitialLoadMaxDuration {
+			return fmt.Errorf("giving up after %.0f seconds: %s", time.Since(startTime).Seconds(), err)
+		}
+
+		if opts.RetryInitialLoadMaxAttempts > 0 {
+			logger.Warning("Failed to load the data: %s. Retrying in %.0f seconds (%d attempts remaining)", err, waitTime.Seconds(), opts.RetryInitialLoadMaxAttempts-int(attempt))
+		} else {
+			logger.Warning("Failed to load the data: %s. Retrying in %.0f seconds", err, waitTime.Seconds())
+		}
+
+		select {
+		case <-time.After(waitTime):
+		case <-p.context.Done():
+			return fmt.Errorf("aborted while waiting for the next attempt: %s", err)
+		}
+	}
+}
+
+// Close shuts down the persistence layer. The WebSocket is closed with the code 1000,
+// the scheduling of the executions is stopped and the internal observers are removed.
+// It returns after the scheduler exited. Running executions are not aborted (see
+// [Execution.CancelExecResponses]).
+// The requests of the API are still possible afterwards. Calling this multiple times is safe
+func (p *Persistence) Close() (err error) {
+	p.closeOnce.Do(func() {
+		err = p.Options.WebSocket.CloseWithMessage(uint16(1000), "Close")
+
+		// Prevents the reconnect of the WebSocket and stops the scheduler
+		p.cancel()
+
+		if p.executionObserver != nil {
+			p.Update.RemoveObserver(p.executionObserver)
+		}
+		p.Options.Exeuction.StopScheduling()
+	})
+
+	return err
+}
+
+// ReconnectWebSocket closes the current WebSocket connection and immediately tries to
+// establish a new one (see [WebSocket.Reconnect]).
+// Updates that were missed while being disconnected are received during the handshake
+func (p *Persistence) ReconnectWebSocket() {
+	p.Options.WebSocket.Reconnect()
+}
+
+// Done returns a channel that is closed when the base context of the
+// persistence layer was canceled or [Persistence.Close] was called. After that, no further updates are received
+// and no entries are executed
+func (p *Persistence) Done() <-chan struct{} {
+	return p.context.Done()
+}
+
+// Err returns nil as long as [Persistence.Done] is not closed. Afterwards, the
+// reason of the cancellation of the base context is returned
+func (p *Persistence) Err() error {
+	return p.context.Err()
+}
+
+// Status is a summary of the current state of the persistence layer
+type Status struct {
+	// Number of locally cached entries and attributes
+	Entries    int
+	Attributes int
+
+	// The entry that is scheduled next and the time the scheduling timer fires.
+	// The entry is nil if nothing is scheduled
+	NextEntry     *models.Entry
+	NextExecution time.Time
+
+	// Whether a WebSocket connection should be used and is currently established
+	WebSocketEnabled   bool
+	WebSocketConnected bool
+
+	// The current version of the data
+	Version int
+}
+
+func (s Status) String() string {
+	next := "none"
+	if s.NextEntry != nil {
+		next = fmt.Sprintf("#%d at %s", s.NextEntry.ID, s.NextExecution.Format(models.TimeFormatPretty))
+	}
+
+	websocket := "disabled"
+	if s.WebSocketEnabled && s.WebSocketConnected {
+		websocket = "connected"
+	} else if s.WebSocketEnabled {
+		websocket = "disconnected"
+	}
+
+	return fmt.Sprintf("Entries: %d, attributes: %d, next entry: %s, WebSocket: %s, version: %d", s.Entries, s.Attributes, next, websocket, s.Version)
+}
+
+// Status returns a summary of the current state like the number of cached
+// entries or the entry that is scheduled next
+func (p *Persistence) Status() Status {
+	rtc := Status{
+		WebSocketEnabled:   p.Options.WebSocket.UseWebsocket,
+		WebSocketConnected: p.Options.WebSocket.IsConnected(),
+		Version:            p.Update.GetVersion(),
+	}
+	rtc.NextEntry, rtc.NextExecution = p.Options.Exeuction.NextEntry()
+
+	p.entry.mux.RLock()
+	rtc.Entries = len(p.entry.data)
+	p.entry.mux.RUnlock()
+
+	p.attribute.mux.RLock()
+	rtc.Attributes = len(p.attribute.data)
+	p.attribute.mux.RUnlock()
+
+	return rtc
+}
+
+// Snapshot returns a consistent point-in-time view of all cached entries and
+// attributes together with the current version of the data.
+// The entries and attributes are deep copies, so it's safe to hold and inspect them
+// without any further locking. The attributes of the returned entries reference
+// the returned attributes
+func (p *Persistence) Snapshot() (entries []*models.Entry, attributes []*models.Attribute, version int) {
+	// Lock in the same order as while linking the attributes
+	p.entry.mux.RLock()
+	defer p.entry.mux.RUnlock()
+	p.attribute.mux.RLock()
+	defer p.attribute.mux.RUnlock()
+	p.Update.versionLock.RLock()
+	defer p.Update.versionLock.RUnlock()
+
+	attributes = make([]*models.Attribute, len(p.attribute.data))
+	attributesById := make(map[int]*models.Attribute, len(p.attribute.data))
+	for i, a := range p.attribute.data {
+		attributes[i] = a.Clone()
+		attributesById[a.ID] = attributes[i]
+	}
+
+	entries = make([]*models.Entry, len(p.entry.data))
+	for i, e := range p.entry.data {
+		entries[i] = e.Clone()
+		if e.Attribute != nil {
+			if attr, ok := attributesById[e.Attribute.ID]; ok {
+				entries[i].Attribute = attr
+			}
+		}
+	}
+
+	return entries, attributes, p.Update.Version
+}
+
 // ReloadData forces a full reload of the persisted
 // data.
 // Locally fetched entries with the flag 'no_db' are
@@ -173,9 +429,11 @@ func (p *Persistence) ReloadData() error {
 	p.Update.versionLock.Unlock()
 
 	// Trigger update after first load
-	if p.Options.BeforeInitialUpdateRequest != nil {
-		p.Options.BeforeInitialUpdateRequest(p)
-	}
+	p.initialLoad.Do(func() {
+		if p.Options.BeforeInitialUpdateRequest != nil {
+			p.Options.BeforeInitialUpdateRequest(p)
+		}
+	})
 	p.Update.notifyForUpdates(nil)
 
 	return nil
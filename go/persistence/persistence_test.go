@@ -0,0 +1,163 @@
+package persistence
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestSnapshot(t *testing.T) {
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	date := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		entries []*models.Entry
+
+		// Name of the linked attribute for every returned entry
+		want []string
+	}{
+		{name: "no entries"},
+		{
+			name: "linked attributes",
+			entries: []*models.Entry{
+				{ID: 1, Attribute: attributes[0], DateTime: models.DateTime{Time: date}},
+				{ID: 2, Attribute: attributes[1], DateTime: models.DateTime{Time: date.Add(time.Minute)}},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:    "unknown attribute",
+			entries: []*models.Entry{{ID: 1, Attribute: &models.Attribute{ID: 3, Name: "c"}, DateTime: models.DateTime{Time: date}}},
+			want:    []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON(nil), attributes, tt.entries...)
+			p.Update.Version = 5
+
+			entries, attrs, version := p.Snapshot()
+			if version != 5 {
+				t.Errorf("Snapshot() version = %d, want 5", version)
+			}
+			if len(attrs) != len(attributes) {
+				t.Fatalf("Snapshot() returned %d attributes, want %d", len(attrs), len(attributes))
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Snapshot() returned %d entries, want %d", len(entries), len(tt.want))
+			}
+
+			for i, e := range entries {
+				if e.Attribute.Name != tt.want[i] {
+					t.Errorf("Attribute of entry #%d = %q, want %q", e.ID, e.Attribute.Name, tt.want[i])
+				}
+				if e == tt.entries[i] || e.Attribute == tt.entries[i].Attribute {
+					t.Errorf("Entry #%d references the cached data", e.ID)
+				}
+
+				// Known attributes are shared with the returned attributes
+				for _, a := range attrs {
+					if a.ID == e.Attribute.ID && a != e.Attribute {
+						t.Errorf("Attribute of entry #%d is not linked to the returned attributes", e.ID)
+					}
+				}
+			}
+
+			// Modifying the snapshot doesn't change the cache
+			for _, a := range attrs {
+				a.Name = "modified"
+			}
+			for _, a := range p.attribute.data {
+				if a.Name == "modified" {
+					t.Errorf("Cached attribute #%d was modified", a.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotConcurrentUpdates(t *testing.T) {
+	const updates = 50
+	attributes := []*models.Attribute{{ID: 1, Name: "a"}}
+	p := newTestPersistence(t, respondJSON(nil), attributes)
+	date := time.Now().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			msg := models.WebSocketMessage{Type: models.WebSocketTypeUpdate}
+			msg.Update.Version = i
+			msg.Update.Entry.Created = []*models.Entry{{ID: i, Attribute: &models.Attribute{ID: 1}, DateTime: models.DateTime{Time: date}}}
+			msg.Update.Attribute.Updated = []*models.Attribute{{ID: 1, Name: "a"}}
+			p.handleWebSocketMessage(msg)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		lastVersion := 0
+		for i := 0; i < updates; i++ {
+			entries, attrs, version := p.Snapshot()
+			if version < lastVersion {
+				t.Errorf("Snapshot() version = %d, was already %d", version, lastVersion)
+			}
+			lastVersion = version
+
+			for _, e := range entries {
+				if len(attrs) != 1 || e.Attribute != attrs[0] {
+					t.Errorf("Attribute of entry #%d is not linked to the returned attributes", e.ID)
+				}
+				e.Note = "modified"
+			}
+		}
+	}()
+	wg.Wait()
+
+	entries, _, version := p.Snapshot()
+	if version != updates || len(entries) != updates {
+		t.Errorf("Snapshot() = %d entries with version %d, want %d", len(entries), version, updates)
+	}
+	for _, e := range p.GetEntriesAll() {
+		if e.Note != "" {
+			t.Errorf("Cached entry #%d was modified", e.ID)
+		}
+	}
+}
+
+func TestLoadInitialData(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *PersistenceOptions
+		cancel  bool
+		wantErr string
+	}{
+		{name: "without retry", options: &PersistenceOptions{}, wantErr: "failed to"},
+		{name: "max attempts", options: &PersistenceOptions{RetryInitialLoad: true, RetryInitialLoadMaxAttempts: 1}, wantErr: "giving up after 1 attempts"},
+		{name: "max duration", options: &PersistenceOptions{RetryInitialLoad: true, RetryInitialLoadMaxDuration: time.Second}, wantErr: "giving up after 0 seconds"},
+		{name: "canceled", options: &PersistenceOptions{RetryInitialLoad: true}, cancel: true, wantErr: "aborted while waiting for the next attempt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}, nil)
+			p.Options = tt.options
+			if tt.cancel {
+				time.AfterFunc(100*time.Millisecond, p.cancel)
+			}
+
+			err := p.loadInitialData()
+			if err == nil || !strings.HasPrefix(strings.ToLower(err.Error()), tt.wantErr) {
+				t.Errorf("loadInitialData() = %v, want an error starting with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// queryCache caches the results of server side filtered queries for a short time.
+// The cached results are invalidated on every update of the data
+type queryCache struct {
+	// Time for which a result is cached. Zero disables the cache
+	ttl time.Duration
+
+	data map[string]queryCacheEntry
+	mux  sync.Mutex
+}
+
+// queryCacheEntry is a single cached result of a query
+type queryCacheEntry struct {
+	entries []*models.Entry
+	created time.Time
+
+	// The number of notified updates when the query was started
+	updates uint64
+}
+
+// get returns the cached result for the given key if it's still valid
+func (c *queryCache) get(key string, updates uint64) ([]*models.Entry, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	cached, ok := c.data[key]
+	if !ok || cached.updates != updates || time.Since(cached.created) > c.ttl {
+		delete(c.data, key)
+		return nil, false
+	}
+
+	return append([]*models.Entry(nil), cached.entries...), true
+}
+
+// set caches the result of the given key. The number of updates has to be read
+// before the query was started, so that updates during the query invalidate the result
+func (c *queryCache) set(key string, updates uint64, entries []*models.Entry) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.data == nil {
+		c.data = make(map[string]queryCacheEntry)
+	}
+	c.data[key] = queryCacheEntry{
+		entries: append([]*models.Entry(nil), entries...),
+		created: time.Now(),
+		updates: updates,
+	}
+}
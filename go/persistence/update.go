@@ -1,7 +1,10 @@
 package persistence
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/RPJoshL/RPdb/v4/go/models"
@@ -19,8 +22,30 @@ type PersistenceUpdate struct {
 	versionLock sync.RWMutex
 
 	// All observers of the update chanel
-	observers    []chan models.Update
+	observers    []*updateObserver
 	observerLock sync.RWMutex
+
+	// Number of notified updates. It's used to invalidate cached results
+	notifications atomic.Uint64
+}
+
+// updateObserver is a single observer registered with [PersistenceUpdate.RegisterObserver]
+type updateObserver struct {
+	c chan models.Update
+
+	// Closed when the observer was removed so that pending notifications are aborted
+	removed chan struct{}
+
+	// Notifications that are not received yet
+	pending sync.WaitGroup
+}
+
+// GetVersion returns the current version number of the data
+func (p *PersistenceUpdate) GetVersion() int {
+	p.versionLock.RLock()
+	defer p.versionLock.RUnlock()
+
+	return p.Version
 }
 
 // handleWebSocketMessage is the entry point to processes received message from the WebSocket
@@ -70,6 +95,33 @@ func (p *Persistence) handleWebSocketMessage(msg models.WebSocketMessage) {
 
 		// Trigger update
 		p.Update.notifyForUpdates(&msg.Update)
+	} else if msg.Type == models.WebSocketTypeResync {
+		p.resync()
+	}
+}
+
+// resync reloads all data because the server signaled that the local
+// version is too old for an incremental update
+func (p *Persistence) resync() {
+	logger.Debug("Reloading data because the server requested a resync")
+
+	err := p.ReloadData()
+	if err != nil {
+		logger.Warning("Failed to reload the data after a resync request: %s", err)
+	}
+
+	if p.Options.OnResyncRequired != nil {
+		p.Options.OnResyncRequired(p, err)
+	}
+}
+
+// resume reloads the data after the system was resumed from a suspend because
+// updates could have been missed in the meantime
+func (p *Persistence) resume() {
+	logger.Info("Reloading data after the system was resumed")
+
+	if err := p.ReloadData(); err != nil {
+		logger.Warning("Failed to reload the data after a resume: %s", err)
 	}
 }
 
@@ -77,15 +129,28 @@ func (p *Persistence) handleWebSocketMessage(msg models.WebSocketMessage) {
 // The update can be nil if no update information is available
 // (initial loading of the data)
 func (p *PersistenceUpdate) notifyForUpdates(update *models.Update) {
+	p.notifications.Add(1)
+
 	p.observerLock.RLock()
 	defer p.observerLock.RUnlock()
 
+	// The update is not passed by reference that the update information
+	// cannot be modified. The data inside the update struct are still
+	// passed by reference (pointers)
+	upd := models.Update{}
+	if update != nil {
+		upd = *update
+	}
+
 	for _, obs := range p.observers {
-		go func(c chan models.Update) {
-			// The update is not passed by reference that the update information
-			// cannot be modified. The data inside the update struct are still
-			// passed by reference (pointers)
-			c <- *update
+		obs.pending.Add(1)
+		go func(o *updateObserver) {
+			defer o.pending.Done()
+
+			select {
+			case o.c <- upd:
+			case <-o.removed:
+			}
 		}(obs)
 	}
 }
@@ -99,23 +164,54 @@ func (p *PersistenceUpdate) RegisterObserver() chan models.Update {
 	p.observerLock.Lock()
 	defer p.observerLock.Unlock()
 
-	c := make(chan models.Update)
-	p.observers = append(p.observers, c)
-	return c
+	obs := &updateObserver{
+		c:       make(chan models.Update),
+		removed: make(chan struct{}),
+	}
+	p.observers = append(p.observers, obs)
+	return obs.c
 }
 
 // RemoveObserver removes the given observer from the internal observers
-// lists and closed the channel
+// lists and closed the channel.
+// Notifications that were not received yet are dropped
 func (p *PersistenceUpdate) RemoveObserver(c chan models.Update) {
 	p.observerLock.Lock()
 	defer p.observerLock.Unlock()
 
 	// Find the observer and remove it
-	for i := range p.observers {
-		if p.observers[i] == c {
+	for i, obs := range p.observers {
+		if obs.c == c {
 			p.observers = append(p.observers[:i], p.observers[i+1:]...)
-			close(c)
+
+			// The channel can only be closed after all pending notifications were aborted
+			close(obs.removed)
+			go func() {
+				obs.pending.Wait()
+				close(obs.c)
+			}()
 			break
 		}
 	}
 }
+
+// WaitForVersion blocks until the local cache reached at least the given version
+// of the data or the context is done.
+// The version is checked on every update of the data that was received. This is
+// useful to read the own changes in a multi instance setup that are only applied
+// through the WebSocket
+func (p *Persistence) WaitForVersion(ctx context.Context, version int) error {
+	// Register the observer before checking the version to not miss an update
+	c := p.Update.RegisterObserver()
+	defer p.Update.RemoveObserver(c)
+
+	for p.Update.GetVersion() < version {
+		select {
+		case <-c:
+		case <-ctx.Done():
+			return fmt.Errorf("version %d was not reached (current version %d): %w", version, p.Update.GetVersion(), ctx.Err())
+		}
+	}
+
+	return nil
+}
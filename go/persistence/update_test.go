@@ -0,0 +1,82 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// newVersionUpdate returns an update message with a created entry for the given version
+func newVersionUpdate(version int) models.WebSocketMessage {
+	msg := models.WebSocketMessage{Type: models.WebSocketTypeUpdate}
+	msg.Update.Version = version
+	msg.Update.Entry.Created = []*models.Entry{{ID: version, Attribute: &models.Attribute{ID: 1}, DateTime: models.DateTime{Time: time.Now().Add(time.Hour)}}}
+
+	return msg
+}
+
+func TestWaitForVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		version int
+		// Versions of the updates that are received while waiting
+		updates []int
+
+		wantErr error
+	}{
+		{name: "already reached", current: 5, version: 5},
+		{name: "already exceeded", current: 6, version: 5},
+		{name: "reached by update", current: 1, version: 3, updates: []int{2, 3}},
+		{name: "exceeded by update", current: 1, version: 3, updates: []int{4}},
+		{name: "not reached", current: 1, version: 3, updates: []int{2}, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersistence(t, respondJSON(nil), []*models.Attribute{{ID: 1, Name: "a"}})
+			p.Update.Version = tt.current
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			done := make(chan error)
+			go func() { done <- p.WaitForVersion(ctx, tt.version) }()
+
+			// Give the observer time to register
+			time.Sleep(10 * time.Millisecond)
+			for _, v := range tt.updates {
+				p.handleWebSocketMessage(newVersionUpdate(v))
+			}
+
+			if err := <-done; !errors.Is(err, tt.wantErr) {
+				t.Errorf("WaitForVersion() = %v, want %v", err, tt.wantErr)
+			}
+			if len(p.Update.observers) != 0 {
+				t.Errorf("%d observers are still registered", len(p.Update.observers))
+			}
+		})
+	}
+}
+
+func TestRemoveObserverWithPendingNotifications(t *testing.T) {
+	p := &PersistenceUpdate{}
+	c := p.RegisterObserver()
+
+	// The notifications are never received
+	p.notifyForUpdates(&models.Update{})
+	p.notifyForUpdates(nil)
+	p.RemoveObserver(c)
+
+	select {
+	case _, ok := <-c:
+		for ok {
+			_, ok = <-c
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Channel of the observer was not closed")
+	}
+}
@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"sync"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+// WatchAttribute provides a live view of the entries of a single attribute.
+// The given callback is called once with the current entries of the attribute and
+// afterwards every time an update of the data affects these entries.
+// The callback is called sequentially from a separate goroutine. The passed entries are
+// the locally cached entries, so don't modify them!
+//
+// The watching is stopped when the returned function is called or the base context
+// of the persistence layer is canceled
+func (p *Persistence) WatchAttribute(id int, cb func(entries []*models.Entry)) (unsubscribe func()) {
+	c := p.Update.RegisterObserver()
+	stop := make(chan struct{})
+
+	go func() {
+		defer p.Update.RemoveObserver(c)
+
+		entries := p.attributeEntries(id)
+		cb(entries)
+
+		for {
+			select {
+			case upd, ok := <-c:
+				if !ok {
+					return
+				}
+
+				if updateAffectsAttribute(upd, id, entries) {
+					entries = p.attributeEntries(id)
+					cb(entries)
+				}
+			case <-stop:
+				return
+			case <-p.context.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+// attributeEntries returns all locally cached entries of the attribute with the given ID
+func (p *Persistence) attributeEntries(id int) []*models.Entry {
+	rtc := make([]*models.Entry, 0)
+	p.ForEachEntry(models.EntryFilter{}, func(e *models.Entry) bool {
+		if e.Attribute != nil && e.Attribute.ID == id {
+			rtc = append(rtc, e)
+		}
+		return true
+	})
+
+	return rtc
+}
+
+// updateAffectsAttribute returns whether the given update affects the entries of the
+// attribute with the given ID. The entries are the currently known entries of the attribute
+func updateAffectsAttribute(upd models.Update, id int, entries []*models.Entry) bool {
+	// No update information are available (e.g. a full reload)
+	if upd.IsZero() {
+		return true
+	}
+
+	// The attribute itself was changed
+	for _, attributes := range [][]*models.Attribute{upd.Attribute.Updated, upd.Attribute.Created} {
+		for _, a := range attributes {
+			if a.ID == id {
+				return true
+			}
+		}
+	}
+	for _, deleted := range upd.Attribute.Deleted {
+		if deleted == id {
+			return true
+		}
+	}
+
+	// An entry of the attribute was changed or an entry was moved to another attribute
+	for _, changed := range [][]*models.Entry{upd.Entry.Updated, upd.Entry.Created} {
+		for _, e := range changed {
+			if (e.Attribute != nil && e.Attribute.ID == id) || containsEntry(entries, e.ID) {
+				return true
+			}
+		}
+	}
+	for _, deleted := range upd.Entry.Deleted {
+		if containsEntry(entries, deleted) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsEntry returns whether an entry with the given ID is contained in the entries
+func containsEntry(entries []*models.Entry, id int) bool {
+	for _, e := range entries {
+		if e.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,6 +17,10 @@ import (
 	"github.com/lesismal/nbio/nbhttp/websocket"
 )
 
+// ErrNotConnected is returned when a message should be sent while no
+// WebSocket connection is established
+var ErrNotConnected = fmt.Errorf("the WebSocket is not connected")
+
 // WebSocket is used to obtain updates of attributes and entries
 // in real time.
 // For some specific attribute flags like "noDB" or "executeResponse"
@@ -33,6 +37,24 @@ type WebSocket struct {
 	// Defaulting to "wss://rpdb.rpjosh.de/api/socket"
 	SocketURL string
 
+	// Maximum time to wait for establishing a connection (including the TLS
+	// and WebSocket handshake). Defaulting to 5 seconds
+	DialTimeout time.Duration
+
+	// Logger to use for this module. Defaulting to the global logger
+	Logger *logger.Logger
+
+	// Function that is called when the connection was established or lost (including
+	// failed connection attempts). "attempt" is the number of the connection attempts since
+	// the last stable connection. This can be used to alert after multiple failures.
+	// The function is called synchronously, so it should return fast
+	OnStateChange func(connected bool, attempt int)
+
+	// Function returning the time to wait before the next reconnect based on the number
+	// of the already failed attempts. A negative duration falls back to the default.
+	// Defaulting to [GetReconnectTimeout]
+	ReconnectFunc func(attempt int) time.Duration
+
 	// Managed by persistence: API key used to authenticate against
 	// the server
 	ApiKey string
@@ -40,6 +62,10 @@ type WebSocket struct {
 	// Manged by persistence: callback function called when receiving a socket message
 	OnMessage func(message models.WebSocketMessage)
 
+	// Managed by persistence: callback function called when the server closed the
+	// connection with the code [CloseCodeResyncRequired]
+	OnResyncRequired func()
+
 	// Managed by persistence: base context to use for the WebSocket
 	BaseContext context.Context
 
@@ -49,6 +75,12 @@ type WebSocket struct {
 	// The currently used websocket connection
 	connection *websocket.Conn
 
+	// The nbio engine of the current connection
+	engine *nbhttp.Engine
+
+	// Number of nbio engines that were started and not stopped yet
+	runningEngines atomic.Int32
+
 	// The context of a single WebSocket connection
 	context       context.Context
 	cancelContext context.CancelFunc
@@ -65,6 +97,36 @@ type WebSocket struct {
 
 	// Ping pong manager for the connection
 	pingPong *ClientMgr
+
+	// Execution responses that couldn't be sent because no connection was available.
+	// These are resent after the next successful connect
+	pendingResponses []pendingExecResponse
+	pendingMtx       sync.Mutex
+}
+
+// CloseCodeResyncRequired is the close code sent by the server if the version
+// of the client (sent during the handshake via the headers "Version" and "Version-Date")
+// is too old to provide an incremental update. The client has to reload all data.
+//
+// The server can also signal this through a message of the type [models.WebSocketTypeResync]
+// without closing the connection
+const CloseCodeResyncRequired = 4000
+
+// Default time to wait for establishing a WebSocket connection
+const defaultDialTimeout = 5 * time.Second
+
+// Maximum number of execution responses that are queued while not connected
+const maxPendingExecResponses = 20
+
+// Time after which a queued execution response is dropped. The server waits
+// at a maximum of 60 seconds for a response
+const pendingExecResponseTTL = 60 * time.Second
+
+// pendingExecResponse is an execution response that is queued until
+// the next connection was established
+type pendingExecResponse struct {
+	response models.ExecutionResponse
+	created  time.Time
 }
 
 // webSocketClientMessage is a wrapper around messages that can be sent
@@ -77,30 +139,36 @@ type webSocketClientMessage struct {
 func (w *WebSocket) Start() {
 	if !w.UseWebsocket {
 		// WebSocket should not be started
+		w.log().Debug("Not starting WebSocket: disabled by the options")
 		return
 	}
 	if w.BaseContext.Err() != nil {
 		// Base Context was canceled
-		logger.Debug("Not starting WebSocket: base context already canceled")
+		w.log().Debug("Not starting WebSocket: base context already canceled")
 		return
 	}
 
 	// Try to close any old connections
 	if err := w.CloseWithMessage(uint16(1000), "Disconnect"); err != nil {
-		logger.Warning(err.Error())
+		w.log().Warning(err.Error())
 	}
 
 	// Increment the reconnect counter
 	w.reconnectAttempts.Store(w.reconnectAttempts.Load() + 1)
 
+	// Notify about the result of the connection attempt after the mutex was unlocked
+	connected := false
+	defer func() { w.notifyStateChange(connected) }()
+
 	// Lock this for all further operations
 	w.mtx.Lock()
 	defer w.mtx.Unlock()
 
-	// Close any old context
+	// Close any old context and engine
 	if w.cancelContext != nil {
 		w.cancelContext()
 	}
+	w.stopEngineWithoutLock()
 	// Create new context to use
 	w.context, w.cancelContext = context.WithCancel(w.BaseContext)
 
@@ -112,17 +180,20 @@ func (w *WebSocket) Start() {
 	w.wasIntentionallyClosed.Store(false)
 
 	// Set default logger to use
-	logging.DefaultLogger = newNbioLogger()
+	logging.DefaultLogger = newNbioLogger(w.log())
 
 	// Start engine and dialer
 	engine := nbhttp.NewEngine(nbhttp.Config{Context: w.context})
 	if err := engine.Start(); err != nil {
-		logger.Error("Failed to start nbio engine: %s", err)
+		w.log().Error("Failed to start nbio engine: %s", err)
+	} else {
+		w.engine = engine
+		w.log().Trace("Started nbio engine (%d running)", w.runningEngines.Add(1))
 	}
 	dialer := websocket.Dialer{
 		Engine:      engine,
 		Upgrader:    w.newUpgrader(),
-		DialTimeout: time.Second * 5,
+		DialTimeout: w.getDialTimeout(),
 	}
 
 	// Build request with authentication header
@@ -138,15 +209,58 @@ func (w *WebSocket) Start() {
 	// Open connection
 	con, _, err := dialer.Dial(w.SocketURL, headers)
 	if err != nil {
-		logger.Warning("Failed to connect to WebSocket: %s", err)
+		w.log().Warning("Failed to connect to WebSocket: %s", err)
 		w.scheduleReconnect()
 		return
 	}
 	w.connection = con
+	connected = true
 
 	// Add ping pong handler for keepalive checks
 	con.SetReadDeadline(time.Now().Add(KeepaliveTimeout))
 	w.pingPong.Add(con)
+
+	// Send the execution responses that couldn't be delivered (the mutex is still locked)
+	go w.resendPendingExecResponses()
+}
+
+// Reconnect closes the current connection (if any) and immediately tries to
+// establish a new one without waiting for a scheduled reconnect. The number of failed
+// attempts is reset, so that the backoff starts again from the beginning.
+// This can be used when the system signals that the network is available again.
+// It's safe to call this while being connected or disconnected. The function returns
+// after the connection attempt finished
+func (w *WebSocket) Reconnect() {
+	if !w.UseWebsocket {
+		w.log().Debug("Not reconnecting WebSocket: disabled by the options")
+		return
+	}
+
+	w.log().Debug("Reconnecting WebSocket")
+	w.reconnectAttempts.Store(0)
+
+	// A scheduled reconnect is aborted because "Start()" cancels the context
+	w.Start()
+}
+
+// notifyStateChange calls the function "OnStateChange" (if set) with the
+// current number of reconnect attempts
+func (w *WebSocket) notifyStateChange(connected bool) {
+	if w.OnStateChange != nil {
+		w.OnStateChange(connected, int(w.reconnectAttempts.Load()))
+	}
+}
+
+// getDialTimeout returns the configured dial timeout or the default value
+// if no valid timeout was set
+func (w *WebSocket) getDialTimeout() time.Duration {
+	if w.DialTimeout < 0 {
+		w.log().Warning("Ignoring negative dial timeout of the WebSocket: %s", w.DialTimeout)
+	} else if w.DialTimeout > 0 {
+		return w.DialTimeout
+	}
+
+	return defaultDialTimeout
 }
 
 // newUpgrader creates a new websocket.Upgrader which is used to handle
@@ -159,7 +273,7 @@ func (w *WebSocket) newUpgrader() *websocket.Upgrader {
 
 	u.SetCloseHandler(func(c *websocket.Conn, i int, s string) {
 		if w.wasIntentionallyClosed.Load() {
-			logger.Debug("Closed WebSocket intentionally from client side")
+			w.log().Debug("Closed WebSocket intentionally from client side")
 		} else {
 			w.onClose(c, i, s)
 		}
@@ -168,18 +282,18 @@ func (w *WebSocket) newUpgrader() *websocket.Upgrader {
 	u.OnMessage(func(c *websocket.Conn, messageType websocket.MessageType, data []byte) {
 		c.SetDeadline(time.Now().Add(KeepaliveTimeout))
 		w.reconnectAttempts.Store(0)
-		logger.Trace("Received message from WebSocket: %s", data)
+		w.log().Trace("Received message from WebSocket: %s", data)
 
 		// Try to convert the received message to an WebSocket message
 		var msg models.WebSocketMessage
 		if err := json.Unmarshal(data, &msg); err != nil {
-			logger.Debug("Received message from WebSocket: %s", data)
-			logger.Warning("Failed to unmarshal WebSocket message: %s", err)
+			w.log().Debug("Received message from WebSocket: %s", data)
+			w.log().Warning("Failed to unmarshal WebSocket message: %s", err)
 		} else if w.OnMessage != nil {
-			logger.Debug("Received message from WebSocket with type %q", msg.Type)
+			w.log().Debug("Received message from WebSocket with type %q", msg.Type)
 			w.OnMessage(msg)
 		} else {
-			logger.Debug("Received message from WebSocket but no 'OnMessage()' function provided")
+			w.log().Debug("Received message from WebSocket but no 'OnMessage()' function provided")
 		}
 	})
 
@@ -189,7 +303,7 @@ func (w *WebSocket) newUpgrader() *websocket.Upgrader {
 
 	u.OnClose(func(c *websocket.Conn, err error) {
 		if w.wasIntentionallyClosed.Load() {
-			logger.Debug("Closed WebSocket intentionally from client side")
+			w.log().Debug("Closed WebSocket intentionally from client side")
 		} else {
 			errorMessage := ""
 			if err != nil {
@@ -207,9 +321,9 @@ func (w *WebSocket) newUpgrader() *websocket.Upgrader {
 // intentially closed
 func (w *WebSocket) onClose(_ *websocket.Conn, i int, s string) {
 	if w.reconnectAttempts.Load() <= 1 {
-		logger.Info("Closed WebSocket with status %q (%d)", s, i)
+		w.log().Info("Closed WebSocket with status %q (%d)", s, i)
 	} else {
-		logger.Debug("Closed WebSocket with status %q (%d)", s, i)
+		w.log().Debug("Closed WebSocket with status %q (%d)", s, i)
 	}
 
 	// Cancel the context
@@ -219,46 +333,76 @@ func (w *WebSocket) onClose(_ *websocket.Conn, i int, s string) {
 	if w.cancelContext != nil {
 		w.cancelContext()
 	}
+	w.stopEngineWithoutLock()
 
 	// Create new context to use
 	w.context, w.cancelContext = context.WithCancel(w.BaseContext)
 
 	w.mtx.Unlock()
+	w.notifyStateChange(false)
+
+	// The version of the client is stale. Reload all data before reconnecting
+	// so that the handshake is done with the current version
+	if i == CloseCodeResyncRequired && w.OnResyncRequired != nil {
+		w.log().Info("Server requested a full reload of the data")
+		w.OnResyncRequired()
+	}
 
 	// Schedule the next reconnect
 	w.scheduleReconnect()
 }
 
-// scheduleReconnect schedules a reconnect of the WebSocket after a short waiting time
-// to not attach the WebSocket server :)
-func (w *WebSocket) scheduleReconnect() {
+// GetReconnectTimeout returns the time to wait before the next reconnect is tried
+// based on the number of the already failed attempts
+func GetReconnectTimeout(attempts int32) time.Duration {
 	waitTime := 5 * time.Second
-	c := w.reconnectAttempts.Load()
 
-	if c < 2 {
+	if attempts < 2 {
 		waitTime = 5 * time.Second
-	} else if c < 6 {
+	} else if attempts < 6 {
 		waitTime = 10 * time.Second
-	} else if c < 10 {
+	} else if attempts < 10 {
 		waitTime = 120 * time.Second
-	} else if c < 15 {
+	} else if attempts < 15 {
 		waitTime = 5 * time.Minute
-	} else if c < 25 {
+	} else if attempts < 25 {
 		waitTime = 10 * time.Minute
-	} else if c < 50 {
+	} else if attempts < 50 {
 		waitTime = 30 * time.Minute
-	} else if c < 90 {
+	} else if attempts < 90 {
 		waitTime = 60 * time.Minute
 	}
 
-	logger.Debug("Scheduled a reconnect in %.0f seconds", waitTime.Seconds())
+	return waitTime
+}
+
+// reconnectTimeout returns the time to wait before the next reconnect using
+// the configured "ReconnectFunc" or [GetReconnectTimeout]
+func (w *WebSocket) reconnectTimeout() time.Duration {
+	attempts := w.reconnectAttempts.Load()
+
+	if w.ReconnectFunc != nil {
+		if waitTime := w.ReconnectFunc(int(attempts)); waitTime >= 0 {
+			return waitTime
+		}
+	}
+
+	return GetReconnectTimeout(attempts)
+}
+
+// scheduleReconnect schedules a reconnect of the WebSocket after a short waiting time
+// to not attach the WebSocket server :)
+func (w *WebSocket) scheduleReconnect() {
+	waitTime := w.reconnectTimeout()
+
+	w.log().Debug("Scheduled a reconnect in %.0f seconds", waitTime.Seconds())
 
 	go func() {
 		select {
 		case <-time.After(waitTime):
 			w.Start()
 		case <-w.context.Done():
-			logger.Debug("Not rescheduling an reconnect because context was canceled")
+			w.log().Debug("Not rescheduling an reconnect because context was canceled")
 		}
 	}()
 }
@@ -270,7 +414,7 @@ func (w *WebSocket) CloseWithMessage(code uint16, message string) error {
 
 	// Check if a connection is available
 	if w.context == nil || w.context.Err() != nil || w.connection == nil {
-		logger.Trace("Not closing connection because WebSocket is not connected")
+		w.log().Trace("Not closing connection because WebSocket is not connected")
 		return nil
 	}
 
@@ -291,37 +435,127 @@ func (w *WebSocket) CloseWithMessage(code uint16, message string) error {
 		if w.cancelContext != nil {
 			w.cancelContext()
 		}
+		w.stopEngineWithoutLock()
 	}
 
 	return nil
 }
 
+// stopEngineWithoutLock stops the currently used nbio engine (if any).
+// The engine is stopped in the background because the close handlers of
+// the engine may be the caller of this function.
+// This method does NOT lock the mutex
+func (w *WebSocket) stopEngineWithoutLock() {
+	if w.engine == nil {
+		return
+	}
+
+	go func(engine *nbhttp.Engine) {
+		engine.Stop()
+		w.log().Trace("Stopped nbio engine (%d running)", w.runningEngines.Add(-1))
+	}(w.engine)
+	w.engine = nil
+}
+
+// RunningEngines returns the number of nbio engines that were started
+// and not stopped yet. Normally, this should never be greater than one
+func (w *WebSocket) RunningEngines() int {
+	return int(w.runningEngines.Load())
+}
+
 // SendExecutionResponse sends the given execution response to the
 // WebSocket server
 func (w *WebSocket) SendExecutionResponse(response models.ExecutionResponse) {
 	data, err := json.Marshal(webSocketClientMessage{ExecutionResponse: response})
 	if err != nil {
-		logger.Error("Failed to marshal execution response")
+		w.log().Error("Failed to marshal execution response")
 		return
 	}
 
 	if err := w.sendMessage(data); err != nil {
-		logger.Error("Failed to send execution response to WebSocket: %s", err)
+		w.log().Warning("Failed to send execution response to WebSocket: %s. Queuing it for the next connection", err)
+		w.queueExecResponse(pendingExecResponse{response: response, created: time.Now()})
 	}
 }
 
+// queueExecResponse adds the given execution response to the queue of responses that
+// are sent after the next connect. An already queued response for the same entry is replaced
+func (w *WebSocket) queueExecResponse(pending pendingExecResponse) {
+	w.pendingMtx.Lock()
+	defer w.pendingMtx.Unlock()
+
+	for i, p := range w.pendingResponses {
+		if p.response.EntryId == pending.response.EntryId {
+			w.pendingResponses[i] = pending
+			return
+		}
+	}
+
+	// Drop the oldest response if the queue is full
+	if len(w.pendingResponses) >= maxPendingExecResponses {
+		w.log().Warning("Dropping execution response of entry #%d because the queue is full", w.pendingResponses[0].response.EntryId)
+		w.pendingResponses = w.pendingResponses[1:]
+	}
+	w.pendingResponses = append(w.pendingResponses, pending)
+}
+
+// resendPendingExecResponses sends all queued execution responses that are not
+// stale yet. Responses that couldn't be sent again are kept in the queue
+func (w *WebSocket) resendPendingExecResponses() {
+	w.pendingMtx.Lock()
+	pending := w.pendingResponses
+	w.pendingResponses = nil
+	w.pendingMtx.Unlock()
+
+	for _, p := range pending {
+		if time.Since(p.created) > pendingExecResponseTTL {
+			w.log().Debug("Dropping stale execution response of entry #%d", p.response.EntryId)
+			continue
+		}
+
+		data, err := json.Marshal(webSocketClientMessage{ExecutionResponse: p.response})
+		if err != nil {
+			w.log().Error("Failed to marshal execution response")
+			continue
+		}
+
+		if err := w.sendMessage(data); err != nil {
+			w.log().Debug("Failed to resend execution response of entry #%d: %s", p.response.EntryId, err)
+			w.queueExecResponse(p)
+		} else {
+			w.log().Debug("Resent execution response of entry #%d", p.response.EntryId)
+		}
+	}
+}
+
+// IsConnected returns whether a connection to the WebSocket is currently established
+func (w *WebSocket) IsConnected() bool {
+	if w == nil {
+		return false
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.context != nil && w.context.Err() == nil && w.connection != nil
+}
+
 // sendMessage sends the given message to the current WebSocket
 // connection
 func (w *WebSocket) sendMessage(data []byte) error {
-	if w != nil && w.context.Err() == nil {
-		w.mtx.Lock()
-		err := w.connection.WriteMessage(websocket.TextMessage, data)
-		w.mtx.Unlock()
+	if w == nil {
+		return ErrNotConnected
+	}
 
-		return err
-	} else {
-		return fmt.Errorf("no active connection")
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// The connection is cleared transiently during a reconnect
+	if w.context == nil || w.context.Err() != nil || w.connection == nil {
+		return ErrNotConnected
 	}
+
+	return w.connection.WriteMessage(websocket.TextMessage, data)
 }
 
 // nbioLogger is a logger adapter for the nbio engine to the RPJosh go-logger
@@ -337,9 +571,9 @@ func (l nbioLogger) Warn(message string, parameters ...any) {
 	l.Logger.Log(logger.LevelWarning, message, parameters...)
 }
 
-func newNbioLogger() nbioLogger {
-	printLevel := logger.GetGlobalLogger().Level
-	logLevel := logger.GetGlobalLogger().File.Level
+func newNbioLogger(base *logger.Logger) nbioLogger {
+	printLevel := base.Level
+	logLevel := base.File.Level
 
 	log := logger.NewLoggerWithFile(
 		&logger.Logger{
@@ -347,9 +581,18 @@ func newNbioLogger() nbioLogger {
 			File: &logger.FileLogger{
 				Level: logLevel,
 			},
-			ColoredOutput: logger.GetGlobalLogger().ColoredOutput,
-		}, logger.GetGlobalLogger(),
+			ColoredOutput: base.ColoredOutput,
+		}, base,
 	)
 
 	return nbioLogger{Logger: log}
 }
+
+// log returns the logger to use for the WebSocket
+func (w *WebSocket) log() *logger.Logger {
+	if w.Logger != nil {
+		return w.Logger
+	}
+
+	return logger.GetGlobalLogger()
+}
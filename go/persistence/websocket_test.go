@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RPJoshL/RPdb/v4/go/models"
+)
+
+func TestSendMessageWhileDisconnected(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ws   *WebSocket
+	}{
+		{name: "no WebSocket", ws: nil},
+		{name: "never connected", ws: &WebSocket{}},
+		{name: "connection closed", ws: &WebSocket{context: canceled}},
+		{name: "reconnecting", ws: &WebSocket{context: context.Background()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ws.sendMessage([]byte("{}")); !errors.Is(err, ErrNotConnected) {
+				t.Errorf("sendMessage() = %v, want %v", err, ErrNotConnected)
+			}
+		})
+	}
+}
+
+func TestSendExecutionResponseWhileDisconnected(t *testing.T) {
+	ws := &WebSocket{context: context.Background()}
+
+	// A response for the same entry replaces the queued one
+	ws.SendExecutionResponse(models.ExecutionResponse{EntryId: 1, Text: "first"})
+	ws.SendExecutionResponse(models.ExecutionResponse{EntryId: 2, Text: "other"})
+	ws.SendExecutionResponse(models.ExecutionResponse{EntryId: 1, Text: "second"})
+
+	want := []models.ExecutionResponse{{EntryId: 1, Text: "second"}, {EntryId: 2, Text: "other"}}
+	if len(ws.pendingResponses) != len(want) {
+		t.Fatalf("Queued %d execution responses, want %d", len(ws.pendingResponses), len(want))
+	}
+	for i, p := range ws.pendingResponses {
+		if p.response != want[i] {
+			t.Errorf("Queued response %d = %+v, want %+v", i, p.response, want[i])
+		}
+	}
+}
+
+func TestReconnectTimeout(t *testing.T) {
+	custom := func(attempt int) time.Duration { return time.Duration(attempt) * time.Second }
+
+	tests := []struct {
+		name          string
+		reconnectFunc func(attempt int) time.Duration
+		attempts      int32
+		want          time.Duration
+	}{
+		{name: "default first attempt", attempts: 0, want: 5 * time.Second},
+		{name: "default after failed attempts", attempts: 7, want: 120 * time.Second},
+		{name: "default after many attempts", attempts: 20, want: 10 * time.Minute},
+		{name: "custom", reconnectFunc: custom, attempts: 3, want: 3 * time.Second},
+		{name: "custom without waiting", reconnectFunc: custom, attempts: 0, want: 0},
+		{name: "negative falls back to the default", reconnectFunc: func(int) time.Duration { return -1 }, attempts: 3, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &WebSocket{ReconnectFunc: tt.reconnectFunc}
+			ws.reconnectAttempts.Store(tt.attempts)
+
+			if got := ws.reconnectTimeout(); got != tt.want {
+				t.Errorf("reconnectTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}